@@ -0,0 +1,29 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey is a long-lived credential a user creates for scripts and CI
+// jobs. Only the SHA-256 hash of the key is stored, Prefix identifies it
+// in listings.
+type APIKey struct {
+	Id         uuid.UUID  `json:"id"`
+	UserId     uuid.UUID  `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"-"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// NewAPIKey is a freshly created key, the only time Key is available
+type NewAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go-authentication-exercise/internal/apikey/request"
+	"go-authentication-exercise/internal/apikey/service"
+	"go-authentication-exercise/internal/middleware"
+	"go-authentication-exercise/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type apiKeyHandler struct {
+	service service.APIKeyService
+}
+
+func NewAPIKeyHandler(sv service.APIKeyService) APIKeyHandler {
+	return &apiKeyHandler{
+		service: sv,
+	}
+}
+
+// Create generates an API key for the authenticated user. Requests
+// authenticated with an API key or a token of an OAuth client can't create
+// keys.
+func (h *apiKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := middleware.UserFromContext(ctx)
+	if user == nil {
+		util.Error(w, http.StatusUnauthorized, nil, "Unauthorized")
+		return
+	}
+
+	if middleware.APIKeyFromContext(ctx) != nil {
+		util.Error(w, http.StatusForbidden, nil, "API keys can't create API keys")
+		return
+	}
+
+	if middleware.DelegatedClientId(ctx) != "" {
+		util.Error(w, http.StatusForbidden, nil, "OAuth clients can't create API keys")
+		return
+	}
+
+	// get payload
+	payload := &request.CreateAPIKeyRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	key, err := h.service.Create(ctx, user.Id, payload.Name, payload.Scopes, payload.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidExpiry) {
+			util.Error(w, http.StatusBadRequest, nil, err.Error())
+			return
+		}
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusCreated, key, "Store the key now, it won't be shown again")
+}
+
+// List returns the API keys of the authenticated user, without the keys
+func (h *apiKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		util.Error(w, http.StatusUnauthorized, nil, "Unauthorized")
+		return
+	}
+
+	keys, err := h.service.List(r.Context(), user.Id)
+	if err != nil {
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, keys, "")
+}
+
+// Revoke revokes the API key in the path
+func (h *apiKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		util.Error(w, http.StatusUnauthorized, nil, "Unauthorized")
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid API key id")
+		return
+	}
+
+	if err := h.service.Revoke(r.Context(), user.Id, id); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			util.Error(w, http.StatusNotFound, nil, err.Error())
+			return
+		}
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, nil, "API key revoked")
+}
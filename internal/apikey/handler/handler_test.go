@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-authentication-exercise/internal/apikey/entity"
+	"go-authentication-exercise/internal/apikey/service"
+	"go-authentication-exercise/internal/middleware"
+	userEntity "go-authentication-exercise/internal/user/entity"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAPIKeyService is a mock implementation of the APIKeyService interface
+type MockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyService) Create(ctx context.Context, userId uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*entity.NewAPIKey, error) {
+	args := m.Called(ctx, userId, name, scopes, expiresAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.NewAPIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) List(ctx context.Context, userId uuid.UUID) ([]*entity.APIKey, error) {
+	args := m.Called(ctx, userId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) Revoke(ctx context.Context, userId uuid.UUID, id uuid.UUID) error {
+	args := m.Called(ctx, userId, id)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyService) Authenticate(ctx context.Context, key string) (*entity.APIKey, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.APIKey), args.Error(1)
+}
+
+func TestCreate(t *testing.T) {
+	user := &userEntity.User{Id: uuid.New(), Username: "testuser"}
+
+	tests := []struct {
+		name               string
+		requestBody        map[string]interface{}
+		viaAPIKey          bool
+		viaOAuthClient     bool
+		setupMock          func(*MockAPIKeyService)
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name: "Key created",
+			requestBody: map[string]interface{}{
+				"name":   "ci",
+				"scopes": []string{"users:read"},
+			},
+			setupMock: func(mockService *MockAPIKeyService) {
+				mockService.On("Create", mock.Anything, user.Id, "ci", []string{"users:read"}, (*time.Time)(nil)).
+					Return(&entity.NewAPIKey{APIKey: &entity.APIKey{Id: uuid.New(), Name: "ci"}, Key: "pat_abc_secret"}, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedMessage:    "Store the key now, it won't be shown again",
+		},
+		{
+			name: "Expiry in the past",
+			requestBody: map[string]interface{}{
+				"name":      "ci",
+				"expiresAt": "2000-01-01T00:00:00Z",
+			},
+			setupMock: func(mockService *MockAPIKeyService) {
+				mockService.On("Create", mock.Anything, user.Id, "ci", []string(nil), mock.Anything).
+					Return(nil, service.ErrInvalidExpiry)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    service.ErrInvalidExpiry.Error(),
+		},
+		{
+			name:               "Missing name",
+			requestBody:        map[string]interface{}{},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    "Validation error",
+		},
+		{
+			name: "Authenticated with an API key",
+			requestBody: map[string]interface{}{
+				"name": "ci",
+			},
+			viaAPIKey:          true,
+			expectedStatusCode: http.StatusForbidden,
+			expectedMessage:    "API keys can't create API keys",
+		},
+		{
+			name: "Authenticated with a token of an OAuth client",
+			requestBody: map[string]interface{}{
+				"name": "ci",
+			},
+			viaOAuthClient:     true,
+			expectedStatusCode: http.StatusForbidden,
+			expectedMessage:    "OAuth clients can't create API keys",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockAPIKeyService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			handler := NewAPIKeyHandler(mockService)
+
+			jsonBody, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/user/api-keys", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			ctx := middleware.WithUser(req.Context(), user)
+			if tt.viaAPIKey {
+				ctx = middleware.WithAPIKey(ctx, &entity.APIKey{Id: uuid.New(), UserId: user.Id})
+			}
+			if tt.viaOAuthClient {
+				ctx = middleware.WithClaims(ctx, jwt.MapClaims{"client_id": "webapp", "scope": "openid"})
+			}
+			res := httptest.NewRecorder()
+
+			handler.Create(res, req.WithContext(ctx))
+
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			var responseBody map[string]interface{}
+			assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &responseBody))
+			assert.Equal(t, tt.expectedMessage, responseBody["message"])
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package handler
+
+import (
+	"net/http"
+)
+
+type APIKeyHandler interface {
+	Create(w http.ResponseWriter, r *http.Request)
+	List(w http.ResponseWriter, r *http.Request)
+	Revoke(w http.ResponseWriter, r *http.Request)
+}
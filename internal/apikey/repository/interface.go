@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-authentication-exercise/internal/apikey/entity"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, m *entity.APIKey) (*entity.APIKey, error)
+	FindOneByTokenHash(ctx context.Context, tokenHash string) (*entity.APIKey, error)
+	ListByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.APIKey, error)
+	Revoke(ctx context.Context, id uuid.UUID, userId uuid.UUID) (bool, error)
+	Touch(ctx context.Context, id uuid.UUID, at time.Time) error
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-authentication-exercise/internal/apikey/entity"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const apiKeyColumns = "id, user_id, name, prefix, token_hash, scopes, expires_at, last_used_at, revoked_at, created_at"
+
+// touchInterval limits how often last_used_at is written for busy keys
+const touchInterval = time.Minute
+
+type apiKeyRepository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) APIKeyRepository {
+	return &apiKeyRepository{
+		db: db,
+	}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, m *entity.APIKey) (*entity.APIKey, error) {
+	query := `INSERT INTO api_keys (id, user_id, name, prefix, token_hash, scopes, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING ` + apiKeyColumns
+
+	row := r.db.QueryRowContext(ctx, query, m.Id, m.UserId, m.Name, m.Prefix, m.TokenHash, pq.Array(m.Scopes), m.ExpiresAt)
+
+	if err := scanAPIKey(row, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (r *apiKeyRepository) FindOneByTokenHash(ctx context.Context, tokenHash string) (*entity.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE token_hash = $1"
+
+	row := r.db.QueryRowContext(ctx, query, tokenHash)
+
+	key := entity.APIKey{}
+	if err := scanAPIKey(row, &key); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // no record
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// ListByUserId returns the keys of the user that haven't been revoked,
+// newest first
+func (r *apiKeyRepository) ListByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + ` FROM api_keys
+			WHERE user_id = $1 AND revoked_at IS NULL
+			ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []*entity.APIKey{}
+	for rows.Next() {
+		var key entity.APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, err
+		}
+
+		res = append(res, &key)
+	}
+
+	return res, rows.Err()
+}
+
+// Revoke revokes a key of the user. It reports false when the user has no
+// such key.
+func (r *apiKeyRepository) Revoke(ctx context.Context, id uuid.UUID, userId uuid.UUID) (bool, error) {
+	query := `UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	res, err := r.db.ExecContext(ctx, query, id, userId)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// Touch records that the key was used at, at most once per touchInterval
+func (r *apiKeyRepository) Touch(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `UPDATE api_keys SET last_used_at = $2
+			WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)`
+
+	_, err := r.db.ExecContext(ctx, query, id, at, at.Add(-touchInterval))
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner, m *entity.APIKey) error {
+	return row.Scan(
+		&m.Id,
+		&m.UserId,
+		&m.Name,
+		&m.Prefix,
+		&m.TokenHash,
+		pq.Array(&m.Scopes),
+		&m.ExpiresAt,
+		&m.LastUsedAt,
+		&m.RevokedAt,
+		&m.CreatedAt)
+}
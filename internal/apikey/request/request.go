@@ -0,0 +1,11 @@
+package request
+
+import "time"
+
+// CreateAPIKeyRequest names the key and limits it to the given permission
+// scopes. Keys without expiresAt never expire.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"omitempty,dive,required"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
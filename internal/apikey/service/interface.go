@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go-authentication-exercise/internal/apikey/entity"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyService interface {
+	Create(ctx context.Context, userId uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*entity.NewAPIKey, error)
+	List(ctx context.Context, userId uuid.UUID) ([]*entity.APIKey, error)
+	Revoke(ctx context.Context, userId uuid.UUID, id uuid.UUID) error
+	Authenticate(ctx context.Context, key string) (*entity.APIKey, error)
+}
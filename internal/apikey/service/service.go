@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"go-authentication-exercise/internal/apikey/entity"
+	"go-authentication-exercise/internal/apikey/repository"
+
+	"github.com/google/uuid"
+)
+
+// KeyPrefix starts every API key, so they can't be mistaken for JWTs and
+// are easy to spot in leaked files
+const KeyPrefix = "pat_"
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidExpiry  = errors.New("expiry must be in the future")
+)
+
+type apiKeyService struct {
+	repository repository.APIKeyRepository
+	now        func() time.Time
+}
+
+func NewService(repo repository.APIKeyRepository) APIKeyService {
+	return &apiKeyService{
+		repository: repo,
+		now:        time.Now,
+	}
+}
+
+// IsAPIKey reports whether token looks like an API key rather than a JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, KeyPrefix)
+}
+
+// Create generates a key for the user. The key itself is only returned
+// here, afterwards it is identified by its prefix.
+func (s *apiKeyService) Create(ctx context.Context, userId uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*entity.NewAPIKey, error) {
+	if expiresAt != nil && !expiresAt.After(s.now()) {
+		return nil, ErrInvalidExpiry
+	}
+
+	prefix, err := randomString(4, hex.EncodeToString)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return nil, err
+	}
+
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	key := KeyPrefix + prefix + "_" + secret
+	created, err := s.repository.Create(ctx, &entity.APIKey{
+		Id:        uuid.New(),
+		UserId:    userId,
+		Name:      name,
+		Prefix:    KeyPrefix + prefix,
+		TokenHash: hashKey(key),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.NewAPIKey{APIKey: created, Key: key}, nil
+}
+
+// List returns the user's keys that haven't been revoked
+func (s *apiKeyService) List(ctx context.Context, userId uuid.UUID) ([]*entity.APIKey, error) {
+	return s.repository.ListByUserId(ctx, userId)
+}
+
+// Revoke revokes one of the user's keys
+func (s *apiKeyService) Revoke(ctx context.Context, userId uuid.UUID, id uuid.UUID) error {
+	revoked, err := s.repository.Revoke(ctx, id, userId)
+	if err != nil {
+		return err
+	}
+
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// Authenticate returns the key if it is valid and records its use
+func (s *apiKeyService) Authenticate(ctx context.Context, key string) (*entity.APIKey, error) {
+	if !IsAPIKey(key) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	stored, err := s.repository.FindOneByTokenHash(ctx, hashKey(key))
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	if stored == nil || stored.RevokedAt != nil || (stored.ExpiresAt != nil && !now.Before(*stored.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if err := s.repository.Touch(ctx, stored.Id, now); err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+func randomString(size int, encode func([]byte) string) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return encode(b), nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go-authentication-exercise/internal/apikey/entity"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPIKeyRepository keeps keys in memory
+type fakeAPIKeyRepository struct {
+	keys    map[string]*entity.APIKey
+	touched int
+}
+
+func newFakeAPIKeyRepository() *fakeAPIKeyRepository {
+	return &fakeAPIKeyRepository{keys: make(map[string]*entity.APIKey)}
+}
+
+func (r *fakeAPIKeyRepository) Create(ctx context.Context, m *entity.APIKey) (*entity.APIKey, error) {
+	m.CreatedAt = time.Now()
+	r.keys[m.TokenHash] = m
+	return m, nil
+}
+
+func (r *fakeAPIKeyRepository) FindOneByTokenHash(ctx context.Context, tokenHash string) (*entity.APIKey, error) {
+	return r.keys[tokenHash], nil
+}
+
+func (r *fakeAPIKeyRepository) ListByUserId(ctx context.Context, userId uuid.UUID) ([]*entity.APIKey, error) {
+	res := []*entity.APIKey{}
+	for _, key := range r.keys {
+		if key.UserId == userId && key.RevokedAt == nil {
+			res = append(res, key)
+		}
+	}
+	return res, nil
+}
+
+func (r *fakeAPIKeyRepository) Revoke(ctx context.Context, id uuid.UUID, userId uuid.UUID) (bool, error) {
+	for _, key := range r.keys {
+		if key.Id == id && key.UserId == userId && key.RevokedAt == nil {
+			now := time.Now()
+			key.RevokedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeAPIKeyRepository) Touch(ctx context.Context, id uuid.UUID, at time.Time) error {
+	r.touched++
+	return nil
+}
+
+func TestCreateAndAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeAPIKeyRepository()
+	s := NewService(repo)
+	userId := uuid.New()
+
+	created, err := s.Create(ctx, userId, "ci", []string{"users:read"}, nil)
+	require.NoError(t, err)
+
+	assert.True(t, IsAPIKey(created.Key))
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix+"_"))
+	assert.NotContains(t, created.TokenHash, created.Key)
+
+	key, err := s.Authenticate(ctx, created.Key)
+	require.NoError(t, err)
+	assert.Equal(t, userId, key.UserId)
+	assert.Equal(t, []string{"users:read"}, key.Scopes)
+	assert.Equal(t, 1, repo.touched)
+
+	_, err = s.Authenticate(ctx, created.Key+"x")
+	assert.Equal(t, ErrInvalidAPIKey, err)
+
+	// another user can't revoke the key
+	assert.Equal(t, ErrAPIKeyNotFound, s.Revoke(ctx, uuid.New(), key.Id))
+
+	require.NoError(t, s.Revoke(ctx, userId, key.Id))
+	_, err = s.Authenticate(ctx, created.Key)
+	assert.Equal(t, ErrInvalidAPIKey, err)
+}
+
+func TestAuthenticateExpired(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := &apiKeyService{repository: newFakeAPIKeyRepository(), now: func() time.Time { return now }}
+
+	expiresAt := now.Add(time.Hour)
+	created, err := s.Create(ctx, uuid.New(), "ci", nil, &expiresAt)
+	require.NoError(t, err)
+
+	_, err = s.Authenticate(ctx, created.Key)
+	assert.NoError(t, err)
+
+	now = expiresAt
+	_, err = s.Authenticate(ctx, created.Key)
+	assert.Equal(t, ErrInvalidAPIKey, err)
+
+	past := now.Add(-time.Minute)
+	_, err = s.Create(ctx, uuid.New(), "ci", nil, &past)
+	assert.Equal(t, ErrInvalidExpiry, err)
+}
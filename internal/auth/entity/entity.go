@@ -0,0 +1,296 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is a persisted, opaque refresh token. Only the SHA-256 hash
+// of the token is stored. Tokens issued by rotating a previous one share
+// the same FamilyId so a replayed token can revoke the whole chain.
+type RefreshToken struct {
+	Id        uuid.UUID
+	UserId    uuid.UUID
+	FamilyId  uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// PasswordResetToken is a single-use token emailed to a user who forgot
+// their password. Only the SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	Id        uuid.UUID
+	UserId    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// EmailVerificationToken is a single-use token mailed to confirm that the
+// user owns Email. Only the SHA-256 hash of the token is stored.
+type EmailVerificationToken struct {
+	Id        uuid.UUID
+	UserId    uuid.UUID
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// TOTPCredential is a user's authenticator app secret. It only protects
+// logins once ConfirmedAt is set. LastUsedStep is the time step of the last
+// accepted code, so a code can't be used twice.
+type TOTPCredential struct {
+	UserId       uuid.UUID
+	Secret       string
+	ConfirmedAt  *time.Time
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// MFAChallenge is the pending second step of a login. Only the SHA-256 hash
+// of the challenge token is stored.
+type MFAChallenge struct {
+	Id        uuid.UUID
+	UserId    uuid.UUID
+	TokenHash string
+	Attempts  int
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// LoginChallenge is returned by a login that needs a second factor, the
+// token is exchanged for a TokenPair together with a code
+type LoginChallenge struct {
+	MFARequired    bool     `json:"mfaRequired"`
+	ChallengeToken string   `json:"challengeToken"`
+	ExpiresIn      int64    `json:"expiresIn"`
+	Methods        []string `json:"methods"`
+}
+
+// TOTPSetup is returned when enrolling an authenticator app
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// LoginAttempt counts the recent failed logins for a key, which is either
+// a username or a client IP. BlockedUntil is set while further attempts
+// are refused.
+type LoginAttempt struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	BlockedUntil  *time.Time
+}
+
+// TokenPair is returned to the client after a successful login or refresh
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int64  `json:"expiresIn"`
+	// space separated scopes granted to an OAuth client, empty otherwise
+	Scope string `json:"scope,omitempty"`
+	// OpenID Connect ID token, issued when the openid scope was granted
+	IDToken string `json:"idToken,omitempty"`
+}
+
+// CookieTokens is returned instead of the TokenPair in cookie mode, the
+// tokens themselves are only set as HttpOnly cookies
+type CookieTokens struct {
+	CSRFToken string `json:"csrfToken"`
+	ExpiresIn int64  `json:"expiresIn"`
+}
+
+// SigningKey is a persisted JWT signing key. RetiredAt is set when a newer
+// key replaces it; it keeps verifying tokens until ExpiresAt.
+type SigningKey struct {
+	Id          string
+	Algorithm   string
+	KeyMaterial string
+	CreatedAt   time.Time
+	RetiredAt   *time.Time
+	ExpiresAt   *time.Time
+}
+
+// Client describes the device a login came from
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// Session is a login on one device. Its id is the id of the refresh token
+// family, and access tokens carry it in the `sid` claim.
+type Session struct {
+	Id         uuid.UUID  `json:"id"`
+	UserId     uuid.UUID  `json:"-"`
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	ClientId   string     `json:"clientId,omitempty"`
+	Scopes     []string   `json:"scopes,omitempty"`
+	AuthTime   time.Time  `json:"-"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	RevokedAt  *time.Time `json:"-"`
+
+	// set for the session of the request listing the sessions
+	Current bool `json:"current"`
+}
+
+// OAuthClient is an application that obtains tokens on behalf of users
+// through the OAuth authorization endpoint. It may only request Scopes and
+// redirect to one of RedirectURIs. Confidential clients authenticate with
+// a secret, of which only the SHA-256 hash is stored, and may also get
+// tokens for themselves with the client credentials grant.
+type OAuthClient struct {
+	Id           string    `json:"clientId"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirectUris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	SecretHash   string    `json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// NewOAuthClient is a freshly registered client, the only time the secret
+// of a confidential client is available
+type NewOAuthClient struct {
+	*OAuthClient
+	ClientSecret string `json:"clientSecret,omitempty"`
+}
+
+// AuthorizationCode is issued to an OAuth client once the user approved
+// its request, and exchanged for tokens together with the PKCE verifier
+type AuthorizationCode struct {
+	Id            uuid.UUID
+	ClientId      string
+	UserId        uuid.UUID
+	CodeHash      string
+	RedirectURI   string
+	Scopes        []string
+	CodeChallenge string
+	Nonce         string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+	UsedAt        *time.Time
+	CreatedAt     time.Time
+}
+
+// AuthorizationRequest holds the parameters of a request to the OAuth
+// authorization endpoint
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientId            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+}
+
+// ClientGrant is what a user granted an OAuth client when approving an
+// authorization request. First-party logins have no ClientId.
+type ClientGrant struct {
+	ClientId string
+	Scopes   []string
+	// echoed in the ID token
+	Nonce string
+	// when the user signed in to approve
+	AuthTime time.Time
+}
+
+// DeviceCode is a pending device authorization. The device polls with the
+// device code while the user approves the user code in a browser; only
+// hashes of both are stored.
+type DeviceCode struct {
+	Id             uuid.UUID
+	ClientId       string
+	DeviceCodeHash string
+	UserCodeHash   string
+	Scopes         []string
+	// minimum seconds between polls, raised when the device polls faster
+	Interval     int
+	ExpiresAt    time.Time
+	LastPolledAt *time.Time
+	UserId       *uuid.UUID
+	ApprovedAt   *time.Time
+	DeniedAt     *time.Time
+	ConsumedAt   *time.Time
+	CreatedAt    time.Time
+}
+
+// DeviceAuthorization is the response of the device authorization endpoint
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// OAuthToken is the response of the OAuth token endpoint
+type OAuthToken struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+// Introspection is the response of the token introspection endpoint
+// (RFC 7662), inactive tokens only report Active
+type Introspection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientId  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	JTI       string `json:"jti,omitempty"`
+}
+
+// OpenIDConfiguration is the OpenID Connect discovery document served at
+// /.well-known/openid-configuration
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint,omitempty"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// UserInfo holds the claims about the user returned by the userinfo
+// endpoint, the profile claims only with the profile scope
+type UserInfo struct {
+	Subject           string `json:"sub"`
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	UpdatedAt         int64  `json:"updated_at,omitempty"`
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"go-authentication-exercise/internal/auth/service"
+	"go-authentication-exercise/internal/user/entity"
+	"go-authentication-exercise/internal/util"
+)
+
+// DeviceAuthorization is the RFC 8628 device authorization endpoint, it
+// hands a device the codes to start the flow with
+func (h *oauthHandler) DeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, &service.OAuthError{Code: "invalid_request", Description: "invalid form body"})
+		return
+	}
+
+	clientId, clientSecret := clientCredentials(r)
+	authorization, err := h.oauth.RequestDeviceCode(r.Context(), clientId, clientSecret, r.PostForm.Get("scope"))
+	if err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+
+	util.JSON(w, http.StatusOK, authorization)
+}
+
+// Device shows the verification page. Without a user code it asks for one,
+// otherwise the user reviews the device's request and signs in, unless the
+// cookie session already did.
+func (h *oauthHandler) Device(w http.ResponseWriter, r *http.Request) {
+	userCode := r.URL.Query().Get("user_code")
+	if userCode == "" {
+		renderPage(w, http.StatusOK, deviceCodePage, &devicePageData{})
+		return
+	}
+
+	page, ok := h.checkUserCode(w, r, userCode)
+	if !ok {
+		return
+	}
+	page.setSession(r)
+
+	renderPage(w, http.StatusOK, deviceApprovePage, page)
+}
+
+// DecideDevice handles the sign in and consent form of the verification
+// page, the device gets its tokens on its next poll. Both decisions need
+// the user, from the cookie session or the credentials of the form.
+func (h *oauthHandler) DecideDevice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userCode := r.PostFormValue("user_code")
+
+	page, ok := h.checkUserCode(w, r, userCode)
+	if !ok {
+		return
+	}
+	page.setSession(r)
+
+	user, ok := h.deviceUser(w, r, page)
+	if !ok {
+		return
+	}
+
+	approve := r.PostFormValue("decision") == "allow"
+	if err := h.oauth.DecideDevice(ctx, userCode, user, approve); err != nil {
+		h.deviceError(w, err)
+		return
+	}
+
+	if !approve {
+		renderPage(w, http.StatusOK, deviceDonePage, "Access denied. The device was not signed in.")
+		return
+	}
+
+	renderPage(w, http.StatusOK, deviceDonePage, "Access granted. You can return to your device.")
+}
+
+// deviceUser returns the user deciding on the device, signed in by the
+// cookie session or otherwise by the form, and shows the form again when
+// the credentials are wrong
+func (h *oauthHandler) deviceUser(w http.ResponseWriter, r *http.Request, page *devicePageData) (*entity.User, bool) {
+	if page.User != nil {
+		return page.User, true
+	}
+
+	page.Username = r.PostFormValue("username")
+	user, err := h.auth.Authenticate(r.Context(), page.Username, r.PostFormValue("password"), r.PostFormValue("code"), clientFromRequest(r))
+	if err != nil {
+		page.Error = err.Error()
+
+		var retry *service.RetryAfterError
+		switch {
+		case errors.As(err, &retry):
+			renderPage(w, http.StatusTooManyRequests, deviceApprovePage, page)
+		case errors.Is(err, service.ErrInvalidCredentials), errors.Is(err, service.ErrMFARequired),
+			errors.Is(err, service.ErrInvalidMFACode):
+			renderPage(w, http.StatusUnauthorized, deviceApprovePage, page)
+		case errors.Is(err, service.ErrAccountDisabled), errors.Is(err, service.ErrEmailNotVerified):
+			renderPage(w, http.StatusForbidden, deviceApprovePage, page)
+		default:
+			renderPage(w, http.StatusInternalServerError, errorPage, "Something went wrong, please try again later.")
+		}
+		return nil, false
+	}
+
+	return user, true
+}
+
+// checkUserCode looks the user code up and asks for it again when it is
+// invalid
+func (h *oauthHandler) checkUserCode(w http.ResponseWriter, r *http.Request, userCode string) (*devicePageData, bool) {
+	client, scopes, err := h.oauth.CheckUserCode(r.Context(), userCode)
+	if err != nil {
+		h.deviceError(w, err)
+		return nil, false
+	}
+
+	return &devicePageData{UserCode: userCode, Client: client, Scopes: scopes}, true
+}
+
+func (h *oauthHandler) deviceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrInvalidUserCode) {
+		renderPage(w, http.StatusBadRequest, deviceCodePage, &devicePageData{Error: err.Error()})
+		return
+	}
+
+	renderPage(w, http.StatusInternalServerError, errorPage, "Something went wrong, please try again later.")
+}
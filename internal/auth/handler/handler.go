@@ -2,20 +2,29 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	authEntity "go-authentication-exercise/internal/auth/entity"
 	"go-authentication-exercise/internal/auth/request"
 	"go-authentication-exercise/internal/auth/service"
+	"go-authentication-exercise/internal/middleware"
 	"go-authentication-exercise/internal/util"
 )
 
 type authHandler struct {
 	service service.AuthService
+	cookies middleware.CookieConfig
 }
 
-func NewAuthHandler(sv service.AuthService) AuthHandler {
+func NewAuthHandler(sv service.AuthService, cookies middleware.CookieConfig) AuthHandler {
 	return &authHandler{
 		service: sv,
+		cookies: cookies,
 	}
 }
 
@@ -36,13 +45,28 @@ func (h *authHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// login
-	accessToken, err := h.service.Login(ctx, payload.Username, payload.Password)
+	accessToken, challenge, err := h.service.Login(ctx, payload.Username, payload.Password, clientFromRequest(r))
 	if err != nil {
+		var retry *service.RetryAfterError
+		if errors.As(err, &retry) {
+			writeRetryAfter(w, retry)
+			return
+		}
+		if errors.Is(err, service.ErrEmailNotVerified) || errors.Is(err, service.ErrAccountDisabled) {
+			util.Error(w, http.StatusForbidden, nil, err.Error())
+			return
+		}
 		util.Error(w, http.StatusBadRequest, err, err.Error())
 		return
 	}
 
-	util.Success(w, http.StatusOK, accessToken, "")
+	// the second factor is still missing
+	if challenge != nil {
+		util.Success(w, http.StatusOK, challenge, "Two-factor authentication required")
+		return
+	}
+
+	h.writeTokens(w, accessToken, "")
 }
 
 func (h *authHandler) Signup(w http.ResponseWriter, r *http.Request) {
@@ -62,11 +86,440 @@ func (h *authHandler) Signup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// register
-	user, err := h.service.Signup(ctx, payload.Username, payload.Fullname, payload.Password)
+	user, err := h.service.Signup(ctx, payload.Username, payload.Email, payload.Fullname, payload.Password)
 	if err != nil {
 		util.Error(w, http.StatusBadRequest, err, err.Error())
 		return
 	}
 
+	// quiet signup mode answers the same whether or not an account exists
+	if user == nil {
+		util.Success(w, http.StatusAccepted, nil, "Check your email to complete the signup")
+		return
+	}
+
 	util.Success(w, http.StatusOK, user, "")
 }
+
+func (h *authHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// get payload, in cookie mode the refresh token may come as a cookie
+	payload := &request.RefreshRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil && !errors.Is(err, io.EOF) {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	if payload.RefreshToken == "" && h.cookies.Enabled {
+		if cookie, err := r.Cookie(middleware.RefreshTokenCookie); err == nil {
+			if err := middleware.CheckCSRF(r); err != nil {
+				util.Error(w, http.StatusForbidden, nil, err.Error())
+				return
+			}
+			payload.RefreshToken = cookie.Value
+		}
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	// rotate
+	tokens, err := h.service.Refresh(ctx, payload.RefreshToken, "")
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			util.Error(w, http.StatusUnauthorized, nil, err.Error())
+			return
+		}
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	h.writeTokens(w, tokens, "")
+}
+
+func (h *authHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// get payload, the body is optional
+	payload := &request.LogoutRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil && !errors.Is(err, io.EOF) {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	if payload.RefreshToken == "" && h.cookies.Enabled {
+		if cookie, err := r.Cookie(middleware.RefreshTokenCookie); err == nil {
+			payload.RefreshToken = cookie.Value
+		}
+	}
+
+	// get the token that authenticated this request
+	claims := middleware.ClaimsFromContext(ctx)
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+	if jti == "" {
+		util.Error(w, http.StatusUnauthorized, nil, "Invalid token")
+		return
+	}
+
+	user := middleware.UserFromContext(ctx)
+	if user == nil {
+		util.Error(w, http.StatusUnauthorized, nil, "Invalid token")
+		return
+	}
+
+	// revoke
+	sessionId := middleware.SessionIdFromClaims(claims)
+	if err := h.service.Logout(ctx, user.Id, jti, time.Unix(int64(exp), 0), sessionId, payload.RefreshToken); err != nil {
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	if h.cookies.Enabled {
+		h.cookies.Clear(w)
+	}
+
+	util.Success(w, http.StatusOK, nil, "Logged out")
+}
+
+// JWKS publishes the public signing keys so other services can verify
+// access tokens without sharing a secret
+func (h *authHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	util.JSON(w, http.StatusOK, h.service.JWKS())
+}
+
+// ChangePassword sets a new password for the authenticated user and
+// returns a fresh token pair, all other sessions are logged out
+func (h *authHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := middleware.UserFromContext(ctx)
+	if user == nil {
+		util.Error(w, http.StatusUnauthorized, nil, "Unauthorized")
+		return
+	}
+
+	// get payload
+	payload := &request.ChangePasswordRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	// change
+	tokens, err := h.service.ChangePassword(ctx, user.Id, payload.CurrentPassword, payload.NewPassword, clientFromRequest(r))
+	if err != nil {
+		if errors.Is(err, service.ErrIncorrectPassword) || errors.Is(err, service.ErrPasswordPolicy) {
+			util.Error(w, http.StatusBadRequest, nil, err.Error())
+			return
+		}
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	h.writeTokens(w, tokens, "Password changed")
+}
+
+// ForgotPassword mails a reset link. The response is the same whether or
+// not the username exists.
+func (h *authHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// get payload
+	payload := &request.ForgotPasswordRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	if err := h.service.ForgotPassword(ctx, payload.Username); err != nil {
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, nil, "If the account exists, a password reset link has been sent")
+}
+
+// ResetPassword sets a new password using the token from the reset link
+func (h *authHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// get payload
+	payload := &request.ResetPasswordRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	// reset
+	if err := h.service.ResetPassword(ctx, payload.Token, payload.NewPassword); err != nil {
+		if errors.Is(err, service.ErrInvalidResetToken) || errors.Is(err, service.ErrPasswordPolicy) {
+			util.Error(w, http.StatusBadRequest, nil, err.Error())
+			return
+		}
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, nil, "Password has been reset")
+}
+
+// VerifyEmail confirms the email address using the token from the
+// verification link
+func (h *authHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// get payload
+	payload := &request.VerifyEmailRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	// verify
+	if err := h.service.VerifyEmail(ctx, payload.Token); err != nil {
+		if errors.Is(err, service.ErrInvalidVerificationToken) {
+			util.Error(w, http.StatusBadRequest, nil, err.Error())
+			return
+		}
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, nil, "Email verified")
+}
+
+// ResendVerification mails a new verification link. The response is the
+// same whether or not the address belongs to an unverified account.
+func (h *authHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// get payload
+	payload := &request.ResendVerificationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	if err := h.service.ResendVerification(ctx, payload.Email); err != nil {
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, nil, "If the address needs verification, a new link has been sent")
+}
+
+// LoginMFA completes a login challenge with a second factor
+func (h *authHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// get payload
+	payload := &request.LoginMFARequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	// login
+	tokens, err := h.service.LoginMFA(ctx, payload.ChallengeToken, payload.Code, payload.RecoveryCode, clientFromRequest(r))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMFAChallenge) || errors.Is(err, service.ErrInvalidMFACode) {
+			util.Error(w, http.StatusUnauthorized, nil, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrAccountDisabled) {
+			util.Error(w, http.StatusForbidden, nil, err.Error())
+			return
+		}
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	h.writeTokens(w, tokens, "")
+}
+
+// SetupTOTP starts enrolling an authenticator app for the authenticated user
+func (h *authHandler) SetupTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := middleware.UserFromContext(ctx)
+	if user == nil {
+		util.Error(w, http.StatusUnauthorized, nil, "Unauthorized")
+		return
+	}
+
+	setup, err := h.service.SetupTOTP(ctx, user.Id)
+	if err != nil {
+		if errors.Is(err, service.ErrMFAAlreadyEnabled) {
+			util.Error(w, http.StatusConflict, nil, err.Error())
+			return
+		}
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, setup, "")
+}
+
+// ConfirmTOTP enables two-factor authentication and returns the recovery
+// codes
+func (h *authHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := middleware.UserFromContext(ctx)
+	if user == nil {
+		util.Error(w, http.StatusUnauthorized, nil, "Unauthorized")
+		return
+	}
+
+	// get payload
+	payload := &request.ConfirmTOTPRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	// confirm
+	recoveryCodes, err := h.service.ConfirmTOTP(ctx, user.Id, payload.Code)
+	if err != nil {
+		if errors.Is(err, service.ErrMFAAlreadyEnabled) {
+			util.Error(w, http.StatusConflict, nil, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrInvalidMFACode) || errors.Is(err, service.ErrMFANotEnabled) {
+			util.Error(w, http.StatusBadRequest, nil, err.Error())
+			return
+		}
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, map[string]interface{}{
+		"recoveryCodes": recoveryCodes,
+	}, "Two-factor authentication enabled")
+}
+
+// DisableTOTP turns two-factor authentication off for the authenticated user
+func (h *authHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := middleware.UserFromContext(ctx)
+	if user == nil {
+		util.Error(w, http.StatusUnauthorized, nil, "Unauthorized")
+		return
+	}
+
+	// get payload
+	payload := &request.DisableTOTPRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	// disable
+	err := h.service.DisableTOTP(ctx, user.Id, payload.Password, payload.Code, payload.RecoveryCode)
+	if err != nil {
+		if errors.Is(err, service.ErrIncorrectPassword) ||
+			errors.Is(err, service.ErrInvalidMFACode) ||
+			errors.Is(err, service.ErrMFANotEnabled) {
+			util.Error(w, http.StatusBadRequest, nil, err.Error())
+			return
+		}
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, nil, "Two-factor authentication disabled")
+}
+
+// writeTokens answers with a newly issued token pair. In cookie mode the
+// tokens are set as cookies and only the CSRF token is returned.
+func (h *authHandler) writeTokens(w http.ResponseWriter, tokens *authEntity.TokenPair, message string) {
+	if !h.cookies.Enabled {
+		util.Success(w, http.StatusOK, tokens, message)
+		return
+	}
+
+	csrfToken, err := h.cookies.SetTokens(w, tokens)
+	if err != nil {
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, &authEntity.CookieTokens{CSRFToken: csrfToken, ExpiresIn: tokens.ExpiresIn}, message)
+}
+
+// clientFromRequest describes the device a new session is started for
+func clientFromRequest(r *http.Request) authEntity.Client {
+	return authEntity.Client{IP: util.ClientIP(r), UserAgent: r.UserAgent()}
+}
+
+// writeRetryAfter answers a refused login, 423 for a locked account and
+// 429 while backing off, or a client over its limit with 429
+func writeRetryAfter(w http.ResponseWriter, err *service.RetryAfterError) {
+	seconds := int64(math.Ceil(err.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+
+	code := http.StatusTooManyRequests
+	if errors.Is(err, service.ErrAccountLocked) {
+		code = http.StatusLocked
+	}
+
+	util.Error(w, code, nil, err.Error())
+}
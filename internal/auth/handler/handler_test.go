@@ -5,35 +5,155 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/auth/service"
+	"go-authentication-exercise/internal/auth/token"
+	"go-authentication-exercise/internal/middleware"
 	"go-authentication-exercise/internal/user/entity"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+// testClient is the client httptest requests come from
+var testClient = authEntity.Client{IP: "192.0.2.1"}
+
 // MockAuthService is a mock implementation of the AuthService interface
 type MockAuthService struct {
 	mock.Mock
 }
 
-func (m *MockAuthService) Login(ctx context.Context, username string, password string) (string, error) {
-	args := m.Called(ctx, username, password)
-	return args.String(0), args.Error(1)
+func (m *MockAuthService) Login(ctx context.Context, username string, password string, client authEntity.Client) (*authEntity.TokenPair, *authEntity.LoginChallenge, error) {
+	args := m.Called(ctx, username, password, client)
+	tokens, _ := args.Get(0).(*authEntity.TokenPair)
+	challenge, _ := args.Get(1).(*authEntity.LoginChallenge)
+	return tokens, challenge, args.Error(2)
+}
+
+func (m *MockAuthService) LoginMFA(ctx context.Context, challengeToken string, code string, recoveryCode string, client authEntity.Client) (*authEntity.TokenPair, error) {
+	args := m.Called(ctx, challengeToken, code, recoveryCode, client)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*authEntity.TokenPair), args.Error(1)
+}
+
+func (m *MockAuthService) SetupTOTP(ctx context.Context, userId uuid.UUID) (*authEntity.TOTPSetup, error) {
+	args := m.Called(ctx, userId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*authEntity.TOTPSetup), args.Error(1)
+}
+
+func (m *MockAuthService) ConfirmTOTP(ctx context.Context, userId uuid.UUID, code string) ([]string, error) {
+	args := m.Called(ctx, userId, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockAuthService) DisableTOTP(ctx context.Context, userId uuid.UUID, password string, code string, recoveryCode string) error {
+	args := m.Called(ctx, userId, password, code, recoveryCode)
+	return args.Error(0)
 }
 
-func (m *MockAuthService) Signup(ctx context.Context, username string, fullname string, password string) (*entity.User, error) {
-	args := m.Called(ctx, username, fullname, password)
+func (m *MockAuthService) Signup(ctx context.Context, username string, email string, fullname string, password string) (*entity.User, error) {
+	args := m.Called(ctx, username, email, fullname, password)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*entity.User), args.Error(1)
 }
 
+func (m *MockAuthService) Refresh(ctx context.Context, refreshToken string, clientId string) (*authEntity.TokenPair, error) {
+	args := m.Called(ctx, refreshToken, clientId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*authEntity.TokenPair), args.Error(1)
+}
+
+func (m *MockAuthService) Logout(ctx context.Context, userId uuid.UUID, jti string, expiresAt time.Time, sessionId uuid.UUID, refreshToken string) error {
+	args := m.Called(ctx, userId, jti, expiresAt, sessionId, refreshToken)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) JWKS() *token.JWKS {
+	args := m.Called()
+	return args.Get(0).(*token.JWKS)
+}
+
+func (m *MockAuthService) ChangePassword(ctx context.Context, userId uuid.UUID, currentPassword string, newPassword string, client authEntity.Client) (*authEntity.TokenPair, error) {
+	args := m.Called(ctx, userId, currentPassword, newPassword, client)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*authEntity.TokenPair), args.Error(1)
+}
+
+func (m *MockAuthService) SendPasswordReset(ctx context.Context, user *entity.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) ForgotPassword(ctx context.Context, username string) error {
+	args := m.Called(ctx, username)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) ResetPassword(ctx context.Context, resetToken string, newPassword string) error {
+	args := m.Called(ctx, resetToken, newPassword)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) VerifyEmail(ctx context.Context, verificationToken string) error {
+	args := m.Called(ctx, verificationToken)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) ResendVerification(ctx context.Context, email string) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) Authenticate(ctx context.Context, username string, password string, code string, client authEntity.Client) (*entity.User, error) {
+	args := m.Called(ctx, username, password, code, client)
+	user, _ := args.Get(0).(*entity.User)
+	return user, args.Error(1)
+}
+
+func (m *MockAuthService) StartClientSession(ctx context.Context, user *entity.User, client authEntity.Client, grant *authEntity.ClientGrant) (*authEntity.TokenPair, error) {
+	args := m.Called(ctx, user, client, grant)
+	tokens, _ := args.Get(0).(*authEntity.TokenPair)
+	return tokens, args.Error(1)
+}
+
+func (m *MockAuthService) IssueClientToken(ctx context.Context, clientId string, scopes []string) (*authEntity.TokenPair, error) {
+	args := m.Called(ctx, clientId, scopes)
+	tokens, _ := args.Get(0).(*authEntity.TokenPair)
+	return tokens, args.Error(1)
+}
+
+func (m *MockAuthService) ListSessions(ctx context.Context, userId uuid.UUID) ([]*authEntity.Session, error) {
+	args := m.Called(ctx, userId)
+	sessions, _ := args.Get(0).([]*authEntity.Session)
+	return sessions, args.Error(1)
+}
+
+func (m *MockAuthService) RevokeSession(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) error {
+	args := m.Called(ctx, userId, sessionId)
+	return args.Error(0)
+}
+
 func TestLogin(t *testing.T) {
 	tests := []struct {
 		name               string
@@ -41,6 +161,7 @@ func TestLogin(t *testing.T) {
 		setupMock          func(*MockAuthService)
 		expectedStatusCode int
 		expectedResponse   map[string]interface{}
+		expectedRetryAfter string
 	}{
 		{
 			name: "Successful login",
@@ -49,13 +170,23 @@ func TestLogin(t *testing.T) {
 				"password": "password123",
 			},
 			setupMock: func(mockService *MockAuthService) {
-				mockService.On("Login", mock.Anything, "testuser", "password123").
-					Return("jwt-token-here", nil)
+				mockService.On("Login", mock.Anything, "testuser", "password123", testClient).
+					Return(&authEntity.TokenPair{
+						AccessToken:  "jwt-token-here",
+						RefreshToken: "refresh-token-here",
+						TokenType:    "Bearer",
+						ExpiresIn:    900,
+					}, nil, nil)
 			},
 			expectedStatusCode: http.StatusOK,
 			expectedResponse: map[string]interface{}{
 				"message": "",
-				"data":    "jwt-token-here",
+				"data": map[string]interface{}{
+					"accessToken":  "jwt-token-here",
+					"refreshToken": "refresh-token-here",
+					"tokenType":    "Bearer",
+					"expiresIn":    float64(900),
+				},
 			},
 		},
 		{
@@ -65,8 +196,8 @@ func TestLogin(t *testing.T) {
 				"password": "wrongpassword",
 			},
 			setupMock: func(mockService *MockAuthService) {
-				mockService.On("Login", mock.Anything, "testuser", "wrongpassword").
-					Return("", errors.New("invalid credentials"))
+				mockService.On("Login", mock.Anything, "testuser", "wrongpassword", testClient).
+					Return(nil, nil, errors.New("invalid credentials"))
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			expectedResponse: map[string]interface{}{
@@ -74,6 +205,96 @@ func TestLogin(t *testing.T) {
 				"data":    nil,
 			},
 		},
+		{
+			name: "Two-factor authentication required",
+			requestBody: map[string]interface{}{
+				"username": "testuser",
+				"password": "password123",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("Login", mock.Anything, "testuser", "password123", testClient).
+					Return(nil, &authEntity.LoginChallenge{
+						MFARequired:    true,
+						ChallengeToken: "challenge-token",
+						ExpiresIn:      300,
+						Methods:        []string{"totp", "recovery_code"},
+					}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: map[string]interface{}{
+				"message": "Two-factor authentication required",
+				"data": map[string]interface{}{
+					"mfaRequired":    true,
+					"challengeToken": "challenge-token",
+					"expiresIn":      float64(300),
+					"methods":        []interface{}{"totp", "recovery_code"},
+				},
+			},
+		},
+		{
+			name: "Email not verified",
+			requestBody: map[string]interface{}{
+				"username": "testuser",
+				"password": "password123",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("Login", mock.Anything, "testuser", "password123", testClient).
+					Return(nil, nil, service.ErrEmailNotVerified)
+			},
+			expectedStatusCode: http.StatusForbidden,
+			expectedResponse: map[string]interface{}{
+				"message": service.ErrEmailNotVerified.Error(),
+				"data":    nil,
+			},
+		},
+		{
+			name: "Disabled account",
+			requestBody: map[string]interface{}{
+				"username": "testuser",
+				"password": "password123",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("Login", mock.Anything, "testuser", "password123", testClient).
+					Return(nil, nil, service.ErrAccountDisabled)
+			},
+			expectedStatusCode: http.StatusForbidden,
+			expectedResponse: map[string]interface{}{
+				"message": service.ErrAccountDisabled.Error(),
+				"data":    nil,
+			},
+		},
+		{
+			name: "Backing off after failed attempts",
+			requestBody: map[string]interface{}{
+				"username": "testuser",
+				"password": "password123",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("Login", mock.Anything, "testuser", "password123", testClient).
+					Return(nil, nil, &service.RetryAfterError{Err: service.ErrTooManyAttempts, RetryAfter: 1500 * time.Millisecond})
+			},
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectedResponse: map[string]interface{}{
+				"message": service.ErrTooManyAttempts.Error(),
+			},
+			expectedRetryAfter: "2",
+		},
+		{
+			name: "Account locked",
+			requestBody: map[string]interface{}{
+				"username": "testuser",
+				"password": "password123",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("Login", mock.Anything, "testuser", "password123", testClient).
+					Return(nil, nil, &service.RetryAfterError{Err: service.ErrAccountLocked, RetryAfter: 15 * time.Minute})
+			},
+			expectedStatusCode: http.StatusLocked,
+			expectedResponse: map[string]interface{}{
+				"message": service.ErrAccountLocked.Error(),
+			},
+			expectedRetryAfter: "900",
+		},
 		{
 			name: "Missing required fields",
 			requestBody: map[string]interface{}{
@@ -100,7 +321,7 @@ func TestLogin(t *testing.T) {
 			}
 
 			// Create the handler with mock service
-			handler := NewAuthHandler(mockService)
+			handler := NewAuthHandler(mockService, middleware.CookieConfig{})
 
 			// Create a request
 			jsonBody, _ := json.Marshal(tt.requestBody)
@@ -124,6 +345,9 @@ func TestLogin(t *testing.T) {
 			// Check response message
 			assert.Equal(t, tt.expectedResponse["message"], responseBody["message"])
 
+			// Refused attempts tell the client when to retry
+			assert.Equal(t, tt.expectedRetryAfter, res.Header().Get("Retry-After"))
+
 			// For successful responses, check data
 			if tt.expectedStatusCode == http.StatusOK {
 				assert.Equal(t, tt.expectedResponse["data"], responseBody["data"])
@@ -142,6 +366,7 @@ func TestSignup(t *testing.T) {
 			Id:        uuid.New(),
 			Username:  "testuser",
 			Fullname:  "Test User",
+			Email:     "test@example.com",
 			Password:  "hashed-password", // This would be hashed in a real scenario
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
@@ -159,11 +384,12 @@ func TestSignup(t *testing.T) {
 			name: "Successful signup",
 			requestBody: map[string]interface{}{
 				"username": "testuser",
+				"email":    "test@example.com",
 				"fullname": "Test User",
 				"password": "password123",
 			},
 			setupMock: func(mockService *MockAuthService) {
-				mockService.On("Signup", mock.Anything, "testuser", "Test User", "password123").
+				mockService.On("Signup", mock.Anything, "testuser", "test@example.com", "Test User", "password123").
 					Return(testUser(), nil)
 			},
 			expectedStatusCode: http.StatusOK,
@@ -176,11 +402,12 @@ func TestSignup(t *testing.T) {
 			name: "Username already exists",
 			requestBody: map[string]interface{}{
 				"username": "existinguser",
+				"email":    "existing@example.com",
 				"fullname": "Existing User",
 				"password": "password123",
 			},
 			setupMock: func(mockService *MockAuthService) {
-				mockService.On("Signup", mock.Anything, "existinguser", "Existing User", "password123").
+				mockService.On("Signup", mock.Anything, "existinguser", "existing@example.com", "Existing User", "password123").
 					Return(nil, errors.New("username already exists"))
 			},
 			expectedStatusCode: http.StatusBadRequest,
@@ -189,6 +416,58 @@ func TestSignup(t *testing.T) {
 				"data":    nil,
 			},
 		},
+		{
+			name: "Email already exists",
+			requestBody: map[string]interface{}{
+				"username": "testuser",
+				"email":    "Existing@Example.com",
+				"fullname": "Test User",
+				"password": "password123",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("Signup", mock.Anything, "testuser", "Existing@Example.com", "Test User", "password123").
+					Return(nil, service.ErrEmailTaken)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse: map[string]interface{}{
+				"message": service.ErrEmailTaken.Error(),
+				"data":    nil,
+			},
+		},
+		{
+			name: "Quiet signup",
+			requestBody: map[string]interface{}{
+				"username": "testuser",
+				"email":    "test@example.com",
+				"fullname": "Test User",
+				"password": "password123",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("Signup", mock.Anything, "testuser", "test@example.com", "Test User", "password123").
+					Return(nil, nil)
+			},
+			expectedStatusCode: http.StatusAccepted,
+			expectedResponse: map[string]interface{}{
+				"message": "Check your email to complete the signup",
+				"data":    nil,
+			},
+		},
+		{
+			name: "Invalid email",
+			requestBody: map[string]interface{}{
+				"username": "testuser",
+				"email":    "not-an-email",
+				"fullname": "Test User",
+				"password": "password123",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				// Service mock should not be called since validation fails
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse: map[string]interface{}{
+				"message": "Validation error",
+			},
+		},
 		{
 			name: "Missing required fields",
 			requestBody: map[string]interface{}{
@@ -216,7 +495,7 @@ func TestSignup(t *testing.T) {
 			}
 
 			// Create the handler with mock service
-			handler := NewAuthHandler(mockService)
+			handler := NewAuthHandler(mockService, middleware.CookieConfig{})
 
 			// Create a request
 			jsonBody, _ := json.Marshal(tt.requestBody)
@@ -257,3 +536,1117 @@ func TestSignup(t *testing.T) {
 		})
 	}
 }
+
+func TestRefresh(t *testing.T) {
+	tests := []struct {
+		name               string
+		requestBody        map[string]interface{}
+		setupMock          func(*MockAuthService)
+		expectedStatusCode int
+		expectedResponse   map[string]interface{}
+	}{
+		{
+			name: "Successful refresh",
+			requestBody: map[string]interface{}{
+				"refreshToken": "refresh-token",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("Refresh", mock.Anything, "refresh-token", "").
+					Return(&authEntity.TokenPair{
+						AccessToken:  "new-jwt-token",
+						RefreshToken: "new-refresh-token",
+						TokenType:    "Bearer",
+						ExpiresIn:    900,
+					}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: map[string]interface{}{
+				"message": "",
+				"data": map[string]interface{}{
+					"accessToken":  "new-jwt-token",
+					"refreshToken": "new-refresh-token",
+					"tokenType":    "Bearer",
+					"expiresIn":    float64(900),
+				},
+			},
+		},
+		{
+			name: "Invalid refresh token",
+			requestBody: map[string]interface{}{
+				"refreshToken": "unknown-token",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("Refresh", mock.Anything, "unknown-token", "").
+					Return(nil, service.ErrInvalidRefreshToken)
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedResponse: map[string]interface{}{
+				"message": service.ErrInvalidRefreshToken.Error(),
+			},
+		},
+		{
+			name: "Reused refresh token",
+			requestBody: map[string]interface{}{
+				"refreshToken": "used-token",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("Refresh", mock.Anything, "used-token", "").
+					Return(nil, service.ErrRefreshTokenReused)
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedResponse: map[string]interface{}{
+				"message": service.ErrRefreshTokenReused.Error(),
+			},
+		},
+		{
+			name:        "Missing refresh token",
+			requestBody: map[string]interface{}{},
+			setupMock: func(mockService *MockAuthService) {
+				// Service mock should not be called since validation fails
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedResponse: map[string]interface{}{
+				"message": "Validation error",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mock service
+			mockService := new(MockAuthService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			// Create the handler with mock service
+			handler := NewAuthHandler(mockService, middleware.CookieConfig{})
+
+			// Create a request
+			jsonBody, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/auth/refresh", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+
+			// Create a response recorder
+			res := httptest.NewRecorder()
+
+			// Call the handler
+			handler.Refresh(res, req)
+
+			// Check status code
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			// Parse response
+			var responseBody map[string]interface{}
+			err := json.Unmarshal(res.Body.Bytes(), &responseBody)
+			assert.NoError(t, err)
+
+			// Check response message
+			assert.Equal(t, tt.expectedResponse["message"], responseBody["message"])
+
+			// For successful responses, check data
+			if tt.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, tt.expectedResponse["data"], responseBody["data"])
+			}
+
+			// Verify that all expected mock calls were made
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	sessionId := uuid.New()
+	user := &entity.User{Id: uuid.New(), Username: "testuser"}
+
+	tests := []struct {
+		name               string
+		requestBody        string
+		claims             jwt.MapClaims
+		setupMock          func(*MockAuthService)
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name:        "Logout without refresh token",
+			requestBody: "",
+			claims:      jwt.MapClaims{"jti": "token-id", "exp": float64(exp)},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("Logout", mock.Anything, user.Id, "token-id", time.Unix(exp, 0), uuid.Nil, "").
+					Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    "Logged out",
+		},
+		{
+			name:        "Logout with session and refresh token",
+			requestBody: `{"refreshToken":"refresh-token"}`,
+			claims:      jwt.MapClaims{"jti": "token-id", "exp": float64(exp), "sid": sessionId.String()},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("Logout", mock.Anything, user.Id, "token-id", time.Unix(exp, 0), sessionId, "refresh-token").
+					Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    "Logged out",
+		},
+		{
+			name:               "Token without jti",
+			requestBody:        "",
+			claims:             jwt.MapClaims{"exp": float64(exp)},
+			setupMock:          func(mockService *MockAuthService) {},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedMessage:    "Invalid token",
+		},
+		{
+			name:        "Revocation fails",
+			requestBody: "",
+			claims:      jwt.MapClaims{"jti": "token-id", "exp": float64(exp)},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("Logout", mock.Anything, user.Id, "token-id", time.Unix(exp, 0), uuid.Nil, "").
+					Return(errors.New("database unavailable"))
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedMessage:    "database unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mock service
+			mockService := new(MockAuthService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			// Create the handler with mock service
+			handler := NewAuthHandler(mockService, middleware.CookieConfig{})
+
+			// Create a request authenticated with the given claims
+			req := httptest.NewRequest("POST", "/auth/logout", bytes.NewBufferString(tt.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			ctx := middleware.WithClaims(req.Context(), tt.claims)
+			req = req.WithContext(middleware.WithUser(ctx, user))
+
+			// Create a response recorder
+			res := httptest.NewRecorder()
+
+			// Call the handler
+			handler.Logout(res, req)
+
+			// Check status code
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			// Parse response
+			var responseBody map[string]interface{}
+			err := json.Unmarshal(res.Body.Bytes(), &responseBody)
+			assert.NoError(t, err)
+
+			// Check response message
+			assert.Equal(t, tt.expectedMessage, responseBody["message"])
+
+			// Verify that all expected mock calls were made
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	// Setup mock service
+	mockService := new(MockAuthService)
+	mockService.On("JWKS").Return(&token.JWKS{
+		Keys: []token.JWK{
+			{Kty: "OKP", Kid: "key-id", Use: "sig", Alg: "EdDSA", Crv: "Ed25519", X: "public-key"},
+		},
+	})
+
+	// Create the handler with mock service
+	handler := NewAuthHandler(mockService, middleware.CookieConfig{})
+
+	req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+	res := httptest.NewRecorder()
+
+	handler.JWKS(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	// the key set is served as is, without the response envelope
+	var responseBody map[string]interface{}
+	err := json.Unmarshal(res.Body.Bytes(), &responseBody)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"kty": "OKP",
+			"kid": "key-id",
+			"use": "sig",
+			"alg": "EdDSA",
+			"crv": "Ed25519",
+			"x":   "public-key",
+		},
+	}, responseBody["keys"])
+
+	mockService.AssertExpectations(t)
+}
+
+func TestChangePassword(t *testing.T) {
+	user := &entity.User{
+		Id:       uuid.New(),
+		Username: "testuser",
+		Fullname: "Test User",
+	}
+
+	tests := []struct {
+		name               string
+		requestBody        map[string]interface{}
+		user               *entity.User
+		setupMock          func(*MockAuthService)
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name: "Successful change",
+			requestBody: map[string]interface{}{
+				"currentPassword": "password123",
+				"newPassword":     "newpassword123",
+			},
+			user: user,
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("ChangePassword", mock.Anything, user.Id, "password123", "newpassword123", testClient).
+					Return(&authEntity.TokenPair{AccessToken: "new-jwt-token", RefreshToken: "new-refresh-token"}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    "Password changed",
+		},
+		{
+			name: "Incorrect current password",
+			requestBody: map[string]interface{}{
+				"currentPassword": "wrongpassword",
+				"newPassword":     "newpassword123",
+			},
+			user: user,
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("ChangePassword", mock.Anything, user.Id, "wrongpassword", "newpassword123", testClient).
+					Return(nil, service.ErrIncorrectPassword)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    service.ErrIncorrectPassword.Error(),
+		},
+		{
+			name: "Password policy violation",
+			requestBody: map[string]interface{}{
+				"currentPassword": "password123",
+				"newPassword":     "password123",
+			},
+			user: user,
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("ChangePassword", mock.Anything, user.Id, "password123", "password123", testClient).
+					Return(nil, service.ErrPasswordPolicy)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    service.ErrPasswordPolicy.Error(),
+		},
+		{
+			name: "Missing new password",
+			requestBody: map[string]interface{}{
+				"currentPassword": "password123",
+			},
+			user: user,
+			setupMock: func(mockService *MockAuthService) {
+				// Service mock should not be called since validation fails
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    "Validation error",
+		},
+		{
+			name: "Not authenticated",
+			requestBody: map[string]interface{}{
+				"currentPassword": "password123",
+				"newPassword":     "newpassword123",
+			},
+			user:               nil,
+			setupMock:          func(mockService *MockAuthService) {},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedMessage:    "Unauthorized",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mock service
+			mockService := new(MockAuthService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			// Create the handler with mock service
+			handler := NewAuthHandler(mockService, middleware.CookieConfig{})
+
+			// Create a request authenticated as the user
+			jsonBody, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/auth/password", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.user != nil {
+				req = req.WithContext(middleware.WithUser(req.Context(), tt.user))
+			}
+
+			// Create a response recorder
+			res := httptest.NewRecorder()
+
+			// Call the handler
+			handler.ChangePassword(res, req)
+
+			// Check status code
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			// Parse response
+			var responseBody map[string]interface{}
+			err := json.Unmarshal(res.Body.Bytes(), &responseBody)
+			assert.NoError(t, err)
+
+			// Check response message
+			assert.Equal(t, tt.expectedMessage, responseBody["message"])
+
+			// Verify that all expected mock calls were made
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestForgotPassword(t *testing.T) {
+	tests := []struct {
+		name               string
+		requestBody        map[string]interface{}
+		setupMock          func(*MockAuthService)
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name: "Existing user",
+			requestBody: map[string]interface{}{
+				"username": "testuser",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("ForgotPassword", mock.Anything, "testuser").Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    "If the account exists, a password reset link has been sent",
+		},
+		{
+			name: "Unknown user gets the same response",
+			requestBody: map[string]interface{}{
+				"username": "nobody",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("ForgotPassword", mock.Anything, "nobody").Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    "If the account exists, a password reset link has been sent",
+		},
+		{
+			name:        "Missing username",
+			requestBody: map[string]interface{}{},
+			setupMock: func(mockService *MockAuthService) {
+				// Service mock should not be called since validation fails
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    "Validation error",
+		},
+		{
+			name: "Service error",
+			requestBody: map[string]interface{}{
+				"username": "testuser",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("ForgotPassword", mock.Anything, "testuser").Return(errors.New("database error"))
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedMessage:    "database error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mock service
+			mockService := new(MockAuthService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			// Create the handler with mock service
+			handler := NewAuthHandler(mockService, middleware.CookieConfig{})
+
+			// Create a request
+			jsonBody, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/auth/password/forgot", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+
+			// Create a response recorder
+			res := httptest.NewRecorder()
+
+			// Call the handler
+			handler.ForgotPassword(res, req)
+
+			// Check status code
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			// Parse response
+			var responseBody map[string]interface{}
+			err := json.Unmarshal(res.Body.Bytes(), &responseBody)
+			assert.NoError(t, err)
+
+			// Check response message
+			assert.Equal(t, tt.expectedMessage, responseBody["message"])
+
+			// Verify that all expected mock calls were made
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	tests := []struct {
+		name               string
+		requestBody        map[string]interface{}
+		setupMock          func(*MockAuthService)
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name: "Successful reset",
+			requestBody: map[string]interface{}{
+				"token":       "reset-token",
+				"newPassword": "newpassword123",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("ResetPassword", mock.Anything, "reset-token", "newpassword123").Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    "Password has been reset",
+		},
+		{
+			name: "Invalid token",
+			requestBody: map[string]interface{}{
+				"token":       "used-token",
+				"newPassword": "newpassword123",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("ResetPassword", mock.Anything, "used-token", "newpassword123").Return(service.ErrInvalidResetToken)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    service.ErrInvalidResetToken.Error(),
+		},
+		{
+			name: "Password policy violation",
+			requestBody: map[string]interface{}{
+				"token":       "reset-token",
+				"newPassword": "newpassword123",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("ResetPassword", mock.Anything, "reset-token", "newpassword123").Return(service.ErrPasswordPolicy)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    service.ErrPasswordPolicy.Error(),
+		},
+		{
+			name: "Missing token",
+			requestBody: map[string]interface{}{
+				"newPassword": "newpassword123",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				// Service mock should not be called since validation fails
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    "Validation error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mock service
+			mockService := new(MockAuthService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			// Create the handler with mock service
+			handler := NewAuthHandler(mockService, middleware.CookieConfig{})
+
+			// Create a request
+			jsonBody, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/auth/password/reset", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+
+			// Create a response recorder
+			res := httptest.NewRecorder()
+
+			// Call the handler
+			handler.ResetPassword(res, req)
+
+			// Check status code
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			// Parse response
+			var responseBody map[string]interface{}
+			err := json.Unmarshal(res.Body.Bytes(), &responseBody)
+			assert.NoError(t, err)
+
+			// Check response message
+			assert.Equal(t, tt.expectedMessage, responseBody["message"])
+
+			// Verify that all expected mock calls were made
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	tests := []struct {
+		name               string
+		requestBody        map[string]interface{}
+		setupMock          func(*MockAuthService)
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name: "Successful verification",
+			requestBody: map[string]interface{}{
+				"token": "verification-token",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("VerifyEmail", mock.Anything, "verification-token").Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    "Email verified",
+		},
+		{
+			name: "Invalid token",
+			requestBody: map[string]interface{}{
+				"token": "used-token",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("VerifyEmail", mock.Anything, "used-token").Return(service.ErrInvalidVerificationToken)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    service.ErrInvalidVerificationToken.Error(),
+		},
+		{
+			name:        "Missing token",
+			requestBody: map[string]interface{}{},
+			setupMock: func(mockService *MockAuthService) {
+				// Service mock should not be called since validation fails
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    "Validation error",
+		},
+		{
+			name: "Service error",
+			requestBody: map[string]interface{}{
+				"token": "verification-token",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("VerifyEmail", mock.Anything, "verification-token").Return(errors.New("database error"))
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedMessage:    "database error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mock service
+			mockService := new(MockAuthService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			// Create the handler with mock service
+			handler := NewAuthHandler(mockService, middleware.CookieConfig{})
+
+			// Create a request
+			jsonBody, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/auth/verify-email", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+
+			// Create a response recorder
+			res := httptest.NewRecorder()
+
+			// Call the handler
+			handler.VerifyEmail(res, req)
+
+			// Check status code
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			// Parse response
+			var responseBody map[string]interface{}
+			err := json.Unmarshal(res.Body.Bytes(), &responseBody)
+			assert.NoError(t, err)
+
+			// Check response message
+			assert.Equal(t, tt.expectedMessage, responseBody["message"])
+
+			// Verify that all expected mock calls were made
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLoginMFA(t *testing.T) {
+	tests := []struct {
+		name               string
+		requestBody        map[string]interface{}
+		setupMock          func(*MockAuthService)
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name: "Successful login with code",
+			requestBody: map[string]interface{}{
+				"challengeToken": "challenge-token",
+				"code":           "123456",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("LoginMFA", mock.Anything, "challenge-token", "123456", "", testClient).
+					Return(&authEntity.TokenPair{AccessToken: "jwt-token-here", RefreshToken: "refresh-token-here"}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    "",
+		},
+		{
+			name: "Successful login with recovery code",
+			requestBody: map[string]interface{}{
+				"challengeToken": "challenge-token",
+				"recoveryCode":   "abcd-efgh",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("LoginMFA", mock.Anything, "challenge-token", "", "abcd-efgh", testClient).
+					Return(&authEntity.TokenPair{AccessToken: "jwt-token-here", RefreshToken: "refresh-token-here"}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    "",
+		},
+		{
+			name: "Invalid code",
+			requestBody: map[string]interface{}{
+				"challengeToken": "challenge-token",
+				"code":           "000000",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("LoginMFA", mock.Anything, "challenge-token", "000000", "", testClient).
+					Return(nil, service.ErrInvalidMFACode)
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedMessage:    service.ErrInvalidMFACode.Error(),
+		},
+		{
+			name: "Expired challenge",
+			requestBody: map[string]interface{}{
+				"challengeToken": "expired-token",
+				"code":           "123456",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("LoginMFA", mock.Anything, "expired-token", "123456", "", testClient).
+					Return(nil, service.ErrInvalidMFAChallenge)
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedMessage:    service.ErrInvalidMFAChallenge.Error(),
+		},
+		{
+			name: "Missing second factor",
+			requestBody: map[string]interface{}{
+				"challengeToken": "challenge-token",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				// Service mock should not be called since validation fails
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    "Validation error",
+		},
+		{
+			name: "Malformed code",
+			requestBody: map[string]interface{}{
+				"challengeToken": "challenge-token",
+				"code":           "12ab",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				// Service mock should not be called since validation fails
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    "Validation error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mock service
+			mockService := new(MockAuthService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			// Create the handler with mock service
+			handler := NewAuthHandler(mockService, middleware.CookieConfig{})
+
+			// Create a request
+			jsonBody, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/auth/login/mfa", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+
+			// Create a response recorder
+			res := httptest.NewRecorder()
+
+			// Call the handler
+			handler.LoginMFA(res, req)
+
+			// Check status code
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			// Parse response
+			var responseBody map[string]interface{}
+			err := json.Unmarshal(res.Body.Bytes(), &responseBody)
+			assert.NoError(t, err)
+
+			// Check response message
+			assert.Equal(t, tt.expectedMessage, responseBody["message"])
+
+			// Verify that all expected mock calls were made
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestConfirmTOTP(t *testing.T) {
+	user := &entity.User{
+		Id:       uuid.New(),
+		Username: "testuser",
+	}
+
+	tests := []struct {
+		name               string
+		requestBody        map[string]interface{}
+		setupMock          func(*MockAuthService)
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name: "Successful confirmation",
+			requestBody: map[string]interface{}{
+				"code": "123456",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("ConfirmTOTP", mock.Anything, user.Id, "123456").
+					Return([]string{"abcd-efgh", "ijkl-mnop"}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    "Two-factor authentication enabled",
+		},
+		{
+			name: "Invalid code",
+			requestBody: map[string]interface{}{
+				"code": "000000",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("ConfirmTOTP", mock.Anything, user.Id, "000000").
+					Return(nil, service.ErrInvalidMFACode)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    service.ErrInvalidMFACode.Error(),
+		},
+		{
+			name: "Already enabled",
+			requestBody: map[string]interface{}{
+				"code": "123456",
+			},
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("ConfirmTOTP", mock.Anything, user.Id, "123456").
+					Return(nil, service.ErrMFAAlreadyEnabled)
+			},
+			expectedStatusCode: http.StatusConflict,
+			expectedMessage:    service.ErrMFAAlreadyEnabled.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mock service
+			mockService := new(MockAuthService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			// Create the handler with mock service
+			handler := NewAuthHandler(mockService, middleware.CookieConfig{})
+
+			// Create a request authenticated as the user
+			jsonBody, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/auth/mfa/totp/verify", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(middleware.WithUser(req.Context(), user))
+
+			// Create a response recorder
+			res := httptest.NewRecorder()
+
+			// Call the handler
+			handler.ConfirmTOTP(res, req)
+
+			// Check status code
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			// Parse response
+			var responseBody map[string]interface{}
+			err := json.Unmarshal(res.Body.Bytes(), &responseBody)
+			assert.NoError(t, err)
+
+			// Check response message
+			assert.Equal(t, tt.expectedMessage, responseBody["message"])
+
+			// Successful confirmations return the recovery codes once
+			if tt.expectedStatusCode == http.StatusOK {
+				data, _ := responseBody["data"].(map[string]interface{})
+				assert.Len(t, data["recoveryCodes"], 2)
+			}
+
+			// Verify that all expected mock calls were made
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	user := &entity.User{
+		Id:       uuid.New(),
+		Username: "testuser",
+	}
+	current := &authEntity.Session{Id: uuid.New(), UserId: user.Id, UserAgent: "curl/8.0"}
+	other := &authEntity.Session{Id: uuid.New(), UserId: user.Id, UserAgent: "Firefox"}
+
+	// Setup mock service
+	mockService := new(MockAuthService)
+	mockService.On("ListSessions", mock.Anything, user.Id).
+		Return([]*authEntity.Session{current, other}, nil)
+
+	// Create the handler with mock service
+	handler := NewAuthHandler(mockService, middleware.CookieConfig{})
+
+	// Create a request authenticated as the user in the current session
+	req := httptest.NewRequest("GET", "/user/me/sessions", nil)
+	ctx := middleware.WithUser(req.Context(), user)
+	ctx = middleware.WithClaims(ctx, jwt.MapClaims{"sid": current.Id.String()})
+	req = req.WithContext(ctx)
+
+	// Create a response recorder
+	res := httptest.NewRecorder()
+
+	// Call the handler
+	handler.ListSessions(res, req)
+
+	// Check status code
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	// Parse response
+	var responseBody struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	err := json.Unmarshal(res.Body.Bytes(), &responseBody)
+	assert.NoError(t, err)
+
+	// Only the session making the request is current
+	if assert.Len(t, responseBody.Data, 2) {
+		assert.Equal(t, true, responseBody.Data[0]["current"])
+		assert.Equal(t, false, responseBody.Data[1]["current"])
+		assert.NotContains(t, responseBody.Data[0], "userId")
+	}
+
+	// Verify that all expected mock calls were made
+	mockService.AssertExpectations(t)
+}
+
+func TestRevokeSession(t *testing.T) {
+	user := &entity.User{
+		Id:       uuid.New(),
+		Username: "testuser",
+	}
+	sessionId := uuid.New()
+
+	tests := []struct {
+		name               string
+		id                 string
+		setupMock          func(*MockAuthService)
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name: "Successful revocation",
+			id:   sessionId.String(),
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("RevokeSession", mock.Anything, user.Id, sessionId).
+					Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    "Session revoked",
+		},
+		{
+			name: "Unknown session",
+			id:   sessionId.String(),
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("RevokeSession", mock.Anything, user.Id, sessionId).
+					Return(service.ErrSessionNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+			expectedMessage:    service.ErrSessionNotFound.Error(),
+		},
+		{
+			name:               "Malformed id",
+			id:                 "not-a-uuid",
+			setupMock:          func(mockService *MockAuthService) {},
+			expectedStatusCode: http.StatusNotFound,
+			expectedMessage:    service.ErrSessionNotFound.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mock service
+			mockService := new(MockAuthService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			// Create the handler with mock service
+			handler := NewAuthHandler(mockService, middleware.CookieConfig{})
+
+			// Create a request authenticated as the user
+			req := httptest.NewRequest("DELETE", "/user/me/sessions/"+tt.id, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			req = req.WithContext(middleware.WithUser(req.Context(), user))
+
+			// Create a response recorder
+			res := httptest.NewRecorder()
+
+			// Call the handler
+			handler.RevokeSession(res, req)
+
+			// Check status code
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			// Parse response
+			var responseBody map[string]interface{}
+			err := json.Unmarshal(res.Body.Bytes(), &responseBody)
+			assert.NoError(t, err)
+
+			// Check response message
+			assert.Equal(t, tt.expectedMessage, responseBody["message"])
+
+			// Verify that all expected mock calls were made
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLoginCookieMode(t *testing.T) {
+	cookies := middleware.CookieConfig{Enabled: true, AccessTokenTTL: time.Hour, RefreshTokenTTL: 24 * time.Hour}
+	tokens := &authEntity.TokenPair{AccessToken: "access-token", RefreshToken: "refresh-token", TokenType: "Bearer", ExpiresIn: 3600}
+
+	// Setup mock service
+	mockService := new(MockAuthService)
+	mockService.On("Login", mock.Anything, "testuser", "password123", testClient).
+		Return(tokens, nil, nil)
+
+	// Create the handler in cookie mode
+	handler := NewAuthHandler(mockService, cookies)
+
+	// Create a request
+	req := httptest.NewRequest("POST", "/auth/login", bytes.NewBufferString(`{"username":"testuser","password":"password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	// Create a response recorder
+	res := httptest.NewRecorder()
+
+	// Call the handler
+	handler.Login(res, req)
+
+	// Check status code
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	// The tokens are only set as cookies
+	assert.NotContains(t, res.Body.String(), "access-token")
+	assert.NotContains(t, res.Body.String(), "refresh-token")
+
+	var responseBody struct {
+		Data authEntity.CookieTokens `json:"data"`
+	}
+	err := json.Unmarshal(res.Body.Bytes(), &responseBody)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, responseBody.Data.CSRFToken)
+	assert.Equal(t, int64(3600), responseBody.Data.ExpiresIn)
+
+	set := map[string]string{}
+	for _, cookie := range res.Result().Cookies() {
+		set[cookie.Name] = cookie.Value
+	}
+	assert.Equal(t, "access-token", set[middleware.AccessTokenCookie])
+	assert.Equal(t, "refresh-token", set[middleware.RefreshTokenCookie])
+	assert.Equal(t, responseBody.Data.CSRFToken, set[middleware.CSRFTokenCookie])
+
+	// Verify that all expected mock calls were made
+	mockService.AssertExpectations(t)
+}
+
+func TestRefreshCookieMode(t *testing.T) {
+	cookies := middleware.CookieConfig{Enabled: true, AccessTokenTTL: time.Hour, RefreshTokenTTL: 24 * time.Hour}
+	tokens := &authEntity.TokenPair{AccessToken: "new-access-token", RefreshToken: "new-refresh-token", TokenType: "Bearer", ExpiresIn: 3600}
+
+	tests := []struct {
+		name               string
+		csrfHeader         string
+		setupMock          func(*MockAuthService)
+		expectedStatusCode int
+	}{
+		{
+			name:       "Refresh token cookie with CSRF token",
+			csrfHeader: "csrf",
+			setupMock: func(mockService *MockAuthService) {
+				mockService.On("Refresh", mock.Anything, "refresh-token", "").
+					Return(tokens, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Refresh token cookie without CSRF token",
+			setupMock:          func(mockService *MockAuthService) {},
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mock service
+			mockService := new(MockAuthService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			// Create the handler in cookie mode
+			handler := NewAuthHandler(mockService, cookies)
+
+			// Create a request without a body
+			req := httptest.NewRequest("POST", "/auth/refresh", nil)
+			req.AddCookie(&http.Cookie{Name: middleware.RefreshTokenCookie, Value: "refresh-token"})
+			req.AddCookie(&http.Cookie{Name: middleware.CSRFTokenCookie, Value: "csrf"})
+			if tt.csrfHeader != "" {
+				req.Header.Set(middleware.CSRFTokenHeader, tt.csrfHeader)
+			}
+
+			// Create a response recorder
+			res := httptest.NewRecorder()
+
+			// Call the handler
+			handler.Refresh(res, req)
+
+			// Check status code
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			// Verify that all expected mock calls were made
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,365 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/auth/request"
+	"go-authentication-exercise/internal/auth/service"
+	"go-authentication-exercise/internal/middleware"
+	"go-authentication-exercise/internal/util"
+
+	"github.com/gorilla/mux"
+)
+
+type oauthHandler struct {
+	oauth service.OAuthService
+	auth  service.AuthService
+}
+
+func NewOAuthHandler(oauth service.OAuthService, auth service.AuthService) OAuthHandler {
+	return &oauthHandler{
+		oauth: oauth,
+		auth:  auth,
+	}
+}
+
+// Authorize shows the login and consent page for a valid authorization
+// request
+func (h *oauthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	req := authorizationRequest(r)
+
+	client, scopes, ok := h.checkAuthorization(w, r, req)
+	if !ok {
+		return
+	}
+
+	renderPage(w, http.StatusOK, authorizePage, &authorizePageData{Client: client, Scopes: scopes, Request: req})
+}
+
+// Approve handles the login and consent form. On success the user is sent
+// back to the client with an authorization code.
+func (h *oauthHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	req := authorizationRequest(r)
+
+	client, scopes, ok := h.checkAuthorization(w, r, req)
+	if !ok {
+		return
+	}
+
+	if r.PostFormValue("decision") != "allow" {
+		redirectWithError(w, r, req, service.OAuthError{Code: "access_denied", Description: "the user denied the request"})
+		return
+	}
+
+	username := r.PostFormValue("username")
+	user, err := h.auth.Authenticate(ctx, username, r.PostFormValue("password"), r.PostFormValue("code"), clientFromRequest(r))
+	if err != nil {
+		page := &authorizePageData{Client: client, Scopes: scopes, Request: req, Username: username, Error: err.Error()}
+
+		var retry *service.RetryAfterError
+		switch {
+		case errors.As(err, &retry):
+			renderPage(w, http.StatusTooManyRequests, authorizePage, page)
+		case errors.Is(err, service.ErrInvalidCredentials), errors.Is(err, service.ErrMFARequired),
+			errors.Is(err, service.ErrInvalidMFACode):
+			renderPage(w, http.StatusUnauthorized, authorizePage, page)
+		case errors.Is(err, service.ErrAccountDisabled), errors.Is(err, service.ErrEmailNotVerified):
+			renderPage(w, http.StatusForbidden, authorizePage, page)
+		default:
+			renderPage(w, http.StatusInternalServerError, errorPage, "Something went wrong, please try again later.")
+		}
+		return
+	}
+
+	code, err := h.oauth.Authorize(ctx, req, user)
+	if err != nil {
+		renderPage(w, http.StatusInternalServerError, errorPage, "Something went wrong, please try again later.")
+		return
+	}
+
+	redirect(w, r, req, url.Values{"code": {code}})
+}
+
+// Token is the OAuth token endpoint, it exchanges authorization codes,
+// device codes and refresh tokens, and issues tokens to confidential
+// clients for themselves
+func (h *oauthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, &service.OAuthError{Code: "invalid_request", Description: "invalid form body"})
+		return
+	}
+
+	clientId, clientSecret := clientCredentials(r)
+
+	var tokens *authEntity.TokenPair
+	var err error
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		tokens, err = h.oauth.ExchangeCode(ctx, clientId, clientSecret, r.PostForm.Get("code"), r.PostForm.Get("redirect_uri"),
+			r.PostForm.Get("code_verifier"), clientFromRequest(r))
+	case "refresh_token":
+		tokens, err = h.oauth.RefreshToken(ctx, clientId, clientSecret, r.PostForm.Get("refresh_token"))
+	case "client_credentials":
+		tokens, err = h.oauth.ClientCredentials(ctx, clientId, clientSecret, r.PostForm.Get("scope"))
+	case service.GrantTypeDeviceCode:
+		tokens, err = h.oauth.ExchangeDeviceCode(ctx, clientId, clientSecret, r.PostForm.Get("device_code"), clientFromRequest(r))
+	default:
+		err = &service.OAuthError{Code: "unsupported_grant_type", Description: "the grant type is not supported"}
+	}
+
+	if err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+
+	util.JSON(w, http.StatusOK, &authEntity.OAuthToken{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    tokens.TokenType,
+		ExpiresIn:    tokens.ExpiresIn,
+		RefreshToken: tokens.RefreshToken,
+		Scope:        tokens.Scope,
+		IDToken:      tokens.IDToken,
+	})
+}
+
+// Introspect is the RFC 7662 token introspection endpoint, for resource
+// servers registered as confidential clients
+func (h *oauthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, &service.OAuthError{Code: "invalid_request", Description: "invalid form body"})
+		return
+	}
+
+	clientId, clientSecret := clientCredentials(r)
+	info, err := h.oauth.Introspect(r.Context(), clientId, clientSecret, r.PostForm.Get("token"))
+	if err != nil {
+		var retry *service.RetryAfterError
+		if errors.As(err, &retry) {
+			writeRetryAfter(w, retry)
+			return
+		}
+		writeOAuthError(w, err)
+		return
+	}
+
+	util.JSON(w, http.StatusOK, info)
+}
+
+// Revoke is the RFC 7009 token revocation endpoint. It answers 200 with an
+// empty body for unknown tokens as well.
+func (h *oauthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, &service.OAuthError{Code: "invalid_request", Description: "invalid form body"})
+		return
+	}
+
+	clientId, clientSecret := clientCredentials(r)
+	if err := h.oauth.Revoke(r.Context(), clientId, clientSecret, r.PostForm.Get("token")); err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// OpenIDConfiguration serves the OpenID Connect discovery document
+func (h *oauthHandler) OpenIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	config, err := h.oauth.OpenIDConfiguration()
+	if err != nil {
+		util.Error(w, http.StatusNotFound, nil, err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	util.JSON(w, http.StatusOK, config)
+}
+
+// UserInfo returns the claims about the user of an access token that was
+// granted the openid scope
+func (h *oauthHandler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.ClaimsFromContext(r.Context())
+	scope, _ := claims["scope"].(string)
+
+	info, err := h.oauth.UserInfo(r.Context(), middleware.UserFromContext(r.Context()).Id, strings.Fields(scope))
+	if err != nil {
+		var oauthErr *service.OAuthError
+		if !errors.As(err, &oauthErr) {
+			util.Error(w, http.StatusInternalServerError, nil, err.Error())
+			return
+		}
+
+		// RFC 6750 bearer token errors
+		code := http.StatusUnauthorized
+		if oauthErr.Code == "insufficient_scope" {
+			code = http.StatusForbidden
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=%q, error_description=%q", oauthErr.Code, oauthErr.Description))
+		util.JSON(w, code, map[string]string{"error": oauthErr.Code, "error_description": oauthErr.Description})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	util.JSON(w, http.StatusOK, info)
+}
+
+// ListClients returns the registered OAuth clients
+func (h *oauthHandler) ListClients(w http.ResponseWriter, r *http.Request) {
+	clients, err := h.oauth.ListClients(r.Context())
+	if err != nil {
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, clients, "")
+}
+
+// CreateClient registers an OAuth client
+func (h *oauthHandler) CreateClient(w http.ResponseWriter, r *http.Request) {
+	// get payload
+	payload := &request.CreateOAuthClientRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	client, err := h.oauth.RegisterClient(r.Context(), payload.Name, payload.RedirectURIs, payload.Scopes, payload.Confidential)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRedirectURI):
+			util.Error(w, http.StatusBadRequest, nil, "redirect URIs must be absolute and without a fragment")
+			return
+		case errors.Is(err, service.ErrRedirectURIRequired):
+			util.Error(w, http.StatusBadRequest, nil, err.Error())
+			return
+		}
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusCreated, client, "")
+}
+
+// DeleteClient removes the OAuth client in the path
+func (h *oauthHandler) DeleteClient(w http.ResponseWriter, r *http.Request) {
+	if err := h.oauth.DeleteClient(r.Context(), mux.Vars(r)["id"]); err != nil {
+		if errors.Is(err, service.ErrOAuthClientNotFound) {
+			util.Error(w, http.StatusNotFound, nil, err.Error())
+			return
+		}
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, nil, "OAuth client deleted")
+}
+
+// checkAuthorization validates the request and answers it when it is
+// invalid. Errors about the client itself are shown to the user, the rest
+// are reported to the client.
+func (h *oauthHandler) checkAuthorization(w http.ResponseWriter, r *http.Request, req *authEntity.AuthorizationRequest) (*authEntity.OAuthClient, []string, bool) {
+	client, scopes, err := h.oauth.CheckAuthorization(r.Context(), req)
+	if err != nil {
+		var oauthErr *service.OAuthError
+		switch {
+		case errors.As(err, &oauthErr):
+			redirectWithError(w, r, req, *oauthErr)
+		case errors.Is(err, service.ErrOAuthClientNotFound), errors.Is(err, service.ErrInvalidRedirectURI):
+			renderPage(w, http.StatusBadRequest, errorPage, err.Error())
+		default:
+			renderPage(w, http.StatusInternalServerError, errorPage, "Something went wrong, please try again later.")
+		}
+		return nil, nil, false
+	}
+
+	return client, scopes, true
+}
+
+// authorizationRequest reads the authorization request from the query, or
+// from the form when it is posted back
+func authorizationRequest(r *http.Request) *authEntity.AuthorizationRequest {
+	return &authEntity.AuthorizationRequest{
+		ResponseType:        r.FormValue("response_type"),
+		ClientId:            r.FormValue("client_id"),
+		RedirectURI:         r.FormValue("redirect_uri"),
+		Scope:               r.FormValue("scope"),
+		State:               r.FormValue("state"),
+		CodeChallenge:       r.FormValue("code_challenge"),
+		CodeChallengeMethod: r.FormValue("code_challenge_method"),
+		Nonce:               r.FormValue("nonce"),
+	}
+}
+
+// redirect sends the user back to the client with params and the state of
+// the request
+func redirect(w http.ResponseWriter, r *http.Request, req *authEntity.AuthorizationRequest, params url.Values) {
+	// the redirect URI matched a registered one, so it parses
+	target, _ := url.Parse(req.RedirectURI)
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	target.RawQuery = query.Encode()
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func redirectWithError(w http.ResponseWriter, r *http.Request, req *authEntity.AuthorizationRequest, err service.OAuthError) {
+	redirect(w, r, req, url.Values{"error": {err.Code}, "error_description": {err.Description}})
+}
+
+// clientCredentials returns the client id and secret of a token request,
+// sent with HTTP Basic authentication or in the form
+func clientCredentials(r *http.Request) (string, string) {
+	if id, secret, ok := r.BasicAuth(); ok {
+		// RFC 6749 form-encodes both before building the header
+		if unescaped, err := url.QueryUnescape(id); err == nil {
+			id = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(secret); err == nil {
+			secret = unescaped
+		}
+		return id, secret
+	}
+
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+}
+
+// writeOAuthError answers a token request with an RFC 6749 error
+func writeOAuthError(w http.ResponseWriter, err error) {
+	var oauthErr *service.OAuthError
+	if !errors.As(err, &oauthErr) {
+		util.JSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	code := http.StatusBadRequest
+	if oauthErr.Code == "invalid_client" {
+		code = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+
+	util.JSON(w, code, map[string]string{"error": oauthErr.Code, "error_description": oauthErr.Description})
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/middleware"
+	"go-authentication-exercise/internal/user/entity"
+)
+
+// authorizePage lets the user log in and approve an OAuth client in one
+// step. The authorization request travels in hidden fields.
+var authorizePage = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in to {{.Client.Name}}</title>
+<style>
+body { font-family: sans-serif; max-width: 24rem; margin: 4rem auto; padding: 0 1rem; }
+label, input, button { display: block; width: 100%; box-sizing: border-box; margin-bottom: .75rem; }
+.error { color: #b00020; }
+.actions { display: flex; gap: .5rem; }
+</style>
+</head>
+<body>
+<h1>Sign in</h1>
+<p><strong>{{.Client.Name}}</strong> wants to access your account.</p>
+{{if .Scopes}}<p>It asks for permission to:</p>
+<ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="/oauth/authorize">
+<input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+<input type="hidden" name="client_id" value="{{.Request.ClientId}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+<input type="hidden" name="scope" value="{{.Request.Scope}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+<input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+<label for="username">Username</label>
+<input id="username" name="username" autocomplete="username" value="{{.Username}}" required>
+<label for="password">Password</label>
+<input id="password" name="password" type="password" autocomplete="current-password" required>
+<label for="code">Two-factor code, if enabled</label>
+<input id="code" name="code" autocomplete="one-time-code">
+<div class="actions">
+<button name="decision" value="allow">Allow</button>
+<button name="decision" value="deny" formnovalidate>Deny</button>
+</div>
+</form>
+</body>
+</html>
+`))
+
+// deviceCodePage asks for the user code shown on the device
+var deviceCodePage = template.Must(template.New("device-code").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Connect a device</title>
+<style>
+body { font-family: sans-serif; max-width: 24rem; margin: 4rem auto; padding: 0 1rem; }
+label, input, button { display: block; width: 100%; box-sizing: border-box; margin-bottom: .75rem; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<h1>Connect a device</h1>
+<p>Enter the code shown on your device.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="get" action="/device">
+<label for="user_code">Code</label>
+<input id="user_code" name="user_code" autocomplete="off" autocapitalize="characters" placeholder="XXXX-XXXX" required>
+<button>Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// deviceApprovePage lets the user sign in, unless signed in already, and
+// approve or deny the device. The user code travels in a hidden field.
+var deviceApprovePage = template.Must(template.New("device-approve").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in to {{.Client.Name}}</title>
+<style>
+body { font-family: sans-serif; max-width: 24rem; margin: 4rem auto; padding: 0 1rem; }
+label, input, button { display: block; width: 100%; box-sizing: border-box; margin-bottom: .75rem; }
+.error { color: #b00020; }
+.actions { display: flex; gap: .5rem; }
+</style>
+</head>
+<body>
+<h1>Sign in</h1>
+<p><strong>{{.Client.Name}}</strong> on a device wants to access your account. Only continue if you started this on your device.</p>
+{{if .Scopes}}<p>It asks for permission to:</p>
+<ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="/device">
+<input type="hidden" name="user_code" value="{{.UserCode}}">
+{{if .User}}<p>Signed in as <strong>{{.User.Username}}</strong>.</p>
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+{{else}}<label for="username">Username</label>
+<input id="username" name="username" autocomplete="username" value="{{.Username}}" required>
+<label for="password">Password</label>
+<input id="password" name="password" type="password" autocomplete="current-password" required>
+<label for="code">Two-factor code, if enabled</label>
+<input id="code" name="code" autocomplete="one-time-code">
+{{end}}<div class="actions">
+<button name="decision" value="allow">Allow</button>
+<button name="decision" value="deny">Deny</button>
+</div>
+</form>
+</body>
+</html>
+`))
+
+// deviceDonePage tells the user the outcome, the device learns it by
+// polling
+var deviceDonePage = template.Must(template.New("device-done").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Connect a device</title>
+</head>
+<body>
+<h1>Connect a device</h1>
+<p>{{.}}</p>
+</body>
+</html>
+`))
+
+// errorPage is shown when the client or redirect URI is invalid, so the
+// user can't be sent back to the client
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Authorization failed</title>
+</head>
+<body>
+<h1>Authorization failed</h1>
+<p>{{.}}</p>
+</body>
+</html>
+`))
+
+type authorizePageData struct {
+	Client   *authEntity.OAuthClient
+	Scopes   []string
+	Request  *authEntity.AuthorizationRequest
+	Username string
+	Error    string
+}
+
+type devicePageData struct {
+	UserCode  string
+	Client    *authEntity.OAuthClient
+	Scopes    []string
+	User      *entity.User
+	CSRFToken string
+	Username  string
+	Error     string
+}
+
+// setSession fills in the user of the cookie session, whose form posts
+// carry the CSRF token of the session
+func (p *devicePageData) setSession(r *http.Request) {
+	p.User = middleware.UserFromContext(r.Context())
+	if cookie, err := r.Cookie(middleware.CSRFTokenCookie); err == nil {
+		p.CSRFToken = cookie.Value
+	}
+}
+
+// renderPage writes an HTML page that must not be framed or cached
+func renderPage(w http.ResponseWriter, code int, page *template.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	w.WriteHeader(code)
+	page.Execute(w, data)
+}
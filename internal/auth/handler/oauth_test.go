@@ -0,0 +1,599 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/auth/service"
+	"go-authentication-exercise/internal/middleware"
+	"go-authentication-exercise/internal/user/entity"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockOAuthService is a mock implementation of the OAuthService interface
+type MockOAuthService struct {
+	mock.Mock
+}
+
+func (m *MockOAuthService) RegisterClient(ctx context.Context, name string, redirectURIs []string, scopes []string, confidential bool) (*authEntity.NewOAuthClient, error) {
+	args := m.Called(ctx, name, redirectURIs, scopes, confidential)
+	client, _ := args.Get(0).(*authEntity.NewOAuthClient)
+	return client, args.Error(1)
+}
+
+func (m *MockOAuthService) ListClients(ctx context.Context) ([]*authEntity.OAuthClient, error) {
+	args := m.Called(ctx)
+	clients, _ := args.Get(0).([]*authEntity.OAuthClient)
+	return clients, args.Error(1)
+}
+
+func (m *MockOAuthService) DeleteClient(ctx context.Context, clientId string) error {
+	return m.Called(ctx, clientId).Error(0)
+}
+
+func (m *MockOAuthService) CheckAuthorization(ctx context.Context, req *authEntity.AuthorizationRequest) (*authEntity.OAuthClient, []string, error) {
+	args := m.Called(ctx, req)
+	client, _ := args.Get(0).(*authEntity.OAuthClient)
+	scopes, _ := args.Get(1).([]string)
+	return client, scopes, args.Error(2)
+}
+
+func (m *MockOAuthService) Authorize(ctx context.Context, req *authEntity.AuthorizationRequest, user *entity.User) (string, error) {
+	args := m.Called(ctx, req, user)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockOAuthService) ExchangeCode(ctx context.Context, clientId string, clientSecret string, code string, redirectURI string, codeVerifier string, client authEntity.Client) (*authEntity.TokenPair, error) {
+	args := m.Called(ctx, clientId, clientSecret, code, redirectURI, codeVerifier, client)
+	tokens, _ := args.Get(0).(*authEntity.TokenPair)
+	return tokens, args.Error(1)
+}
+
+func (m *MockOAuthService) RefreshToken(ctx context.Context, clientId string, clientSecret string, refreshToken string) (*authEntity.TokenPair, error) {
+	args := m.Called(ctx, clientId, clientSecret, refreshToken)
+	tokens, _ := args.Get(0).(*authEntity.TokenPair)
+	return tokens, args.Error(1)
+}
+
+func (m *MockOAuthService) ClientCredentials(ctx context.Context, clientId string, clientSecret string, scope string) (*authEntity.TokenPair, error) {
+	args := m.Called(ctx, clientId, clientSecret, scope)
+	tokens, _ := args.Get(0).(*authEntity.TokenPair)
+	return tokens, args.Error(1)
+}
+
+func (m *MockOAuthService) RequestDeviceCode(ctx context.Context, clientId string, clientSecret string, scope string) (*authEntity.DeviceAuthorization, error) {
+	args := m.Called(ctx, clientId, clientSecret, scope)
+	authorization, _ := args.Get(0).(*authEntity.DeviceAuthorization)
+	return authorization, args.Error(1)
+}
+
+func (m *MockOAuthService) CheckUserCode(ctx context.Context, userCode string) (*authEntity.OAuthClient, []string, error) {
+	args := m.Called(ctx, userCode)
+	client, _ := args.Get(0).(*authEntity.OAuthClient)
+	scopes, _ := args.Get(1).([]string)
+	return client, scopes, args.Error(2)
+}
+
+func (m *MockOAuthService) DecideDevice(ctx context.Context, userCode string, user *entity.User, approve bool) error {
+	return m.Called(ctx, userCode, user, approve).Error(0)
+}
+
+func (m *MockOAuthService) ExchangeDeviceCode(ctx context.Context, clientId string, clientSecret string, deviceCode string, client authEntity.Client) (*authEntity.TokenPair, error) {
+	args := m.Called(ctx, clientId, clientSecret, deviceCode, client)
+	tokens, _ := args.Get(0).(*authEntity.TokenPair)
+	return tokens, args.Error(1)
+}
+
+func (m *MockOAuthService) Introspect(ctx context.Context, clientId string, clientSecret string, token string) (*authEntity.Introspection, error) {
+	args := m.Called(ctx, clientId, clientSecret, token)
+	info, _ := args.Get(0).(*authEntity.Introspection)
+	return info, args.Error(1)
+}
+
+func (m *MockOAuthService) Revoke(ctx context.Context, clientId string, clientSecret string, token string) error {
+	return m.Called(ctx, clientId, clientSecret, token).Error(0)
+}
+
+func (m *MockOAuthService) OpenIDConfiguration() (*authEntity.OpenIDConfiguration, error) {
+	args := m.Called()
+	config, _ := args.Get(0).(*authEntity.OpenIDConfiguration)
+	return config, args.Error(1)
+}
+
+func (m *MockOAuthService) UserInfo(ctx context.Context, userId uuid.UUID, scopes []string) (*authEntity.UserInfo, error) {
+	args := m.Called(ctx, userId, scopes)
+	info, _ := args.Get(0).(*authEntity.UserInfo)
+	return info, args.Error(1)
+}
+
+func TestAuthorize(t *testing.T) {
+	client := &authEntity.OAuthClient{Id: "spa", Name: "Single page app"}
+
+	tests := []struct {
+		name               string
+		setupMock          func(*MockOAuthService)
+		expectedStatusCode int
+		expectedLocation   string
+		expectedBody       string
+	}{
+		{
+			name: "Valid request shows the consent page",
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("CheckAuthorization", mock.Anything, mock.Anything).
+					Return(client, []string{"users:read"}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Single page app",
+		},
+		{
+			name: "Invalid request is redirected with the state",
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("CheckAuthorization", mock.Anything, mock.Anything).
+					Return(nil, nil, &service.OAuthError{Code: "invalid_scope", Description: "bad scope"})
+			},
+			expectedStatusCode: http.StatusFound,
+			expectedLocation:   "https://app.example.com/callback?error=invalid_scope&error_description=bad+scope&state=xyz",
+		},
+		{
+			name: "Unknown client is not redirected",
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("CheckAuthorization", mock.Anything, mock.Anything).
+					Return(nil, nil, service.ErrOAuthClientNotFound)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       service.ErrOAuthClientNotFound.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockOAuthService)
+			tt.setupMock(mockService)
+
+			h := NewOAuthHandler(mockService, new(MockAuthService))
+
+			query := url.Values{
+				"response_type": {"code"},
+				"client_id":     {"spa"},
+				"redirect_uri":  {"https://app.example.com/callback"},
+				"state":         {"xyz"},
+			}
+			req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+			rr := httptest.NewRecorder()
+
+			h.Authorize(rr, req)
+
+			assert.Equal(t, tt.expectedStatusCode, rr.Code)
+			assert.Equal(t, tt.expectedLocation, rr.Header().Get("Location"))
+			assert.Contains(t, rr.Body.String(), tt.expectedBody)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestToken(t *testing.T) {
+	tests := []struct {
+		name               string
+		form               url.Values
+		basicAuth          []string
+		setupMock          func(*MockOAuthService)
+		expectedStatusCode int
+		expectedError      string
+	}{
+		{
+			name: "Authorization code",
+			form: url.Values{
+				"grant_type":    {"authorization_code"},
+				"client_id":     {"spa"},
+				"code":          {"code"},
+				"redirect_uri":  {"https://app.example.com/callback"},
+				"code_verifier": {"verifier"},
+			},
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("ExchangeCode", mock.Anything, "spa", "", "code", "https://app.example.com/callback", "verifier", testClient).
+					Return(&authEntity.TokenPair{AccessToken: "access-token", TokenType: "Bearer", Scope: "users:read"}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "Unknown client",
+			form: url.Values{"grant_type": {"refresh_token"}, "client_id": {"unknown"}, "refresh_token": {"refresh-token"}},
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("RefreshToken", mock.Anything, "unknown", "", "refresh-token").
+					Return(nil, &service.OAuthError{Code: "invalid_client", Description: "unknown client"})
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedError:      "invalid_client",
+		},
+		{
+			name:      "Client credentials with Basic authentication",
+			form:      url.Values{"grant_type": {"client_credentials"}, "scope": {"users:read"}},
+			basicAuth: []string{"worker", "s3cret%2Fvalue"},
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("ClientCredentials", mock.Anything, "worker", "s3cret/value", "users:read").
+					Return(&authEntity.TokenPair{AccessToken: "access-token", TokenType: "Bearer", Scope: "users:read"}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "Client credentials with a wrong secret",
+			form: url.Values{"grant_type": {"client_credentials"}, "client_id": {"worker"}, "client_secret": {"wrong"}},
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("ClientCredentials", mock.Anything, "worker", "wrong", "").
+					Return(nil, &service.OAuthError{Code: "invalid_client", Description: "invalid client secret"})
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedError:      "invalid_client",
+		},
+		{
+			name: "Device code not approved yet",
+			form: url.Values{"grant_type": {service.GrantTypeDeviceCode}, "client_id": {"cli"}, "device_code": {"device-code"}},
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("ExchangeDeviceCode", mock.Anything, "cli", "", "device-code", testClient).
+					Return(nil, &service.OAuthError{Code: "authorization_pending", Description: "pending"})
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedError:      "authorization_pending",
+		},
+		{
+			name: "Approved device code",
+			form: url.Values{"grant_type": {service.GrantTypeDeviceCode}, "client_id": {"cli"}, "device_code": {"device-code"}},
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("ExchangeDeviceCode", mock.Anything, "cli", "", "device-code", testClient).
+					Return(&authEntity.TokenPair{AccessToken: "access-token", TokenType: "Bearer", Scope: "users:read"}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Unsupported grant type",
+			form:               url.Values{"grant_type": {"password"}},
+			setupMock:          func(mockService *MockOAuthService) {},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedError:      "unsupported_grant_type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockOAuthService)
+			tt.setupMock(mockService)
+
+			h := NewOAuthHandler(mockService, new(MockAuthService))
+
+			req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.basicAuth != nil {
+				req.SetBasicAuth(tt.basicAuth[0], tt.basicAuth[1])
+			}
+			rr := httptest.NewRecorder()
+
+			h.Token(rr, req)
+
+			assert.Equal(t, tt.expectedStatusCode, rr.Code)
+			assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			if tt.expectedError != "" {
+				assert.Equal(t, tt.expectedError, response["error"])
+			} else {
+				assert.Equal(t, "access-token", response["access_token"])
+				assert.Equal(t, "users:read", response["scope"])
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserInfo(t *testing.T) {
+	user := &entity.User{Id: uuid.New()}
+
+	tests := []struct {
+		name               string
+		claims             jwt.MapClaims
+		setupMock          func(*MockOAuthService)
+		expectedStatusCode int
+		expectedChallenge  string
+	}{
+		{
+			name:   "Token with openid scope",
+			claims: jwt.MapClaims{"scope": "openid profile"},
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("UserInfo", mock.Anything, user.Id, []string{"openid", "profile"}).
+					Return(&authEntity.UserInfo{Subject: user.Id.String()}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "First-party token",
+			claims: jwt.MapClaims{},
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("UserInfo", mock.Anything, user.Id, []string{}).
+					Return(nil, &service.OAuthError{Code: "insufficient_scope", Description: "the openid scope is required"})
+			},
+			expectedStatusCode: http.StatusForbidden,
+			expectedChallenge:  `Bearer error="insufficient_scope", error_description="the openid scope is required"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockOAuthService)
+			tt.setupMock(mockService)
+
+			h := NewOAuthHandler(mockService, new(MockAuthService))
+
+			req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+			ctx := middleware.WithClaims(middleware.WithUser(req.Context(), user), tt.claims)
+			rr := httptest.NewRecorder()
+
+			h.UserInfo(rr, req.WithContext(ctx))
+
+			assert.Equal(t, tt.expectedStatusCode, rr.Code)
+			assert.Equal(t, tt.expectedChallenge, rr.Header().Get("WWW-Authenticate"))
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDecideDevice(t *testing.T) {
+	client := &authEntity.OAuthClient{Id: "cli", Name: "Command line tool"}
+	user := &entity.User{Id: uuid.New(), Username: "testuser"}
+
+	tests := []struct {
+		name               string
+		form               url.Values
+		sessionUser        *entity.User
+		setupMock          func(*MockOAuthService, *MockAuthService)
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name: "Approved after signing in",
+			form: url.Values{"user_code": {"WDJB-MJHT"}, "decision": {"allow"}, "username": {"testuser"}, "password": {"password123"}},
+			setupMock: func(mockService *MockOAuthService, authService *MockAuthService) {
+				mockService.On("CheckUserCode", mock.Anything, "WDJB-MJHT").Return(client, []string{"users:read"}, nil)
+				authService.On("Authenticate", mock.Anything, "testuser", "password123", "", testClient).Return(user, nil)
+				mockService.On("DecideDevice", mock.Anything, "WDJB-MJHT", user, true).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Access granted",
+		},
+		{
+			name: "Wrong password shows the form again",
+			form: url.Values{"user_code": {"WDJB-MJHT"}, "decision": {"allow"}, "username": {"testuser"}, "password": {"wrong"}},
+			setupMock: func(mockService *MockOAuthService, authService *MockAuthService) {
+				mockService.On("CheckUserCode", mock.Anything, "WDJB-MJHT").Return(client, []string{"users:read"}, nil)
+				authService.On("Authenticate", mock.Anything, "testuser", "wrong", "", testClient).
+					Return(nil, service.ErrInvalidCredentials)
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedBody:       "Command line tool",
+		},
+		{
+			name: "Anonymous deny is rejected",
+			form: url.Values{"user_code": {"WDJB-MJHT"}, "decision": {"deny"}},
+			setupMock: func(mockService *MockOAuthService, authService *MockAuthService) {
+				mockService.On("CheckUserCode", mock.Anything, "WDJB-MJHT").Return(client, []string{"users:read"}, nil)
+				authService.On("Authenticate", mock.Anything, "", "", "", testClient).
+					Return(nil, service.ErrInvalidCredentials)
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedBody:       "Username",
+		},
+		{
+			name: "Denied after signing in",
+			form: url.Values{"user_code": {"WDJB-MJHT"}, "decision": {"deny"}, "username": {"testuser"}, "password": {"password123"}},
+			setupMock: func(mockService *MockOAuthService, authService *MockAuthService) {
+				mockService.On("CheckUserCode", mock.Anything, "WDJB-MJHT").Return(client, []string{"users:read"}, nil)
+				authService.On("Authenticate", mock.Anything, "testuser", "password123", "", testClient).Return(user, nil)
+				mockService.On("DecideDevice", mock.Anything, "WDJB-MJHT", user, false).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Access denied",
+		},
+		{
+			name:        "Approved by the user of the cookie session",
+			form:        url.Values{"user_code": {"WDJB-MJHT"}, "decision": {"allow"}},
+			sessionUser: user,
+			setupMock: func(mockService *MockOAuthService, authService *MockAuthService) {
+				mockService.On("CheckUserCode", mock.Anything, "WDJB-MJHT").Return(client, []string{"users:read"}, nil)
+				mockService.On("DecideDevice", mock.Anything, "WDJB-MJHT", user, true).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Access granted",
+		},
+		{
+			name:        "Denied by the user of the cookie session",
+			form:        url.Values{"user_code": {"WDJB-MJHT"}, "decision": {"deny"}},
+			sessionUser: user,
+			setupMock: func(mockService *MockOAuthService, authService *MockAuthService) {
+				mockService.On("CheckUserCode", mock.Anything, "WDJB-MJHT").Return(client, []string{"users:read"}, nil)
+				mockService.On("DecideDevice", mock.Anything, "WDJB-MJHT", user, false).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Access denied",
+		},
+		{
+			name: "Unknown code asks for it again",
+			form: url.Values{"user_code": {"BCDF-GHJK"}, "decision": {"allow"}},
+			setupMock: func(mockService *MockOAuthService, authService *MockAuthService) {
+				mockService.On("CheckUserCode", mock.Anything, "BCDF-GHJK").Return(nil, nil, service.ErrInvalidUserCode)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       "Enter the code shown on your device",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockOAuthService)
+			authService := new(MockAuthService)
+			tt.setupMock(mockService, authService)
+
+			h := NewOAuthHandler(mockService, authService)
+
+			req := httptest.NewRequest(http.MethodPost, "/device", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.sessionUser != nil {
+				req = req.WithContext(middleware.WithUser(req.Context(), tt.sessionUser))
+			}
+			rr := httptest.NewRecorder()
+
+			h.DecideDevice(rr, req)
+
+			assert.Equal(t, tt.expectedStatusCode, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.expectedBody)
+			mockService.AssertExpectations(t)
+			authService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDevice(t *testing.T) {
+	client := &authEntity.OAuthClient{Id: "cli", Name: "Command line tool"}
+	user := &entity.User{Id: uuid.New(), Username: "testuser"}
+
+	mockService := new(MockOAuthService)
+	mockService.On("CheckUserCode", mock.Anything, "WDJB-MJHT").Return(client, []string{"users:read"}, nil)
+	h := NewOAuthHandler(mockService, new(MockAuthService))
+
+	// without a session the page asks for credentials
+	rr := httptest.NewRecorder()
+	h.Device(rr, httptest.NewRequest(http.MethodGet, "/device?user_code=WDJB-MJHT", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `name="password"`)
+
+	// the user of the cookie session only decides, the form carries the
+	// CSRF token of the session
+	req := httptest.NewRequest(http.MethodGet, "/device?user_code=WDJB-MJHT", nil)
+	req.AddCookie(&http.Cookie{Name: middleware.CSRFTokenCookie, Value: "csrf"})
+	rr = httptest.NewRecorder()
+	h.Device(rr, req.WithContext(middleware.WithUser(req.Context(), user)))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Signed in as <strong>testuser</strong>")
+	assert.Contains(t, rr.Body.String(), `name="csrf_token" value="csrf"`)
+	assert.NotContains(t, rr.Body.String(), `name="password"`)
+}
+
+func TestIntrospect(t *testing.T) {
+	tests := []struct {
+		name               string
+		form               url.Values
+		setupMock          func(*MockOAuthService)
+		expectedStatusCode int
+		expectedBody       map[string]interface{}
+	}{
+		{
+			name: "Active token",
+			form: url.Values{"client_id": {"gateway"}, "client_secret": {"s3cret"}, "token": {"access-token"}},
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("Introspect", mock.Anything, "gateway", "s3cret", "access-token").
+					Return(&authEntity.Introspection{Active: true, Subject: "user-id", Scope: "users:read", ExpiresAt: 1700000000}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       map[string]interface{}{"active": true, "sub": "user-id", "scope": "users:read", "exp": float64(1700000000)},
+		},
+		{
+			name: "Inactive token",
+			form: url.Values{"client_id": {"gateway"}, "client_secret": {"s3cret"}, "token": {"expired"}},
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("Introspect", mock.Anything, "gateway", "s3cret", "expired").
+					Return(&authEntity.Introspection{Active: false}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       map[string]interface{}{"active": false},
+		},
+		{
+			name: "Public client",
+			form: url.Values{"client_id": {"spa"}, "token": {"access-token"}},
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("Introspect", mock.Anything, "spa", "", "access-token").
+					Return(nil, &service.OAuthError{Code: "invalid_client", Description: "confidential clients only"})
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedBody:       map[string]interface{}{"error": "invalid_client", "error_description": "confidential clients only"},
+		},
+		{
+			name: "Client over its limit",
+			form: url.Values{"client_id": {"gateway"}, "client_secret": {"s3cret"}, "token": {"access-token"}},
+			setupMock: func(mockService *MockOAuthService) {
+				mockService.On("Introspect", mock.Anything, "gateway", "s3cret", "access-token").
+					Return(nil, &service.RetryAfterError{Err: service.ErrTooManyIntrospections, RetryAfter: 1500 * time.Millisecond})
+			},
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectedBody:       map[string]interface{}{"data": nil, "message": "too many introspection requests"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockOAuthService)
+			tt.setupMock(mockService)
+
+			h := NewOAuthHandler(mockService, new(MockAuthService))
+
+			req := httptest.NewRequest(http.MethodPost, "/oauth/introspect", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rr := httptest.NewRecorder()
+
+			h.Introspect(rr, req)
+
+			assert.Equal(t, tt.expectedStatusCode, rr.Code)
+			assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedBody, response)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	tests := []struct {
+		name               string
+		err                error
+		expectedStatusCode int
+	}{
+		{
+			name:               "Revoked or unknown token",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "API key",
+			err:                &service.OAuthError{Code: "unsupported_token_type", Description: "API keys can only be revoked by their owner"},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockOAuthService)
+			mockService.On("Revoke", mock.Anything, "spa", "", "refresh-token").Return(tt.err)
+
+			h := NewOAuthHandler(mockService, new(MockAuthService))
+
+			form := url.Values{"client_id": {"spa"}, "token": {"refresh-token"}, "token_type_hint": {"refresh_token"}}
+			req := httptest.NewRequest(http.MethodPost, "/oauth/revoke", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rr := httptest.NewRecorder()
+
+			h.Revoke(rr, req)
+
+			assert.Equal(t, tt.expectedStatusCode, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"go-authentication-exercise/internal/auth/service"
+	"go-authentication-exercise/internal/middleware"
+	"go-authentication-exercise/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// ListSessions returns the active sessions of the authenticated user, the
+// one making the request is marked as current
+func (h *authHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := middleware.UserFromContext(ctx)
+	if user == nil {
+		util.Error(w, http.StatusUnauthorized, nil, "Unauthorized")
+		return
+	}
+
+	sessions, err := h.service.ListSessions(ctx, user.Id)
+	if err != nil {
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	current := middleware.SessionIdFromClaims(middleware.ClaimsFromContext(ctx))
+	for _, session := range sessions {
+		session.Current = current != uuid.Nil && session.Id == current
+	}
+
+	util.Success(w, http.StatusOK, sessions, "")
+}
+
+// RevokeSession signs the authenticated user out of the session in the path
+func (h *authHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := middleware.UserFromContext(ctx)
+	if user == nil {
+		util.Error(w, http.StatusUnauthorized, nil, "Unauthorized")
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		util.Error(w, http.StatusNotFound, nil, service.ErrSessionNotFound.Error())
+		return
+	}
+
+	if err := h.service.RevokeSession(ctx, user.Id, id); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			util.Error(w, http.StatusNotFound, nil, err.Error())
+			return
+		}
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, nil, "Session revoked")
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go-authentication-exercise/internal/auth/entity"
+
+	"github.com/lib/pq"
+)
+
+const authorizationCodeColumns = "id, client_id, user_id, code_hash, redirect_uri, scopes, code_challenge, nonce, auth_time, expires_at, used_at, created_at"
+
+type authorizationCodeRepository struct {
+	db *sql.DB
+}
+
+func NewAuthorizationCodeRepository(db *sql.DB) AuthorizationCodeRepository {
+	return &authorizationCodeRepository{
+		db: db,
+	}
+}
+
+func (r *authorizationCodeRepository) Create(ctx context.Context, m *entity.AuthorizationCode) (*entity.AuthorizationCode, error) {
+	query := `INSERT INTO oauth_authorization_codes (id, client_id, user_id, code_hash, redirect_uri, scopes, code_challenge, nonce, auth_time, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING ` + authorizationCodeColumns
+
+	row := r.db.QueryRowContext(ctx, query,
+		m.Id, m.ClientId, m.UserId, m.CodeHash, m.RedirectURI, pq.Array(m.Scopes), m.CodeChallenge, m.Nonce, m.AuthTime, m.ExpiresAt)
+
+	if err := scanAuthorizationCode(row, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Consume marks the code as used and returns it. Unknown, expired and
+// already used codes return nil, so a code can only be exchanged once.
+func (r *authorizationCodeRepository) Consume(ctx context.Context, codeHash string) (*entity.AuthorizationCode, error) {
+	query := `UPDATE oauth_authorization_codes SET used_at = CURRENT_TIMESTAMP
+			WHERE code_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+			RETURNING ` + authorizationCodeColumns
+
+	row := r.db.QueryRowContext(ctx, query, codeHash)
+
+	code := entity.AuthorizationCode{}
+	if err := scanAuthorizationCode(row, &code); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // no record
+		}
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+func scanAuthorizationCode(row rowScanner, m *entity.AuthorizationCode) error {
+	return row.Scan(
+		&m.Id,
+		&m.ClientId,
+		&m.UserId,
+		&m.CodeHash,
+		&m.RedirectURI,
+		pq.Array(&m.Scopes),
+		&m.CodeChallenge,
+		&m.Nonce,
+		&m.AuthTime,
+		&m.ExpiresAt,
+		&m.UsedAt,
+		&m.CreatedAt)
+}
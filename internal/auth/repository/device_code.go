@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-authentication-exercise/internal/auth/entity"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const deviceCodeColumns = `id, client_id, device_code_hash, user_code_hash, scopes, "interval", expires_at,
+	last_polled_at, user_id, approved_at, denied_at, consumed_at, created_at`
+
+type deviceCodeRepository struct {
+	db *sql.DB
+}
+
+func NewDeviceCodeRepository(db *sql.DB) DeviceCodeRepository {
+	return &deviceCodeRepository{
+		db: db,
+	}
+}
+
+func (r *deviceCodeRepository) Create(ctx context.Context, m *entity.DeviceCode) (*entity.DeviceCode, error) {
+	query := `INSERT INTO oauth_device_codes (id, client_id, device_code_hash, user_code_hash, scopes, "interval", expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING ` + deviceCodeColumns
+
+	row := r.db.QueryRowContext(ctx, query,
+		m.Id, m.ClientId, m.DeviceCodeHash, m.UserCodeHash, pq.Array(m.Scopes), m.Interval, m.ExpiresAt)
+
+	if err := scanDeviceCode(row, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (r *deviceCodeRepository) FindOneByDeviceCodeHash(ctx context.Context, deviceCodeHash string) (*entity.DeviceCode, error) {
+	query := "SELECT " + deviceCodeColumns + " FROM oauth_device_codes WHERE device_code_hash = $1"
+
+	return r.findOne(ctx, query, deviceCodeHash)
+}
+
+// FindPendingByUserCodeHash returns the unexpired code with the user code
+// that was neither approved nor denied yet
+func (r *deviceCodeRepository) FindPendingByUserCodeHash(ctx context.Context, userCodeHash string) (*entity.DeviceCode, error) {
+	query := "SELECT " + deviceCodeColumns + ` FROM oauth_device_codes
+			WHERE user_code_hash = $1 AND approved_at IS NULL AND denied_at IS NULL
+				AND expires_at > CURRENT_TIMESTAMP`
+
+	return r.findOne(ctx, query, userCodeHash)
+}
+
+// RecordPoll stores the time of a poll and the interval for the next one
+func (r *deviceCodeRepository) RecordPoll(ctx context.Context, id uuid.UUID, at time.Time, interval int) error {
+	query := `UPDATE oauth_device_codes SET last_polled_at = $2, "interval" = $3 WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, at, interval)
+	return err
+}
+
+// Approve grants the pending code to the user, it reports false if the
+// code was decided on or expired meanwhile
+func (r *deviceCodeRepository) Approve(ctx context.Context, id uuid.UUID, userId uuid.UUID) (bool, error) {
+	query := `UPDATE oauth_device_codes SET user_id = $2, approved_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND approved_at IS NULL AND denied_at IS NULL AND expires_at > CURRENT_TIMESTAMP`
+
+	return r.exec(ctx, query, id, userId)
+}
+
+// Deny rejects the pending code
+func (r *deviceCodeRepository) Deny(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `UPDATE oauth_device_codes SET denied_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND approved_at IS NULL AND denied_at IS NULL AND expires_at > CURRENT_TIMESTAMP`
+
+	return r.exec(ctx, query, id)
+}
+
+// Consume marks an approved code as exchanged, so it only yields tokens
+// once
+func (r *deviceCodeRepository) Consume(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `UPDATE oauth_device_codes SET consumed_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND approved_at IS NOT NULL AND consumed_at IS NULL`
+
+	return r.exec(ctx, query, id)
+}
+
+func (r *deviceCodeRepository) findOne(ctx context.Context, query string, args ...interface{}) (*entity.DeviceCode, error) {
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	code := entity.DeviceCode{}
+	if err := scanDeviceCode(row, &code); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // no record
+		}
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+func (r *deviceCodeRepository) exec(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func scanDeviceCode(row rowScanner, m *entity.DeviceCode) error {
+	return row.Scan(
+		&m.Id,
+		&m.ClientId,
+		&m.DeviceCodeHash,
+		&m.UserCodeHash,
+		pq.Array(&m.Scopes),
+		&m.Interval,
+		&m.ExpiresAt,
+		&m.LastPolledAt,
+		&m.UserId,
+		&m.ApprovedAt,
+		&m.DeniedAt,
+		&m.ConsumedAt,
+		&m.CreatedAt)
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go-authentication-exercise/internal/auth/entity"
+
+	"github.com/google/uuid"
+)
+
+type emailVerificationTokenRepository struct {
+	db *sql.DB
+}
+
+func NewEmailVerificationTokenRepository(db *sql.DB) EmailVerificationTokenRepository {
+	return &emailVerificationTokenRepository{
+		db: db,
+	}
+}
+
+func (r *emailVerificationTokenRepository) Create(ctx context.Context, m *entity.EmailVerificationToken) (*entity.EmailVerificationToken, error) {
+	sql := `INSERT INTO email_verification_tokens (id, user_id, email, token_hash, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, user_id, email, token_hash, expires_at, used_at, created_at`
+
+	row := r.db.QueryRowContext(ctx, sql, m.Id, m.UserId, m.Email, m.TokenHash, m.ExpiresAt)
+
+	if err := scanEmailVerificationToken(row, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Consume marks the token as used and returns it. Unknown, expired and
+// already used tokens return nil, so a token can only be consumed once.
+func (r *emailVerificationTokenRepository) Consume(ctx context.Context, tokenHash string) (*entity.EmailVerificationToken, error) {
+	query := `UPDATE email_verification_tokens SET used_at = CURRENT_TIMESTAMP
+			WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+			RETURNING id, user_id, email, token_hash, expires_at, used_at, created_at`
+
+	row := r.db.QueryRowContext(ctx, query, tokenHash)
+
+	token := entity.EmailVerificationToken{}
+	if err := scanEmailVerificationToken(row, &token); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // no record
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// InvalidateAllForUser marks every outstanding token of the user as used
+func (r *emailVerificationTokenRepository) InvalidateAllForUser(ctx context.Context, userId uuid.UUID) error {
+	sql := `UPDATE email_verification_tokens SET used_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1 AND used_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, sql, userId)
+	return err
+}
+
+func scanEmailVerificationToken(row *sql.Row, m *entity.EmailVerificationToken) error {
+	return row.Scan(
+		&m.Id,
+		&m.UserId,
+		&m.Email,
+		&m.TokenHash,
+		&m.ExpiresAt,
+		&m.UsedAt,
+		&m.CreatedAt)
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-authentication-exercise/internal/auth/entity"
+
+	"github.com/google/uuid"
+)
+
+type OAuthClientRepository interface {
+	Create(ctx context.Context, m *entity.OAuthClient) (*entity.OAuthClient, error)
+	FindOneById(ctx context.Context, id string) (*entity.OAuthClient, error)
+	List(ctx context.Context) ([]*entity.OAuthClient, error)
+	Delete(ctx context.Context, id string) (bool, error)
+}
+
+type AuthorizationCodeRepository interface {
+	Create(ctx context.Context, m *entity.AuthorizationCode) (*entity.AuthorizationCode, error)
+	Consume(ctx context.Context, codeHash string) (*entity.AuthorizationCode, error)
+}
+
+type DeviceCodeRepository interface {
+	Create(ctx context.Context, m *entity.DeviceCode) (*entity.DeviceCode, error)
+	FindOneByDeviceCodeHash(ctx context.Context, deviceCodeHash string) (*entity.DeviceCode, error)
+	FindPendingByUserCodeHash(ctx context.Context, userCodeHash string) (*entity.DeviceCode, error)
+	RecordPoll(ctx context.Context, id uuid.UUID, at time.Time, interval int) error
+	Approve(ctx context.Context, id uuid.UUID, userId uuid.UUID) (bool, error)
+	Deny(ctx context.Context, id uuid.UUID) (bool, error)
+	Consume(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+type SessionRepository interface {
+	Create(ctx context.Context, m *entity.Session) (*entity.Session, error)
+	FindOneById(ctx context.Context, id uuid.UUID) (*entity.Session, error)
+	ListActiveByUserId(ctx context.Context, userId uuid.UUID, seenAfter time.Time) ([]*entity.Session, error)
+	Touch(ctx context.Context, id uuid.UUID, at time.Time) error
+	Revoke(ctx context.Context, id uuid.UUID) error
+	RevokeAllForUser(ctx context.Context, userId uuid.UUID) error
+}
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, t *entity.RefreshToken) (*entity.RefreshToken, error)
+	FindOneByTokenHash(ctx context.Context, tokenHash string) (*entity.RefreshToken, error)
+	MarkUsed(ctx context.Context, id uuid.UUID) (bool, error)
+	RevokeFamily(ctx context.Context, familyId uuid.UUID) error
+	RevokeAllForUser(ctx context.Context, userId uuid.UUID) error
+}
+
+type RevokedTokenRepository interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+type PasswordResetTokenRepository interface {
+	Create(ctx context.Context, t *entity.PasswordResetToken) (*entity.PasswordResetToken, error)
+	Consume(ctx context.Context, tokenHash string) (*entity.PasswordResetToken, error)
+	InvalidateAllForUser(ctx context.Context, userId uuid.UUID) error
+}
+
+type EmailVerificationTokenRepository interface {
+	Create(ctx context.Context, t *entity.EmailVerificationToken) (*entity.EmailVerificationToken, error)
+	Consume(ctx context.Context, tokenHash string) (*entity.EmailVerificationToken, error)
+	InvalidateAllForUser(ctx context.Context, userId uuid.UUID) error
+}
+
+type TOTPCredentialRepository interface {
+	FindOneByUserId(ctx context.Context, userId uuid.UUID) (*entity.TOTPCredential, error)
+	Save(ctx context.Context, c *entity.TOTPCredential) (*entity.TOTPCredential, error)
+	Confirm(ctx context.Context, userId uuid.UUID, step int64) (bool, error)
+	UseStep(ctx context.Context, userId uuid.UUID, step int64) (bool, error)
+	Delete(ctx context.Context, userId uuid.UUID) error
+}
+
+type RecoveryCodeRepository interface {
+	ReplaceAll(ctx context.Context, userId uuid.UUID, codeHashes []string) error
+	Consume(ctx context.Context, userId uuid.UUID, codeHash string) (bool, error)
+	DeleteAllForUser(ctx context.Context, userId uuid.UUID) error
+}
+
+type MFAChallengeRepository interface {
+	Create(ctx context.Context, c *entity.MFAChallenge) (*entity.MFAChallenge, error)
+	FindOneByTokenHash(ctx context.Context, tokenHash string) (*entity.MFAChallenge, error)
+	RecordAttempt(ctx context.Context, id uuid.UUID, maxAttempts int) (bool, error)
+	MarkUsed(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+type LoginAttemptRepository interface {
+	Get(ctx context.Context, key string) (*entity.LoginAttempt, error)
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*entity.LoginAttempt, error)
+	Block(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+type SigningKeyRepository interface {
+	ListActive(ctx context.Context) ([]*entity.SigningKey, error)
+	FindOneById(ctx context.Context, id string) (*entity.SigningKey, error)
+	Create(ctx context.Context, k *entity.SigningKey) (*entity.SigningKey, error)
+	Rotate(ctx context.Context, next *entity.SigningKey, expiresAt time.Time) (*entity.SigningKey, error)
+}
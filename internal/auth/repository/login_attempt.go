@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-authentication-exercise/internal/auth/entity"
+)
+
+type loginAttemptRepository struct {
+	db *sql.DB
+}
+
+// NewLoginAttemptRepository stores failed logins in Postgres, so every
+// instance shares the counters
+func NewLoginAttemptRepository(db *sql.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{
+		db: db,
+	}
+}
+
+func (r *loginAttemptRepository) Get(ctx context.Context, key string) (*entity.LoginAttempt, error) {
+	query := `SELECT key, failures, last_failure_at, blocked_until
+			FROM login_attempts WHERE key = $1`
+
+	row := r.db.QueryRowContext(ctx, query, key)
+
+	attempt := entity.LoginAttempt{}
+	if err := scanLoginAttempt(row, &attempt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // no record
+		}
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+// RecordFailure counts a failed login. The count restarts when the last
+// failure is older than window. Stale entries are cleaned up here.
+func (r *loginAttemptRepository) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*entity.LoginAttempt, error) {
+	query := `INSERT INTO login_attempts (key, failures, last_failure_at)
+			VALUES ($1, 1, $2)
+			ON CONFLICT (key) DO UPDATE
+			SET failures = CASE WHEN login_attempts.last_failure_at < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+				last_failure_at = EXCLUDED.last_failure_at
+			RETURNING key, failures, last_failure_at, blocked_until`
+
+	row := r.db.QueryRowContext(ctx, query, key, now, now.Add(-window))
+
+	attempt := entity.LoginAttempt{}
+	if err := scanLoginAttempt(row, &attempt); err != nil {
+		return nil, err
+	}
+
+	cleanup := `DELETE FROM login_attempts
+			WHERE last_failure_at < $1 AND (blocked_until IS NULL OR blocked_until < $2)`
+
+	if _, err := r.db.ExecContext(ctx, cleanup, now.Add(-window), now); err != nil {
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+func (r *loginAttemptRepository) Block(ctx context.Context, key string, until time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE login_attempts SET blocked_until = $2 WHERE key = $1`, key, until)
+	return err
+}
+
+func (r *loginAttemptRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE key = $1`, key)
+	return err
+}
+
+func scanLoginAttempt(row *sql.Row, m *entity.LoginAttempt) error {
+	return row.Scan(
+		&m.Key,
+		&m.Failures,
+		&m.LastFailureAt,
+		&m.BlockedUntil)
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-authentication-exercise/internal/auth/entity"
+)
+
+// memoryLoginAttemptRepository keeps failed logins in memory. Counters are
+// per instance and lost on restart, which is fine for a single instance.
+type memoryLoginAttemptRepository struct {
+	mu        sync.Mutex
+	attempts  map[string]entity.LoginAttempt
+	lastSweep time.Time
+}
+
+func NewMemoryLoginAttemptRepository() LoginAttemptRepository {
+	return &memoryLoginAttemptRepository{
+		attempts: make(map[string]entity.LoginAttempt),
+	}
+}
+
+func (r *memoryLoginAttemptRepository) Get(ctx context.Context, key string) (*entity.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+
+	return &attempt, nil
+}
+
+func (r *memoryLoginAttemptRepository) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*entity.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sweep(now, window)
+
+	attempt, ok := r.attempts[key]
+	if !ok || attempt.LastFailureAt.Before(now.Add(-window)) {
+		attempt = entity.LoginAttempt{Key: key, BlockedUntil: attempt.BlockedUntil}
+	}
+
+	attempt.Failures++
+	attempt.LastFailureAt = now
+	r.attempts[key] = attempt
+
+	return &attempt, nil
+}
+
+func (r *memoryLoginAttemptRepository) Block(ctx context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt, ok := r.attempts[key]; ok {
+		attempt.BlockedUntil = &until
+		r.attempts[key] = attempt
+	}
+
+	return nil
+}
+
+func (r *memoryLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
+
+// sweep drops entries that no longer count or block, at most once per
+// window. Callers must hold mu.
+func (r *memoryLoginAttemptRepository) sweep(now time.Time, window time.Duration) {
+	if now.Sub(r.lastSweep) < window {
+		return
+	}
+	r.lastSweep = now
+
+	for key, attempt := range r.attempts {
+		blocked := attempt.BlockedUntil != nil && attempt.BlockedUntil.After(now)
+		if !blocked && attempt.LastFailureAt.Before(now.Add(-window)) {
+			delete(r.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go-authentication-exercise/internal/auth/entity"
+
+	"github.com/google/uuid"
+)
+
+type mfaChallengeRepository struct {
+	db *sql.DB
+}
+
+func NewMFAChallengeRepository(db *sql.DB) MFAChallengeRepository {
+	return &mfaChallengeRepository{
+		db: db,
+	}
+}
+
+func (r *mfaChallengeRepository) Create(ctx context.Context, m *entity.MFAChallenge) (*entity.MFAChallenge, error) {
+	sql := `INSERT INTO mfa_challenges (id, user_id, token_hash, expires_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, user_id, token_hash, attempts, expires_at, used_at, created_at`
+
+	row := r.db.QueryRowContext(ctx, sql, m.Id, m.UserId, m.TokenHash, m.ExpiresAt)
+
+	if err := scanMFAChallenge(row, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (r *mfaChallengeRepository) FindOneByTokenHash(ctx context.Context, tokenHash string) (*entity.MFAChallenge, error) {
+	query := `SELECT id, user_id, token_hash, attempts, expires_at, used_at, created_at
+			FROM mfa_challenges WHERE token_hash = $1`
+
+	row := r.db.QueryRowContext(ctx, query, tokenHash)
+
+	challenge := entity.MFAChallenge{}
+	if err := scanMFAChallenge(row, &challenge); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // no record
+		}
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+// RecordAttempt counts a verification attempt. It reports false once
+// maxAttempts were made or the challenge was used or expired.
+func (r *mfaChallengeRepository) RecordAttempt(ctx context.Context, id uuid.UUID, maxAttempts int) (bool, error) {
+	sql := `UPDATE mfa_challenges SET attempts = attempts + 1
+			WHERE id = $1 AND attempts < $2 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP`
+
+	return updateOne(ctx, r.db, sql, id, maxAttempts)
+}
+
+// MarkUsed flags the challenge as completed. It reports false if it was
+// completed already.
+func (r *mfaChallengeRepository) MarkUsed(ctx context.Context, id uuid.UUID) (bool, error) {
+	sql := `UPDATE mfa_challenges SET used_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND used_at IS NULL`
+
+	return updateOne(ctx, r.db, sql, id)
+}
+
+func scanMFAChallenge(row *sql.Row, m *entity.MFAChallenge) error {
+	return row.Scan(
+		&m.Id,
+		&m.UserId,
+		&m.TokenHash,
+		&m.Attempts,
+		&m.ExpiresAt,
+		&m.UsedAt,
+		&m.CreatedAt)
+}
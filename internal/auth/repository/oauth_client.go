@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go-authentication-exercise/internal/auth/entity"
+
+	"github.com/lib/pq"
+)
+
+const oauthClientColumns = "id, name, redirect_uris, scopes, secret_hash, created_at"
+
+type oauthClientRepository struct {
+	db *sql.DB
+}
+
+func NewOAuthClientRepository(db *sql.DB) OAuthClientRepository {
+	return &oauthClientRepository{
+		db: db,
+	}
+}
+
+func (r *oauthClientRepository) Create(ctx context.Context, m *entity.OAuthClient) (*entity.OAuthClient, error) {
+	query := `INSERT INTO oauth_clients (id, name, redirect_uris, scopes, secret_hash)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+			RETURNING ` + oauthClientColumns
+
+	row := r.db.QueryRowContext(ctx, query, m.Id, m.Name, pq.Array(m.RedirectURIs), pq.Array(m.Scopes), m.SecretHash)
+
+	if err := scanOAuthClient(row, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (r *oauthClientRepository) FindOneById(ctx context.Context, id string) (*entity.OAuthClient, error) {
+	query := "SELECT " + oauthClientColumns + " FROM oauth_clients WHERE id = $1"
+
+	row := r.db.QueryRowContext(ctx, query, id)
+
+	client := entity.OAuthClient{}
+	if err := scanOAuthClient(row, &client); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // no record
+		}
+		return nil, err
+	}
+
+	return &client, nil
+}
+
+func (r *oauthClientRepository) List(ctx context.Context) ([]*entity.OAuthClient, error) {
+	query := "SELECT " + oauthClientColumns + " FROM oauth_clients ORDER BY name, id"
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []*entity.OAuthClient{}
+	for rows.Next() {
+		var client entity.OAuthClient
+		if err := scanOAuthClient(rows, &client); err != nil {
+			return nil, err
+		}
+
+		res = append(res, &client)
+	}
+
+	return res, rows.Err()
+}
+
+// Delete removes the client along with its codes and sessions. The refresh
+// tokens of those sessions are revoked first, so they can't be used to
+// start a session without the client's scopes.
+func (r *oauthClientRepository) Delete(ctx context.Context, id string) (bool, error) {
+	query := `WITH revoked AS (
+				UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+				WHERE family_id IN (SELECT id FROM sessions WHERE client_id = $1) AND revoked_at IS NULL
+			)
+			DELETE FROM oauth_clients WHERE id = $1`
+
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func scanOAuthClient(row rowScanner, m *entity.OAuthClient) error {
+	var secretHash sql.NullString
+	err := row.Scan(
+		&m.Id,
+		&m.Name,
+		pq.Array(&m.RedirectURIs),
+		pq.Array(&m.Scopes),
+		&secretHash,
+		&m.CreatedAt)
+	m.SecretHash = secretHash.String
+	m.Confidential = secretHash.Valid
+	return err
+}
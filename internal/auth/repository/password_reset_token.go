@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go-authentication-exercise/internal/auth/entity"
+
+	"github.com/google/uuid"
+)
+
+type passwordResetTokenRepository struct {
+	db *sql.DB
+}
+
+func NewPasswordResetTokenRepository(db *sql.DB) PasswordResetTokenRepository {
+	return &passwordResetTokenRepository{
+		db: db,
+	}
+}
+
+func (r *passwordResetTokenRepository) Create(ctx context.Context, m *entity.PasswordResetToken) (*entity.PasswordResetToken, error) {
+	sql := `INSERT INTO password_reset_tokens (id, user_id, token_hash, expires_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, user_id, token_hash, expires_at, used_at, created_at`
+
+	row := r.db.QueryRowContext(ctx, sql, m.Id, m.UserId, m.TokenHash, m.ExpiresAt)
+
+	if err := scanPasswordResetToken(row, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Consume marks the token as used and returns it. Unknown, expired and
+// already used tokens return nil, so a token can only be consumed once.
+func (r *passwordResetTokenRepository) Consume(ctx context.Context, tokenHash string) (*entity.PasswordResetToken, error) {
+	query := `UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP
+			WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+			RETURNING id, user_id, token_hash, expires_at, used_at, created_at`
+
+	row := r.db.QueryRowContext(ctx, query, tokenHash)
+
+	token := entity.PasswordResetToken{}
+	if err := scanPasswordResetToken(row, &token); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // no record
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// InvalidateAllForUser marks every outstanding token of the user as used
+func (r *passwordResetTokenRepository) InvalidateAllForUser(ctx context.Context, userId uuid.UUID) error {
+	sql := `UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1 AND used_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, sql, userId)
+	return err
+}
+
+func scanPasswordResetToken(row *sql.Row, m *entity.PasswordResetToken) error {
+	return row.Scan(
+		&m.Id,
+		&m.UserId,
+		&m.TokenHash,
+		&m.ExpiresAt,
+		&m.UsedAt,
+		&m.CreatedAt)
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+type recoveryCodeRepository struct {
+	db *sql.DB
+}
+
+func NewRecoveryCodeRepository(db *sql.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{
+		db: db,
+	}
+}
+
+// ReplaceAll discards the user's recovery codes and stores the given ones
+func (r *recoveryCodeRepository) ReplaceAll(ctx context.Context, userId uuid.UUID, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userId); err != nil {
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		query := `INSERT INTO recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)`
+
+		if _, err := tx.ExecContext(ctx, query, uuid.New(), userId, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Consume marks the code as used. It reports false for unknown and already
+// used codes.
+func (r *recoveryCodeRepository) Consume(ctx context.Context, userId uuid.UUID, codeHash string) (bool, error) {
+	query := `UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	return updateOne(ctx, r.db, query, userId, codeHash)
+}
+
+func (r *recoveryCodeRepository) DeleteAllForUser(ctx context.Context, userId uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userId)
+	return err
+}
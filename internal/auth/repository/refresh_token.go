@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go-authentication-exercise/internal/auth/entity"
+
+	"github.com/google/uuid"
+)
+
+type refreshTokenRepository struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenRepository(db *sql.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{
+		db: db,
+	}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, m *entity.RefreshToken) (*entity.RefreshToken, error) {
+	sql := `INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at`
+
+	row := r.db.QueryRowContext(ctx, sql, m.Id, m.UserId, m.FamilyId, m.TokenHash, m.ExpiresAt)
+
+	if err := scanRefreshToken(row, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (r *refreshTokenRepository) FindOneByTokenHash(ctx context.Context, tokenHash string) (*entity.RefreshToken, error) {
+	query := `SELECT id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at
+			FROM refresh_tokens WHERE token_hash = $1`
+
+	row := r.db.QueryRowContext(ctx, query, tokenHash)
+
+	token := entity.RefreshToken{}
+	if err := scanRefreshToken(row, &token); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // no record
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// MarkUsed flags the token as consumed. It reports false if the token was
+// already used or revoked, which lets callers detect concurrent replays.
+func (r *refreshTokenRepository) MarkUsed(ctx context.Context, id uuid.UUID) (bool, error) {
+	sql := `UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL`
+
+	res, err := r.db.ExecContext(ctx, sql, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyId uuid.UUID) error {
+	sql := `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+			WHERE family_id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, sql, familyId)
+	return err
+}
+
+func (r *refreshTokenRepository) RevokeAllForUser(ctx context.Context, userId uuid.UUID) error {
+	sql := `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, sql, userId)
+	return err
+}
+
+func scanRefreshToken(row *sql.Row, m *entity.RefreshToken) error {
+	return row.Scan(
+		&m.Id,
+		&m.UserId,
+		&m.FamilyId,
+		&m.TokenHash,
+		&m.ExpiresAt,
+		&m.UsedAt,
+		&m.RevokedAt,
+		&m.CreatedAt)
+}
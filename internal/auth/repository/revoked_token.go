@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type revokedTokenRepository struct {
+	db *sql.DB
+}
+
+func NewRevokedTokenRepository(db *sql.DB) RevokedTokenRepository {
+	return &revokedTokenRepository{
+		db: db,
+	}
+}
+
+// Revoke blocks the access token with the given jti until it expires.
+// Entries for tokens that have expired on their own are cleaned up here.
+func (r *revokedTokenRepository) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	sql := `INSERT INTO revoked_tokens (jti, expires_at)
+			VALUES ($1, $2)
+			ON CONFLICT (jti) DO NOTHING`
+
+	if _, err := r.db.ExecContext(ctx, sql, jti, expiresAt); err != nil {
+		return err
+	}
+
+	_, err := r.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at < CURRENT_TIMESTAMP")
+	return err
+}
+
+func (r *revokedTokenRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	sql := "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)"
+
+	var revoked bool
+	if err := r.db.QueryRowContext(ctx, sql, jti).Scan(&revoked); err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cachedRevokedTokenRepository keeps revocations in memory so the
+// middleware doesn't hit the database on every request. Revoked tokens are
+// remembered until they expire; "not revoked" answers are only trusted for
+// ttl, which bounds how long a revocation made by another instance takes
+// to be seen here.
+type cachedRevokedTokenRepository struct {
+	next RevokedTokenRepository
+	ttl  time.Duration
+
+	mu        sync.Mutex
+	revoked   map[string]time.Time
+	checked   map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewCachedRevokedTokenRepository(next RevokedTokenRepository, ttl time.Duration) RevokedTokenRepository {
+	return &cachedRevokedTokenRepository{
+		next:      next,
+		ttl:       ttl,
+		revoked:   make(map[string]time.Time),
+		checked:   make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+func (r *cachedRevokedTokenRepository) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := r.next.Revoke(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.revoked[jti] = expiresAt
+	delete(r.checked, jti)
+
+	return nil
+}
+
+func (r *cachedRevokedTokenRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	r.sweep(now)
+	if _, ok := r.revoked[jti]; ok {
+		r.mu.Unlock()
+		return true, nil
+	}
+	if until, ok := r.checked[jti]; ok && now.Before(until) {
+		r.mu.Unlock()
+		return false, nil
+	}
+	r.mu.Unlock()
+
+	revoked, err := r.next.IsRevoked(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if revoked {
+		// the expiry is unknown here, keep it for as long as a negative answer
+		r.revoked[jti] = now.Add(r.ttl)
+	} else {
+		r.checked[jti] = now.Add(r.ttl)
+	}
+
+	return revoked, nil
+}
+
+// sweep drops expired entries, at most once per ttl. Callers must hold mu.
+func (r *cachedRevokedTokenRepository) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < r.ttl {
+		return
+	}
+
+	for jti, until := range r.revoked {
+		if now.After(until) {
+			delete(r.revoked, jti)
+		}
+	}
+
+	for jti, until := range r.checked {
+		if now.After(until) {
+			delete(r.checked, jti)
+		}
+	}
+
+	r.lastSweep = now
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-authentication-exercise/internal/auth/entity"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const sessionColumns = "id, user_id, user_agent, ip, client_id, scopes, auth_time, created_at, last_seen_at, revoked_at"
+
+// sessionTouchInterval limits how often last_seen_at is written for busy
+// sessions
+const sessionTouchInterval = time.Minute
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) SessionRepository {
+	return &sessionRepository{
+		db: db,
+	}
+}
+
+func (r *sessionRepository) Create(ctx context.Context, m *entity.Session) (*entity.Session, error) {
+	query := `INSERT INTO sessions (id, user_id, user_agent, ip, client_id, scopes, auth_time)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)
+			RETURNING ` + sessionColumns
+
+	scopes := m.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	row := r.db.QueryRowContext(ctx, query, m.Id, m.UserId, m.UserAgent, m.IP, m.ClientId, pq.Array(scopes), m.AuthTime)
+
+	if err := scanSession(row, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (r *sessionRepository) FindOneById(ctx context.Context, id uuid.UUID) (*entity.Session, error) {
+	query := "SELECT " + sessionColumns + " FROM sessions WHERE id = $1"
+
+	row := r.db.QueryRowContext(ctx, query, id)
+
+	session := entity.Session{}
+	if err := scanSession(row, &session); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // no record
+		}
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// ListActiveByUserId returns the sessions of the user that aren't revoked
+// and were seen after seenAfter, most recently seen first
+func (r *sessionRepository) ListActiveByUserId(ctx context.Context, userId uuid.UUID, seenAfter time.Time) ([]*entity.Session, error) {
+	query := "SELECT " + sessionColumns + ` FROM sessions
+			WHERE user_id = $1 AND revoked_at IS NULL AND last_seen_at > $2
+			ORDER BY last_seen_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userId, seenAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []*entity.Session{}
+	for rows.Next() {
+		var session entity.Session
+		if err := scanSession(rows, &session); err != nil {
+			return nil, err
+		}
+
+		res = append(res, &session)
+	}
+
+	return res, rows.Err()
+}
+
+// Touch records that the session was seen at, at most once per
+// sessionTouchInterval
+func (r *sessionRepository) Touch(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := `UPDATE sessions SET last_seen_at = $2
+			WHERE id = $1 AND last_seen_at < $3`
+
+	_, err := r.db.ExecContext(ctx, query, id, at, at.Add(-sessionTouchInterval))
+	return err
+}
+
+func (r *sessionRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// RevokeAllForUser revokes every session of the user
+func (r *sessionRepository) RevokeAllForUser(ctx context.Context, userId uuid.UUID) error {
+	query := `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, userId)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row rowScanner, m *entity.Session) error {
+	var clientId sql.NullString
+	err := row.Scan(
+		&m.Id,
+		&m.UserId,
+		&m.UserAgent,
+		&m.IP,
+		&clientId,
+		pq.Array(&m.Scopes),
+		&m.AuthTime,
+		&m.CreatedAt,
+		&m.LastSeenAt,
+		&m.RevokedAt)
+	m.ClientId = clientId.String
+	return err
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-authentication-exercise/internal/auth/entity"
+)
+
+type signingKeyRepository struct {
+	db *sql.DB
+}
+
+func NewSigningKeyRepository(db *sql.DB) SigningKeyRepository {
+	return &signingKeyRepository{
+		db: db,
+	}
+}
+
+// ListActive returns the keys that can still verify tokens, newest first
+func (r *signingKeyRepository) ListActive(ctx context.Context) (res []*entity.SigningKey, err error) {
+	query := `SELECT id, algorithm, key_material, created_at, retired_at, expires_at
+			FROM signing_keys
+			WHERE expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP
+			ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key entity.SigningKey
+		if err := rows.Scan(
+			&key.Id,
+			&key.Algorithm,
+			&key.KeyMaterial,
+			&key.CreatedAt,
+			&key.RetiredAt,
+			&key.ExpiresAt); err != nil {
+			return nil, err
+		}
+
+		res = append(res, &key)
+	}
+
+	return res, rows.Err()
+}
+
+// FindOneById returns the key with the given id, expired keys included
+func (r *signingKeyRepository) FindOneById(ctx context.Context, id string) (*entity.SigningKey, error) {
+	query := `SELECT id, algorithm, key_material, created_at, retired_at, expires_at
+			FROM signing_keys
+			WHERE id = $1`
+
+	var key entity.SigningKey
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&key.Id,
+		&key.Algorithm,
+		&key.KeyMaterial,
+		&key.CreatedAt,
+		&key.RetiredAt,
+		&key.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (r *signingKeyRepository) Create(ctx context.Context, m *entity.SigningKey) (*entity.SigningKey, error) {
+	return insertSigningKey(ctx, r.db, m)
+}
+
+// Rotate stores next as the signing key and retires every other key,
+// letting them verify tokens until expiresAt
+func (r *signingKeyRepository) Rotate(ctx context.Context, next *entity.SigningKey, expiresAt time.Time) (*entity.SigningKey, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE signing_keys SET retired_at = CURRENT_TIMESTAMP, expires_at = $1
+			WHERE retired_at IS NULL`
+
+	if _, err := tx.ExecContext(ctx, query, expiresAt); err != nil {
+		return nil, err
+	}
+
+	res, err := insertSigningKey(ctx, tx, next)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func insertSigningKey(ctx context.Context, db queryRower, m *entity.SigningKey) (*entity.SigningKey, error) {
+	query := `INSERT INTO signing_keys (id, algorithm, key_material)
+			VALUES ($1, $2, $3)
+			RETURNING id, algorithm, key_material, created_at, retired_at, expires_at`
+
+	row := db.QueryRowContext(ctx, query, m.Id, m.Algorithm, m.KeyMaterial)
+
+	if err := row.Scan(
+		&m.Id,
+		&m.Algorithm,
+		&m.KeyMaterial,
+		&m.CreatedAt,
+		&m.RetiredAt,
+		&m.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go-authentication-exercise/internal/auth/entity"
+
+	"github.com/google/uuid"
+)
+
+type totpCredentialRepository struct {
+	db *sql.DB
+}
+
+func NewTOTPCredentialRepository(db *sql.DB) TOTPCredentialRepository {
+	return &totpCredentialRepository{
+		db: db,
+	}
+}
+
+func (r *totpCredentialRepository) FindOneByUserId(ctx context.Context, userId uuid.UUID) (*entity.TOTPCredential, error) {
+	query := `SELECT user_id, secret, confirmed_at, last_used_step, created_at
+			FROM totp_credentials WHERE user_id = $1`
+
+	row := r.db.QueryRowContext(ctx, query, userId)
+
+	credential := entity.TOTPCredential{}
+	if err := scanTOTPCredential(row, &credential); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // no record
+		}
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// Save stores an unconfirmed credential, replacing a previous one that was
+// never confirmed. A confirmed credential is left alone and sql.ErrNoRows
+// is returned.
+func (r *totpCredentialRepository) Save(ctx context.Context, m *entity.TOTPCredential) (*entity.TOTPCredential, error) {
+	sql := `INSERT INTO totp_credentials (user_id, secret)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE
+			SET secret = EXCLUDED.secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+			WHERE totp_credentials.confirmed_at IS NULL
+			RETURNING user_id, secret, confirmed_at, last_used_step, created_at`
+
+	row := r.db.QueryRowContext(ctx, sql, m.UserId, m.Secret)
+
+	if err := scanTOTPCredential(row, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Confirm enables the credential, recording step as used
+func (r *totpCredentialRepository) Confirm(ctx context.Context, userId uuid.UUID, step int64) (bool, error) {
+	sql := `UPDATE totp_credentials SET confirmed_at = CURRENT_TIMESTAMP, last_used_step = $2
+			WHERE user_id = $1 AND confirmed_at IS NULL`
+
+	return updateOne(ctx, r.db, sql, userId, step)
+}
+
+// UseStep records step as used. It reports false if the same or a later
+// step was used already, which rejects replayed codes.
+func (r *totpCredentialRepository) UseStep(ctx context.Context, userId uuid.UUID, step int64) (bool, error) {
+	sql := `UPDATE totp_credentials SET last_used_step = $2
+			WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2`
+
+	return updateOne(ctx, r.db, sql, userId, step)
+}
+
+func (r *totpCredentialRepository) Delete(ctx context.Context, userId uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM totp_credentials WHERE user_id = $1`, userId)
+	return err
+}
+
+// updateOne runs an update and reports whether it changed a row
+func updateOne(ctx context.Context, db *sql.DB, query string, args ...interface{}) (bool, error) {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func scanTOTPCredential(row *sql.Row, m *entity.TOTPCredential) error {
+	return row.Scan(
+		&m.UserId,
+		&m.Secret,
+		&m.ConfirmedAt,
+		&m.LastUsedStep,
+		&m.CreatedAt)
+}
@@ -7,6 +7,65 @@ type LoginRequest struct {
 
 type SignupRequest struct {
 	Username string `json:"username" validate:"required,min=2"`
+	Email    string `json:"email" validate:"required,email"`
 	Fullname string `json:"fullname" validate:"required"`
 	Password string `json:"password" validate:"required,min=5"`
 }
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=5"`
+}
+
+type ForgotPasswordRequest struct {
+	Username string `json:"username" validate:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required,min=5"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// LoginMFARequest completes a login challenge with either an authenticator
+// code or a recovery code
+type LoginMFARequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode   string `json:"recoveryCode"`
+}
+
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type DisableTOTPRequest struct {
+	Password     string `json:"password" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// CreateOAuthClientRequest registers a client that may redirect to the
+// given URIs and request the given permission scopes. Confidential clients
+// get a secret and may go without redirect URIs.
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	RedirectURIs []string `json:"redirectUris" validate:"omitempty,dive,required,url"`
+	Scopes       []string `json:"scopes" validate:"omitempty,dive,required"`
+	Confidential bool     `json:"confidential"`
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/user/entity"
+
+	"github.com/google/uuid"
+)
+
+// GrantTypeDeviceCode is the grant type of RFC 8628 device access token
+// requests
+const GrantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+// userCodeAlphabet has no vowels, so codes don't spell words, and no
+// characters that are easily confused
+const (
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+// slowDownStep is added to the polling interval of a device that polls too
+// fast
+const slowDownStep = 5
+
+var (
+	ErrInvalidUserCode    = errors.New("the code is invalid or has expired")
+	ErrDeviceUserRequired = errors.New("sign in to decide on the device")
+)
+
+// RequestDeviceCode starts a device authorization. The device shows the
+// user code and the verification URI, and polls the token endpoint with
+// the device code.
+func (s *oauthService) RequestDeviceCode(ctx context.Context, clientId string, clientSecret string, scope string) (*authEntity.DeviceAuthorization, error) {
+	if s.config.DeviceVerificationURI == "" {
+		return nil, oauthError("unsupported_grant_type", "device authorization is not enabled on this server")
+	}
+
+	client, err := s.authenticateClient(ctx, clientId, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, err := s.userScopes(client, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceCode, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	userCode, err := generateUserCode()
+	if err != nil {
+		return nil, err
+	}
+
+	interval := int(s.config.DeviceInterval.Seconds())
+	_, err = s.devices.Create(ctx, &authEntity.DeviceCode{
+		Id:             uuid.New(),
+		ClientId:       client.Id,
+		DeviceCodeHash: hashToken(deviceCode),
+		UserCodeHash:   hashToken(normalizeUserCode(userCode)),
+		Scopes:         scopes,
+		Interval:       interval,
+		ExpiresAt:      time.Now().Add(s.config.DeviceCodeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &authEntity.DeviceAuthorization{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         s.config.DeviceVerificationURI,
+		VerificationURIComplete: s.config.DeviceVerificationURI + "?" + url.Values{"user_code": {userCode}}.Encode(),
+		ExpiresIn:               int64(s.config.DeviceCodeTTL.Seconds()),
+		Interval:                interval,
+	}, nil
+}
+
+// CheckUserCode returns the client and the scopes of a pending device
+// authorization, for the user to review
+func (s *oauthService) CheckUserCode(ctx context.Context, userCode string) (*authEntity.OAuthClient, []string, error) {
+	device, err := s.pendingDevice(ctx, userCode)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := s.clients.FindOneById(ctx, device.ClientId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if client == nil {
+		return nil, nil, ErrInvalidUserCode
+	}
+
+	return client, device.Scopes, nil
+}
+
+// DecideDevice approves or denies a pending device authorization for the
+// user, who has signed in on the verification page. Anyone else could
+// cancel the sign in of a device whose code they saw.
+func (s *oauthService) DecideDevice(ctx context.Context, userCode string, user *entity.User, approve bool) error {
+	if user == nil {
+		return ErrDeviceUserRequired
+	}
+
+	device, err := s.pendingDevice(ctx, userCode)
+	if err != nil {
+		return err
+	}
+
+	var decided bool
+	if approve {
+		decided, err = s.devices.Approve(ctx, device.Id, user.Id)
+	} else {
+		decided, err = s.devices.Deny(ctx, device.Id)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !decided {
+		return ErrInvalidUserCode
+	}
+
+	return nil
+}
+
+// ExchangeDeviceCode answers a polling device. It gets a token pair in a
+// new session of the client once the user approved, RFC 8628 errors until
+// then. Polling faster than the interval raises the interval.
+func (s *oauthService) ExchangeDeviceCode(ctx context.Context, clientId string, clientSecret string, deviceCode string, client authEntity.Client) (*authEntity.TokenPair, error) {
+	if deviceCode == "" {
+		return nil, oauthError("invalid_request", "device_code is required")
+	}
+
+	if _, err := s.authenticateClient(ctx, clientId, clientSecret); err != nil {
+		return nil, err
+	}
+
+	device, err := s.devices.FindOneByDeviceCodeHash(ctx, hashToken(deviceCode))
+	if err != nil {
+		return nil, err
+	}
+
+	invalidGrant := oauthError("invalid_grant", "invalid device code")
+	if device == nil || device.ClientId != clientId || device.ConsumedAt != nil {
+		return nil, invalidGrant
+	}
+
+	now := time.Now()
+	if !now.Before(device.ExpiresAt) {
+		return nil, oauthError("expired_token", "the device code has expired")
+	}
+
+	if device.DeniedAt != nil {
+		return nil, oauthError("access_denied", "the user denied the request")
+	}
+
+	interval := device.Interval
+	tooFast := device.LastPolledAt != nil && now.Sub(*device.LastPolledAt) < time.Duration(interval)*time.Second
+	if tooFast {
+		interval += slowDownStep
+	}
+
+	if err := s.devices.RecordPoll(ctx, device.Id, now, interval); err != nil {
+		return nil, err
+	}
+
+	if tooFast {
+		return nil, oauthError("slow_down", "poll less often")
+	}
+
+	if device.ApprovedAt == nil || device.UserId == nil {
+		return nil, oauthError("authorization_pending", "the user has not approved the request yet")
+	}
+
+	consumed, err := s.devices.Consume(ctx, device.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !consumed {
+		return nil, invalidGrant
+	}
+
+	user, err := s.users.FindOneById(ctx, *device.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, invalidGrant
+	}
+
+	tokens, err := s.auth.StartClientSession(ctx, user, client, &authEntity.ClientGrant{
+		ClientId: clientId,
+		Scopes:   device.Scopes,
+		AuthTime: *device.ApprovedAt,
+	})
+	if errors.Is(err, ErrAccountDisabled) {
+		return nil, invalidGrant
+	}
+
+	return tokens, err
+}
+
+func (s *oauthService) pendingDevice(ctx context.Context, userCode string) (*authEntity.DeviceCode, error) {
+	normalized := normalizeUserCode(userCode)
+	if len(normalized) != userCodeLength {
+		return nil, ErrInvalidUserCode
+	}
+
+	device, err := s.devices.FindPendingByUserCodeHash(ctx, hashToken(normalized))
+	if err != nil {
+		return nil, err
+	}
+
+	if device == nil {
+		return nil, ErrInvalidUserCode
+	}
+
+	return device, nil
+}
+
+// generateUserCode returns a code like WDJB-MJHT for the user to type in
+func generateUserCode() (string, error) {
+	b := make([]byte, userCodeLength)
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = userCodeAlphabet[n.Int64()]
+	}
+
+	half := userCodeLength / 2
+	return string(b[:half]) + "-" + string(b[half:]), nil
+}
+
+// normalizeUserCode uppercases the code and drops the dash and anything
+// else the user may have typed around it
+func normalizeUserCode(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
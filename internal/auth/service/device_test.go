@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	userEntity "go-authentication-exercise/internal/user/entity"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDeviceCodeRepository keeps device codes in memory
+type fakeDeviceCodeRepository struct {
+	codes []*authEntity.DeviceCode
+}
+
+func (r *fakeDeviceCodeRepository) Create(ctx context.Context, m *authEntity.DeviceCode) (*authEntity.DeviceCode, error) {
+	r.codes = append(r.codes, m)
+	return m, nil
+}
+
+func (r *fakeDeviceCodeRepository) FindOneByDeviceCodeHash(ctx context.Context, deviceCodeHash string) (*authEntity.DeviceCode, error) {
+	for _, c := range r.codes {
+		if c.DeviceCodeHash == deviceCodeHash {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeDeviceCodeRepository) FindPendingByUserCodeHash(ctx context.Context, userCodeHash string) (*authEntity.DeviceCode, error) {
+	for _, c := range r.codes {
+		if c.UserCodeHash == userCodeHash && c.ApprovedAt == nil && c.DeniedAt == nil && time.Now().Before(c.ExpiresAt) {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeDeviceCodeRepository) RecordPoll(ctx context.Context, id uuid.UUID, at time.Time, interval int) error {
+	c := r.find(id)
+	c.LastPolledAt, c.Interval = &at, interval
+	return nil
+}
+
+func (r *fakeDeviceCodeRepository) Approve(ctx context.Context, id uuid.UUID, userId uuid.UUID) (bool, error) {
+	now := time.Now()
+	c := r.find(id)
+	c.UserId, c.ApprovedAt = &userId, &now
+	return true, nil
+}
+
+func (r *fakeDeviceCodeRepository) Deny(ctx context.Context, id uuid.UUID) (bool, error) {
+	now := time.Now()
+	r.find(id).DeniedAt = &now
+	return true, nil
+}
+
+func (r *fakeDeviceCodeRepository) Consume(ctx context.Context, id uuid.UUID) (bool, error) {
+	c := r.find(id)
+	if c.ConsumedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	c.ConsumedAt = &now
+	return true, nil
+}
+
+func (r *fakeDeviceCodeRepository) find(id uuid.UUID) *authEntity.DeviceCode {
+	for _, c := range r.codes {
+		if c.Id == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestDeviceAuthorization(t *testing.T) {
+	user := &userEntity.User{Id: uuid.New()}
+	s, auth := newOAuthTestService(user)
+	ctx := context.Background()
+
+	authorization, err := s.RequestDeviceCode(ctx, "spa", "", "users:read openid")
+	require.NoError(t, err)
+	assert.Regexp(t, `^[B-Z]{4}-[B-Z]{4}$`, authorization.UserCode)
+	assert.Equal(t, "https://auth.example.com/device", authorization.VerificationURI)
+	assert.Equal(t, "https://auth.example.com/device?user_code="+authorization.UserCode, authorization.VerificationURIComplete)
+	assert.Equal(t, int64(600), authorization.ExpiresIn)
+	assert.Equal(t, 5, authorization.Interval)
+
+	_, err = s.ExchangeDeviceCode(ctx, "spa", "", authorization.DeviceCode, authEntity.Client{})
+	assertOAuthError(t, "authorization_pending", err)
+
+	// the user may type the code in lowercase and without the dash
+	typed := authorization.UserCode[:4] + " " + authorization.UserCode[5:]
+	client, scopes, err := s.CheckUserCode(ctx, typed)
+	require.NoError(t, err)
+	assert.Equal(t, "spa", client.Id)
+	assert.Equal(t, []string{"users:read", ScopeOpenID}, scopes)
+
+	// nobody can decide without signing in, not even deny
+	assert.Equal(t, ErrDeviceUserRequired, s.DecideDevice(ctx, typed, nil, false))
+
+	require.NoError(t, s.DecideDevice(ctx, typed, user, true))
+	assert.Equal(t, ErrInvalidUserCode, s.DecideDevice(ctx, typed, user, true), "a code is decided on once")
+
+	// pretend the device waited for the interval
+	device := s.devices.(*fakeDeviceCodeRepository).codes[0]
+	polled := time.Now().Add(-6 * time.Second)
+	device.LastPolledAt = &polled
+
+	tokens, err := s.ExchangeDeviceCode(ctx, "spa", "", authorization.DeviceCode, authEntity.Client{})
+	require.NoError(t, err)
+	assert.Equal(t, "access-token", tokens.AccessToken)
+	assert.Equal(t, "spa", auth.grant.ClientId)
+	assert.Equal(t, []string{"users:read", ScopeOpenID}, auth.grant.Scopes)
+	assert.Equal(t, *device.ApprovedAt, auth.grant.AuthTime)
+
+	device.LastPolledAt = &polled
+	_, err = s.ExchangeDeviceCode(ctx, "spa", "", authorization.DeviceCode, authEntity.Client{})
+	assertOAuthError(t, "invalid_grant", err)
+}
+
+func TestExchangeDeviceCode(t *testing.T) {
+	now := time.Now()
+	recently := now.Add(-2 * time.Second)
+	longAgo := now.Add(-time.Minute)
+
+	tests := []struct {
+		name             string
+		clientId         string
+		device           authEntity.DeviceCode
+		expectedCode     string
+		expectedInterval int
+	}{
+		{
+			name:             "Pending",
+			device:           authEntity.DeviceCode{LastPolledAt: &longAgo},
+			expectedCode:     "authorization_pending",
+			expectedInterval: 5,
+		},
+		{
+			name:             "Polling too fast",
+			device:           authEntity.DeviceCode{LastPolledAt: &recently},
+			expectedCode:     "slow_down",
+			expectedInterval: 10,
+		},
+		{
+			name:             "Denied",
+			device:           authEntity.DeviceCode{DeniedAt: &recently},
+			expectedCode:     "access_denied",
+			expectedInterval: 5,
+		},
+		{
+			name:             "Expired",
+			device:           authEntity.DeviceCode{ExpiresAt: recently},
+			expectedCode:     "expired_token",
+			expectedInterval: 5,
+		},
+		{
+			name:             "Issued to another client",
+			clientId:         "worker",
+			device:           authEntity.DeviceCode{},
+			expectedCode:     "invalid_grant",
+			expectedInterval: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newOAuthTestService(&userEntity.User{Id: uuid.New()})
+
+			device := tt.device
+			device.Id = uuid.New()
+			device.ClientId = "spa"
+			device.DeviceCodeHash = hashToken("device-code")
+			device.Interval = 5
+			if device.ExpiresAt.IsZero() {
+				device.ExpiresAt = now.Add(time.Minute)
+			}
+			s.devices.(*fakeDeviceCodeRepository).codes = []*authEntity.DeviceCode{&device}
+
+			clientId := tt.clientId
+			if clientId == "" {
+				clientId = "spa"
+			}
+
+			// public clients have no secret to check
+			_, err := s.ExchangeDeviceCode(context.Background(), clientId, "worker-secret", "device-code", authEntity.Client{})
+
+			assertOAuthError(t, tt.expectedCode, err)
+			assert.Equal(t, tt.expectedInterval, device.Interval)
+		})
+	}
+}
+
+func assertOAuthError(t *testing.T, expectedCode string, err error) {
+	t.Helper()
+
+	var oauthErr *OAuthError
+	if assert.ErrorAs(t, err, &oauthErr) {
+		assert.Equal(t, expectedCode, oauthErr.Code)
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/mailer"
+	"go-authentication-exercise/internal/user/entity"
+	"go-authentication-exercise/internal/util"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrEmailTaken               = errors.New("email exists")
+	ErrEmailNotVerified         = errors.New("email address is not verified")
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+)
+
+// EmailVerificationConfig holds the settings of the email verification
+// flow. With Required set, users can't log in before verifying.
+type EmailVerificationConfig struct {
+	URL      string
+	TTL      time.Duration
+	Required bool
+}
+
+// EmailVerificationConfigFromEnv reads EMAIL_VERIFICATION_URL,
+// EMAIL_VERIFICATION_TTL and EMAIL_VERIFICATION_REQUIRED
+func EmailVerificationConfigFromEnv() EmailVerificationConfig {
+	required, _ := strconv.ParseBool(os.Getenv("EMAIL_VERIFICATION_REQUIRED"))
+
+	return EmailVerificationConfig{
+		URL:      os.Getenv("EMAIL_VERIFICATION_URL"),
+		TTL:      util.GetEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+		Required: required,
+	}
+}
+
+// VerifyEmail marks the email address the token was sent to as verified
+func (s *authService) VerifyEmail(ctx context.Context, verificationToken string) error {
+	stored, err := s.verificationTokens.Consume(ctx, hashToken(verificationToken))
+	if err != nil {
+		return err
+	}
+
+	if stored == nil {
+		return ErrInvalidVerificationToken
+	}
+
+	// fails when the user changed their email since the token was sent
+	verified, err := s.repository.MarkEmailVerified(ctx, stored.UserId, stored.Email)
+	if err != nil {
+		return err
+	}
+
+	if !verified {
+		return ErrInvalidVerificationToken
+	}
+
+	return nil
+}
+
+// ResendVerification mails a new verification link. Like ForgotPassword it
+// doesn't reveal whether the address belongs to an account.
+func (s *authService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.repository.FindOneByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	if user == nil || user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	s.inBackground("email verification", user, s.sendEmailVerification)
+	return nil
+}
+
+// sendEmailVerification replaces any outstanding verification token of the
+// user with a new one and mails it
+func (s *authService) sendEmailVerification(ctx context.Context, user *entity.User) error {
+	if err := s.verificationTokens.InvalidateAllForUser(ctx, user.Id); err != nil {
+		return err
+	}
+
+	verificationToken, err := generateRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.verificationTokens.Create(ctx, &authEntity.EmailVerificationToken{
+		Id:        uuid.New(),
+		UserId:    user.Id,
+		Email:     user.Email,
+		TokenHash: hashToken(verificationToken),
+		ExpiresAt: time.Now().Add(s.verificationConfig.TTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link, err := tokenLink(s.verificationConfig.URL, verificationToken)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm your email address using the following within %s:\n\n"+
+			"%s\n\n"+
+			"If you didn't create an account, you can ignore this email.\n",
+			user.Fullname, s.verificationConfig.TTL, link),
+	})
+}
+
+// inBackground runs send for user without holding up the request, so the
+// response time doesn't depend on the mail server. Failures are logged.
+func (s *authService) inBackground(description string, user *entity.User, send func(context.Context, *entity.User) error) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		if err := send(ctx, user); err != nil {
+			log.Printf("Failed to send %s for user %s: %v", description, user.Id, err)
+		}
+	}()
+}
+
+// tokenLink appends token to base as the `token` query parameter, or
+// returns the bare token when no base URL is configured
+func tokenLink(base string, token string) (string, error) {
+	if base == "" {
+		return token, nil
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
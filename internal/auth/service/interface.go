@@ -2,11 +2,67 @@ package service
 
 import (
 	"context"
+	"time"
 
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/auth/token"
 	"go-authentication-exercise/internal/user/entity"
+
+	"github.com/google/uuid"
 )
 
 type AuthService interface {
-	Login(ctx context.Context, username string, password string) (string, error)
-	Signup(ctx context.Context, username string, fullname string, password string) (*entity.User, error)
+	Login(ctx context.Context, username string, password string, client authEntity.Client) (*authEntity.TokenPair, *authEntity.LoginChallenge, error)
+	LoginMFA(ctx context.Context, challengeToken string, code string, recoveryCode string, client authEntity.Client) (*authEntity.TokenPair, error)
+	Signup(ctx context.Context, username string, email string, fullname string, password string) (*entity.User, error)
+	Refresh(ctx context.Context, refreshToken string, clientId string) (*authEntity.TokenPair, error)
+	Logout(ctx context.Context, userId uuid.UUID, jti string, expiresAt time.Time, sessionId uuid.UUID, refreshToken string) error
+	JWKS() *token.JWKS
+	ChangePassword(ctx context.Context, userId uuid.UUID, currentPassword string, newPassword string, client authEntity.Client) (*authEntity.TokenPair, error)
+	ForgotPassword(ctx context.Context, username string) error
+	SendPasswordReset(ctx context.Context, user *entity.User) error
+	ResetPassword(ctx context.Context, resetToken string, newPassword string) error
+	VerifyEmail(ctx context.Context, verificationToken string) error
+	ResendVerification(ctx context.Context, email string) error
+	SetupTOTP(ctx context.Context, userId uuid.UUID) (*authEntity.TOTPSetup, error)
+	ConfirmTOTP(ctx context.Context, userId uuid.UUID, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userId uuid.UUID, password string, code string, recoveryCode string) error
+	ListSessions(ctx context.Context, userId uuid.UUID) ([]*authEntity.Session, error)
+	RevokeSession(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) error
+	Authenticate(ctx context.Context, username string, password string, code string, client authEntity.Client) (*entity.User, error)
+	StartClientSession(ctx context.Context, user *entity.User, client authEntity.Client, grant *authEntity.ClientGrant) (*authEntity.TokenPair, error)
+	IssueClientToken(ctx context.Context, clientId string, scopes []string) (*authEntity.TokenPair, error)
+}
+
+type OAuthService interface {
+	RegisterClient(ctx context.Context, name string, redirectURIs []string, scopes []string, confidential bool) (*authEntity.NewOAuthClient, error)
+	ListClients(ctx context.Context) ([]*authEntity.OAuthClient, error)
+	DeleteClient(ctx context.Context, clientId string) error
+	CheckAuthorization(ctx context.Context, req *authEntity.AuthorizationRequest) (*authEntity.OAuthClient, []string, error)
+	Authorize(ctx context.Context, req *authEntity.AuthorizationRequest, user *entity.User) (string, error)
+	ExchangeCode(ctx context.Context, clientId string, clientSecret string, code string, redirectURI string, codeVerifier string, client authEntity.Client) (*authEntity.TokenPair, error)
+	RefreshToken(ctx context.Context, clientId string, clientSecret string, refreshToken string) (*authEntity.TokenPair, error)
+	ClientCredentials(ctx context.Context, clientId string, clientSecret string, scope string) (*authEntity.TokenPair, error)
+	RequestDeviceCode(ctx context.Context, clientId string, clientSecret string, scope string) (*authEntity.DeviceAuthorization, error)
+	CheckUserCode(ctx context.Context, userCode string) (*authEntity.OAuthClient, []string, error)
+	DecideDevice(ctx context.Context, userCode string, user *entity.User, approve bool) error
+	ExchangeDeviceCode(ctx context.Context, clientId string, clientSecret string, deviceCode string, client authEntity.Client) (*authEntity.TokenPair, error)
+	Introspect(ctx context.Context, clientId string, clientSecret string, token string) (*authEntity.Introspection, error)
+	Revoke(ctx context.Context, clientId string, clientSecret string, token string) error
+	OpenIDConfiguration() (*authEntity.OpenIDConfiguration, error)
+	UserInfo(ctx context.Context, userId uuid.UUID, scopes []string) (*authEntity.UserInfo, error)
+}
+
+type LoginThrottle interface {
+	Check(ctx context.Context, username string, clientIP string) error
+	Failure(ctx context.Context, username string, clientIP string) error
+	Success(ctx context.Context, username string) error
+	Unlock(ctx context.Context, username string) error
+}
+
+type KeyService interface {
+	Load(ctx context.Context) error
+	Adopt(ctx context.Context, key *token.Key) (bool, error)
+	Rotate(ctx context.Context) (*token.Key, error)
+	Run(ctx context.Context, rotateEvery time.Duration)
 }
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	apiKeyService "go-authentication-exercise/internal/apikey/service"
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/user/entity"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+var ErrTooManyIntrospections = errors.New("too many introspection requests")
+
+// inactive is the whole answer about a token that is not active, RFC 7662
+// doesn't tell why
+func inactive() *authEntity.Introspection {
+	return &authEntity.Introspection{Active: false}
+}
+
+// Introspect tells a confidential client whether a token is active and
+// what it grants. Access tokens, refresh tokens and API keys are told
+// apart by their form, so no type hint is needed. A token is active when
+// the API would accept it. Each client has a limit of its own, counted once
+// it is authenticated so nobody else can use it up.
+func (s *oauthService) Introspect(ctx context.Context, clientId string, clientSecret string, token string) (*authEntity.Introspection, error) {
+	if token == "" {
+		return nil, oauthError("invalid_request", "token is required")
+	}
+
+	client, err := s.authenticateClient(ctx, clientId, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	// public clients have no secret, anyone could claim to be one
+	if !client.Confidential {
+		return nil, oauthError("invalid_client", "only confidential clients may introspect tokens")
+	}
+
+	if allowed, retryAfter := s.introspectLimit.Allow("client:" + client.Id); !allowed {
+		return nil, &RetryAfterError{Err: ErrTooManyIntrospections, RetryAfter: retryAfter}
+	}
+
+	switch {
+	case apiKeyService.IsAPIKey(token):
+		return s.introspectAPIKey(ctx, token)
+	case isJWT(token):
+		return s.introspectAccessToken(ctx, token)
+	default:
+		return s.introspectRefreshToken(ctx, token)
+	}
+}
+
+// Revoke revokes an access or refresh token issued to the client. Revoking
+// a refresh token ends its session, which invalidates the access tokens of
+// the session too. Tokens that are unknown or belong to someone else are
+// left alone without an error, as RFC 7009 asks. API keys are only revoked
+// by their owner.
+func (s *oauthService) Revoke(ctx context.Context, clientId string, clientSecret string, token string) error {
+	if token == "" {
+		return oauthError("invalid_request", "token is required")
+	}
+
+	if _, err := s.authenticateClient(ctx, clientId, clientSecret); err != nil {
+		return err
+	}
+
+	if apiKeyService.IsAPIKey(token) {
+		return oauthError("unsupported_token_type", "API keys can only be revoked by their owner")
+	}
+
+	if isJWT(token) {
+		claims, err := s.accessTokenClaims(token)
+		if err != nil || claims["client_id"] != clientId {
+			return nil
+		}
+
+		jti, _ := claims["jti"].(string)
+		return s.revokedTokens.Revoke(ctx, jti, time.Unix(unixClaim(claims, "exp"), 0))
+	}
+
+	stored, err := s.refreshTokens.FindOneByTokenHash(ctx, hashToken(token))
+	if err != nil || stored == nil {
+		return err
+	}
+
+	session, err := s.sessions.FindOneById(ctx, stored.FamilyId)
+	if err != nil || session == nil || session.ClientId != clientId {
+		return err
+	}
+
+	if err := s.sessions.Revoke(ctx, session.Id); err != nil {
+		return err
+	}
+
+	return s.refreshTokens.RevokeFamily(ctx, session.Id)
+}
+
+func (s *oauthService) introspectAccessToken(ctx context.Context, token string) (*authEntity.Introspection, error) {
+	claims, err := s.accessTokenClaims(token)
+	if err != nil {
+		return inactive(), nil
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		// ID tokens
+		return inactive(), nil
+	}
+
+	revoked, err := s.revokedTokens.IsRevoked(ctx, jti)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return inactive(), nil
+	}
+
+	subject, _ := claims["sub"].(string)
+	clientId, _ := claims["client_id"].(string)
+	scope, _ := claims["scope"].(string)
+	issuer, _ := claims["iss"].(string)
+	audience, _ := claims["aud"].(string)
+	info := &authEntity.Introspection{
+		Active:    true,
+		Scope:     scope,
+		ClientId:  clientId,
+		TokenType: "Bearer",
+		ExpiresAt: unixClaim(claims, "exp"),
+		IssuedAt:  unixClaim(claims, "iat"),
+		NotBefore: unixClaim(claims, "nbf"),
+		Subject:   subject,
+		Audience:  audience,
+		Issuer:    issuer,
+		JTI:       jti,
+	}
+
+	// tokens of the client credentials grant have the client as subject
+	if clientId != "" && subject == clientId {
+		client, err := s.clients.FindOneById(ctx, clientId)
+		if err != nil {
+			return nil, err
+		}
+
+		if client == nil || !client.Confidential {
+			return inactive(), nil
+		}
+
+		return info, nil
+	}
+
+	userId, err := uuid.Parse(subject)
+	if err != nil {
+		return inactive(), nil
+	}
+
+	user, err := s.activeUser(ctx, userId, time.Unix(info.IssuedAt, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return inactive(), nil
+	}
+
+	// tokens issued before sessions existed carry no session
+	if sid, ok := claims["sid"].(string); ok {
+		sessionId, err := uuid.Parse(sid)
+		if err != nil {
+			return inactive(), nil
+		}
+
+		session, err := s.sessions.FindOneById(ctx, sessionId)
+		if err != nil {
+			return nil, err
+		}
+
+		if session == nil || session.UserId != user.Id || session.RevokedAt != nil {
+			return inactive(), nil
+		}
+	}
+
+	info.Username = user.Username
+	return info, nil
+}
+
+func (s *oauthService) introspectRefreshToken(ctx context.Context, token string) (*authEntity.Introspection, error) {
+	stored, err := s.refreshTokens.FindOneByTokenHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if stored == nil || stored.RevokedAt != nil || stored.UsedAt != nil || !time.Now().Before(stored.ExpiresAt) {
+		return inactive(), nil
+	}
+
+	user, err := s.activeUser(ctx, stored.UserId, stored.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return inactive(), nil
+	}
+
+	info := &authEntity.Introspection{
+		Active:    true,
+		Username:  user.Username,
+		ExpiresAt: stored.ExpiresAt.Unix(),
+		IssuedAt:  stored.CreatedAt.Unix(),
+		Subject:   user.Id.String(),
+	}
+
+	// families from before sessions existed have no session yet
+	session, err := s.sessions.FindOneById(ctx, stored.FamilyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if session != nil {
+		if session.RevokedAt != nil || session.UserId != user.Id {
+			return inactive(), nil
+		}
+
+		if session.ClientId != "" {
+			info.ClientId = session.ClientId
+			info.Scope = strings.Join(session.Scopes, " ")
+		}
+	}
+
+	return info, nil
+}
+
+func (s *oauthService) introspectAPIKey(ctx context.Context, token string) (*authEntity.Introspection, error) {
+	key, err := s.apiKeys.Authenticate(ctx, token)
+	if errors.Is(err, apiKeyService.ErrInvalidAPIKey) {
+		return inactive(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// API keys outlive password changes
+	user, err := s.activeUser(ctx, key.UserId, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return inactive(), nil
+	}
+
+	info := &authEntity.Introspection{
+		Active:    true,
+		Scope:     strings.Join(key.Scopes, " "),
+		Username:  user.Username,
+		TokenType: "Bearer",
+		IssuedAt:  key.CreatedAt.Unix(),
+		Subject:   user.Id.String(),
+	}
+	if key.ExpiresAt != nil {
+		info.ExpiresAt = key.ExpiresAt.Unix()
+	}
+
+	return info, nil
+}
+
+// accessTokenClaims verifies the signature and the registered claims of a
+// JWT, it doesn't check for revocation
+func (s *oauthService) accessTokenClaims(token string) (jwt.MapClaims, error) {
+	// the claims are validated below to allow for clock skew
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	parsed, err := parser.Parse(token, s.keyring.Keyfunc)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || !parsed.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if err := s.tokenConfig.Validate(claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// activeUser returns the user unless it is deleted, disabled or signed out
+// everywhere after issuedAt
+func (s *oauthService) activeUser(ctx context.Context, userId uuid.UUID, issuedAt time.Time) (*entity.User, error) {
+	user, err := s.users.FindOneById(ctx, userId)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	if user.DisabledAt != nil {
+		return nil, nil
+	}
+
+	if !issuedAt.IsZero() && user.TokensValidAfter != nil && issuedAt.Unix() < user.TokensValidAfter.Unix() {
+		return nil, nil
+	}
+
+	return user, nil
+}
+
+// isJWT reports whether token has the three parts of a JWT, opaque tokens
+// are URL-safe base64 without dots
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func unixClaim(claims jwt.MapClaims, name string) int64 {
+	value, _ := claims[name].(float64)
+	return int64(value)
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apiKeyEntity "go-authentication-exercise/internal/apikey/entity"
+	apiKeyService "go-authentication-exercise/internal/apikey/service"
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/auth/token"
+	userEntity "go-authentication-exercise/internal/user/entity"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRevokedTokenRepository keeps revoked token ids in memory
+type fakeRevokedTokenRepository struct {
+	revoked map[string]time.Time
+}
+
+func (r *fakeRevokedTokenRepository) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	r.revoked[jti] = expiresAt
+	return nil
+}
+
+func (r *fakeRevokedTokenRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	_, ok := r.revoked[jti]
+	return ok, nil
+}
+
+// fakeAPIKeyService knows a fixed set of keys
+type fakeAPIKeyService struct {
+	apiKeyService.APIKeyService
+	keys map[string]*apiKeyEntity.APIKey
+}
+
+func (s *fakeAPIKeyService) Authenticate(ctx context.Context, key string) (*apiKeyEntity.APIKey, error) {
+	if stored, ok := s.keys[key]; ok {
+		return stored, nil
+	}
+	return nil, apiKeyService.ErrInvalidAPIKey
+}
+
+// fakeClientRateLimiter allows limit requests per key, zero disables it
+type fakeClientRateLimiter struct {
+	limit int
+	taken map[string]int
+}
+
+func (l *fakeClientRateLimiter) Allow(key string) (bool, time.Duration) {
+	if l.limit == 0 {
+		return true, 0
+	}
+
+	l.taken[key]++
+	return l.taken[key] <= l.limit, time.Minute
+}
+
+// introspectionFixture is an OAuth service with a user, who has a first
+// party session and a session of the spa client, and an API key
+type introspectionFixture struct {
+	service       *oauthService
+	user          *userEntity.User
+	session       *authEntity.Session
+	clientSession *authEntity.Session
+	refreshTokens *fakeRefreshTokenRepository
+	revokedTokens *fakeRevokedTokenRepository
+}
+
+func newIntrospectionFixture() *introspectionFixture {
+	user := &userEntity.User{Id: uuid.New(), Username: "testuser"}
+	s, _ := newOAuthTestService(user)
+
+	session := &authEntity.Session{Id: uuid.New(), UserId: user.Id}
+	clientSession := &authEntity.Session{Id: uuid.New(), UserId: user.Id, ClientId: "spa", Scopes: []string{"users:read"}}
+	refreshTokens := &fakeRefreshTokenRepository{tokens: []*authEntity.RefreshToken{{
+		Id:        uuid.New(),
+		UserId:    user.Id,
+		FamilyId:  clientSession.Id,
+		TokenHash: hashToken("client-refresh-token"),
+		ExpiresAt: time.Now().Add(time.Hour),
+		CreatedAt: time.Now(),
+	}}}
+	revokedTokens := &fakeRevokedTokenRepository{revoked: map[string]time.Time{}}
+
+	s.refreshTokens = refreshTokens
+	s.revokedTokens = revokedTokens
+	s.sessions = &fakeSessionRepository{sessions: map[uuid.UUID]*authEntity.Session{
+		session.Id:       session,
+		clientSession.Id: clientSession,
+	}}
+	s.apiKeys = &fakeAPIKeyService{keys: map[string]*apiKeyEntity.APIKey{
+		"pat_0123abcd_secret": {Id: uuid.New(), UserId: user.Id, Scopes: []string{"users:read"}, CreatedAt: time.Now()},
+	}}
+	s.introspectLimit = &fakeClientRateLimiter{}
+	s.keyring = token.NewKeyring(token.NewHMACKey("test", []byte("secret")))
+	s.tokenConfig = token.Config{Issuer: "https://auth.example.com", AccessTokenTTL: time.Minute}
+
+	return &introspectionFixture{
+		service:       s,
+		user:          user,
+		session:       session,
+		clientSession: clientSession,
+		refreshTokens: refreshTokens,
+		revokedTokens: revokedTokens,
+	}
+}
+
+// accessToken signs an access token of the session, like the auth service
+func (f *introspectionFixture) accessToken(t *testing.T, session *authEntity.Session) string {
+	claims := f.service.tokenConfig.NewClaims(f.user.Id.String(), time.Now())
+	claims["username"] = f.user.Username
+	claims["sid"] = session.Id.String()
+	if session.ClientId != "" {
+		claims["client_id"] = session.ClientId
+		claims["scope"] = "users:read"
+	}
+
+	signed, err := f.service.keyring.Sign(claims)
+	require.NoError(t, err)
+	return signed
+}
+
+func (f *introspectionFixture) clientToken(t *testing.T) string {
+	claims := f.service.tokenConfig.NewClaims("worker", time.Now())
+	claims["client_id"] = "worker"
+	claims["scope"] = "users:read"
+
+	signed, err := f.service.keyring.Sign(claims)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestIntrospect(t *testing.T) {
+	tests := []struct {
+		name             string
+		token            func(*testing.T, *introspectionFixture) string
+		expectedActive   bool
+		expectedSubject  string
+		expectedUsername bool
+		expectedClientId string
+		expectedScope    string
+	}{
+		{
+			name:             "Access token of a login",
+			token:            func(t *testing.T, f *introspectionFixture) string { return f.accessToken(t, f.session) },
+			expectedActive:   true,
+			expectedUsername: true,
+		},
+		{
+			name:             "Access token of an OAuth client",
+			token:            func(t *testing.T, f *introspectionFixture) string { return f.accessToken(t, f.clientSession) },
+			expectedActive:   true,
+			expectedUsername: true,
+			expectedClientId: "spa",
+			expectedScope:    "users:read",
+		},
+		{
+			name: "Revoked access token",
+			token: func(t *testing.T, f *introspectionFixture) string {
+				signed := f.accessToken(t, f.session)
+				claims, err := f.service.accessTokenClaims(signed)
+				require.NoError(t, err)
+				f.revokedTokens.revoked[claims["jti"].(string)] = time.Now()
+				return signed
+			},
+		},
+		{
+			name: "Access token of a revoked session",
+			token: func(t *testing.T, f *introspectionFixture) string {
+				revokedAt := time.Now()
+				f.session.RevokedAt = &revokedAt
+				return f.accessToken(t, f.session)
+			},
+		},
+		{
+			name: "Access token of a disabled user",
+			token: func(t *testing.T, f *introspectionFixture) string {
+				disabledAt := time.Now()
+				f.user.DisabledAt = &disabledAt
+				return f.accessToken(t, f.session)
+			},
+		},
+		{
+			name:             "Client credentials token",
+			token:            func(t *testing.T, f *introspectionFixture) string { return f.clientToken(t) },
+			expectedActive:   true,
+			expectedSubject:  "worker",
+			expectedClientId: "worker",
+			expectedScope:    "users:read",
+		},
+		{
+			name:  "Token with a bad signature",
+			token: func(t *testing.T, f *introspectionFixture) string { return f.accessToken(t, f.session) + "x" },
+		},
+		{
+			name:             "Refresh token",
+			token:            func(t *testing.T, f *introspectionFixture) string { return "client-refresh-token" },
+			expectedActive:   true,
+			expectedUsername: true,
+			expectedClientId: "spa",
+			expectedScope:    "users:read",
+		},
+		{
+			name: "Rotated refresh token",
+			token: func(t *testing.T, f *introspectionFixture) string {
+				usedAt := time.Now()
+				f.refreshTokens.tokens[0].UsedAt = &usedAt
+				return "client-refresh-token"
+			},
+		},
+		{
+			name:  "Unknown refresh token",
+			token: func(t *testing.T, f *introspectionFixture) string { return "unknown" },
+		},
+		{
+			name:             "API key",
+			token:            func(t *testing.T, f *introspectionFixture) string { return "pat_0123abcd_secret" },
+			expectedActive:   true,
+			expectedUsername: true,
+			expectedScope:    "users:read",
+		},
+		{
+			name:  "Revoked API key",
+			token: func(t *testing.T, f *introspectionFixture) string { return "pat_0123abcd_revoked" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newIntrospectionFixture()
+
+			info, err := f.service.Introspect(context.Background(), "worker", "worker-secret", tt.token(t, f))
+			require.NoError(t, err)
+
+			if !tt.expectedActive {
+				assert.Equal(t, &authEntity.Introspection{Active: false}, info)
+				return
+			}
+
+			expectedSubject := tt.expectedSubject
+			if expectedSubject == "" {
+				expectedSubject = f.user.Id.String()
+			}
+
+			assert.True(t, info.Active)
+			assert.Equal(t, expectedSubject, info.Subject)
+			assert.Equal(t, tt.expectedClientId, info.ClientId)
+			assert.Equal(t, tt.expectedScope, info.Scope)
+			if tt.expectedUsername {
+				assert.Equal(t, "testuser", info.Username)
+			}
+		})
+	}
+}
+
+func TestIntrospectClientAuthentication(t *testing.T) {
+	f := newIntrospectionFixture()
+
+	_, err := f.service.Introspect(context.Background(), "spa", "", "client-refresh-token")
+	assertOAuthError(t, "invalid_client", err)
+
+	_, err = f.service.Introspect(context.Background(), "worker", "wrong", "client-refresh-token")
+	assertOAuthError(t, "invalid_client", err)
+}
+
+func TestIntrospectRateLimit(t *testing.T) {
+	f := newIntrospectionFixture()
+	limiter := &fakeClientRateLimiter{limit: 1, taken: map[string]int{}}
+	f.service.introspectLimit = limiter
+
+	// requests claiming to be the worker don't count against its limit
+	for i := 0; i < 3; i++ {
+		_, err := f.service.Introspect(context.Background(), "worker", "forged", "client-refresh-token")
+		assertOAuthError(t, "invalid_client", err)
+
+		_, err = f.service.Introspect(context.Background(), "forged-client", "", "client-refresh-token")
+		assertOAuthError(t, "invalid_client", err)
+	}
+	assert.Empty(t, limiter.taken)
+
+	_, err := f.service.Introspect(context.Background(), "worker", "worker-secret", "client-refresh-token")
+	require.NoError(t, err)
+
+	_, err = f.service.Introspect(context.Background(), "worker", "worker-secret", "client-refresh-token")
+	var retry *RetryAfterError
+	require.ErrorAs(t, err, &retry)
+	assert.ErrorIs(t, err, ErrTooManyIntrospections)
+	assert.Equal(t, map[string]int{"client:worker": 2}, limiter.taken)
+}
+
+func TestRevoke(t *testing.T) {
+	t.Run("Refresh token ends the session", func(t *testing.T) {
+		f := newIntrospectionFixture()
+
+		require.NoError(t, f.service.Revoke(context.Background(), "spa", "", "client-refresh-token"))
+		assert.NotNil(t, f.clientSession.RevokedAt)
+		assert.Equal(t, []uuid.UUID{f.clientSession.Id}, f.refreshTokens.revokedFamilies)
+	})
+
+	t.Run("Tokens of another client are left alone", func(t *testing.T) {
+		f := newIntrospectionFixture()
+
+		require.NoError(t, f.service.Revoke(context.Background(), "worker", "worker-secret", "client-refresh-token"))
+		require.NoError(t, f.service.Revoke(context.Background(), "worker", "worker-secret", f.accessToken(t, f.clientSession)))
+		assert.Nil(t, f.clientSession.RevokedAt)
+		assert.Empty(t, f.refreshTokens.revokedFamilies)
+		assert.Empty(t, f.revokedTokens.revoked)
+	})
+
+	t.Run("Access token", func(t *testing.T) {
+		f := newIntrospectionFixture()
+		accessToken := f.accessToken(t, f.clientSession)
+
+		require.NoError(t, f.service.Revoke(context.Background(), "spa", "", accessToken))
+		assert.Len(t, f.revokedTokens.revoked, 1)
+
+		info, err := f.service.Introspect(context.Background(), "worker", "worker-secret", accessToken)
+		require.NoError(t, err)
+		assert.False(t, info.Active)
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		f := newIntrospectionFixture()
+
+		assert.NoError(t, f.service.Revoke(context.Background(), "spa", "", "unknown"))
+	})
+
+	t.Run("API keys are not revoked by clients", func(t *testing.T) {
+		f := newIntrospectionFixture()
+
+		err := f.service.Revoke(context.Background(), "spa", "", "pat_0123abcd_secret")
+		assertOAuthError(t, "unsupported_token_type", err)
+	})
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	authRepository "go-authentication-exercise/internal/auth/repository"
+	"go-authentication-exercise/internal/auth/token"
+)
+
+type keyService struct {
+	repository  authRepository.SigningKeyRepository
+	keyring     *token.Keyring
+	cipher      *token.KeyCipher
+	algorithm   string
+	overlap     time.Duration
+	reloadEvery time.Duration
+	latestAt    time.Time
+	now         func() time.Time
+}
+
+// NewKeyService keeps keyring in sync with the stored signing keys and
+// reloads them every reloadEvery. New keys use algorithm. A replaced key
+// keeps verifying tokens for two reload intervals plus overlap after the
+// rotation: the new key waits one interval before it signs, and other
+// instances may sign with the replaced key until their next reload. overlap
+// must be at least the access token lifetime. Key material is encrypted
+// with cipher when it is not nil.
+func NewKeyService(
+	repo authRepository.SigningKeyRepository,
+	keyring *token.Keyring,
+	cipher *token.KeyCipher,
+	algorithm string,
+	overlap time.Duration,
+	reloadEvery time.Duration,
+) KeyService {
+	return &keyService{
+		repository:  repo,
+		keyring:     keyring,
+		cipher:      cipher,
+		algorithm:   algorithm,
+		overlap:     overlap,
+		reloadEvery: reloadEvery,
+		now:         time.Now,
+	}
+}
+
+// Load refreshes the keyring from the database. When no key is stored yet,
+// the keyring's current key (the configured one) is stored as the first.
+//
+// A new key only starts signing once it is older than the reload interval,
+// so every instance already trusts it by the time tokens signed with it
+// show up. Until then it is only used for verification.
+func (s *keyService) Load(ctx context.Context) error {
+	stored, err := s.repository.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(stored) == 0 {
+		seed, err := s.store(ctx, s.keyring.Current(), nil)
+		if err != nil {
+			return err
+		}
+		stored = []*authEntity.SigningKey{seed}
+		log.Printf("Stored the configured signing key %s as the first key", seed.Id)
+	}
+
+	signFrom := s.now().Add(-s.reloadEvery)
+	currentIndex := len(stored) - 1
+	for i, m := range stored {
+		// keys are ordered newest first
+		if !m.CreatedAt.After(signFrom) {
+			currentIndex = i
+			break
+		}
+	}
+
+	var current *token.Key
+	var others []*token.Key
+
+	for i, m := range stored {
+		material, err := s.cipher.Open(m.Id, m.KeyMaterial)
+		if err != nil {
+			return err
+		}
+
+		key, err := token.ParseKey(m.Id, m.Algorithm, material)
+		if err != nil {
+			return err
+		}
+
+		if m.ExpiresAt != nil {
+			key.NotAfter = *m.ExpiresAt
+		}
+
+		if i == currentIndex {
+			current = key
+		} else {
+			others = append(others, key)
+		}
+	}
+
+	s.keyring.Set(current, others...)
+	s.latestAt = stored[0].CreatedAt
+
+	return nil
+}
+
+// Adopt rotates to key, the configured one, unless it has been stored
+// before, so replacing a leaked secret or switching the algorithm takes
+// effect without waiting for the next rotation. A different key stored
+// under the same id is an error, the new key needs an id of its own. It
+// reports whether it rotated.
+func (s *keyService) Adopt(ctx context.Context, key *token.Key) (bool, error) {
+	stored, err := s.repository.FindOneById(ctx, key.Id)
+	if err != nil {
+		return false, err
+	}
+
+	if stored == nil {
+		if err := s.rotateTo(ctx, key); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	material, err := key.MarshalPrivateKey()
+	if err != nil {
+		return false, err
+	}
+
+	storedMaterial, err := s.cipher.Open(stored.Id, stored.KeyMaterial)
+	if err != nil {
+		return false, err
+	}
+
+	if stored.Algorithm != key.Method.Alg() || subtle.ConstantTimeCompare([]byte(material), []byte(storedMaterial)) != 1 {
+		return false, fmt.Errorf("the configured signing key differs from the stored key %s, set a new JWT_KEY_ID to rotate to it", key.Id)
+	}
+
+	return false, nil
+}
+
+// Rotate generates a new signing key. The keys it replaces keep verifying
+// tokens for the overlap period after the last instance stops signing with
+// them.
+func (s *keyService) Rotate(ctx context.Context) (*token.Key, error) {
+	key, err := token.GenerateKey(s.algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.rotateTo(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// rotateTo stores key as the newest key and retires the others
+func (s *keyService) rotateTo(ctx context.Context, key *token.Key) error {
+	expiresAt := s.now().Add(2*s.reloadEvery + s.overlap)
+	if _, err := s.store(ctx, key, &expiresAt); err != nil {
+		return err
+	}
+
+	return s.Load(ctx)
+}
+
+// Run reloads the keyring periodically so keys rotated by other instances
+// are picked up, and rotates the signing key once the newest key is older
+// than rotateEvery. A zero rotateEvery disables scheduled rotation.
+func (s *keyService) Run(ctx context.Context, rotateEvery time.Duration) {
+	ticker := time.NewTicker(s.reloadEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.Load(ctx); err != nil {
+			log.Printf("Failed to reload signing keys: %v", err)
+			continue
+		}
+
+		if rotateEvery > 0 && s.now().Sub(s.latestAt) >= rotateEvery {
+			key, err := s.Rotate(ctx)
+			if err != nil {
+				log.Printf("Failed to rotate signing key: %v", err)
+				continue
+			}
+			log.Printf("Rotated signing key, new key id %s", key.Id)
+		}
+	}
+}
+
+// store persists key, retiring the other keys when retireUntil is set
+func (s *keyService) store(ctx context.Context, key *token.Key, retireUntil *time.Time) (*authEntity.SigningKey, error) {
+	material, err := key.MarshalPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.cipher == nil {
+		log.Printf("Storing signing key %s unencrypted, set JWT_KEY_ENCRYPTION_KEY to encrypt it", key.Id)
+	}
+
+	material, err = s.cipher.Seal(key.Id, material)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &authEntity.SigningKey{
+		Id:          key.Id,
+		Algorithm:   key.Method.Alg(),
+		KeyMaterial: material,
+	}
+
+	if retireUntil == nil {
+		return s.repository.Create(ctx, m)
+	}
+
+	return s.repository.Rotate(ctx, m, *retireUntil)
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/auth/token"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSigningKeyRepository is an in-memory SigningKeyRepository, on the
+// wall clock unless clock is set
+type fakeSigningKeyRepository struct {
+	keys  []*authEntity.SigningKey
+	clock *time.Time
+}
+
+func (r *fakeSigningKeyRepository) now() time.Time {
+	if r.clock != nil {
+		return *r.clock
+	}
+	return time.Now()
+}
+
+func (r *fakeSigningKeyRepository) ListActive(ctx context.Context) ([]*authEntity.SigningKey, error) {
+	var res []*authEntity.SigningKey
+	for i := len(r.keys) - 1; i >= 0; i-- {
+		k := r.keys[i]
+		if k.ExpiresAt == nil || k.ExpiresAt.After(r.now()) {
+			res = append(res, k)
+		}
+	}
+	return res, nil
+}
+
+func (r *fakeSigningKeyRepository) FindOneById(ctx context.Context, id string) (*authEntity.SigningKey, error) {
+	for _, k := range r.keys {
+		if k.Id == id {
+			return k, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeSigningKeyRepository) Create(ctx context.Context, k *authEntity.SigningKey) (*authEntity.SigningKey, error) {
+	k.CreatedAt = r.now()
+	r.keys = append(r.keys, k)
+	return k, nil
+}
+
+func (r *fakeSigningKeyRepository) Rotate(ctx context.Context, next *authEntity.SigningKey, expiresAt time.Time) (*authEntity.SigningKey, error) {
+	now := r.now()
+	for _, k := range r.keys {
+		if k.RetiredAt == nil {
+			k.RetiredAt = &now
+			k.ExpiresAt = &expiresAt
+		}
+	}
+	return r.Create(ctx, next)
+}
+
+func TestKeyServiceRotation(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeSigningKeyRepository{}
+	seed := token.NewHMACKey("seed", []byte("test-secret-key"))
+	keyring := token.NewKeyring(seed)
+	service := NewKeyService(repo, keyring, nil, "ES256", time.Hour, time.Minute)
+
+	// an empty store is seeded with the configured key
+	assert.NoError(t, service.Load(ctx))
+	assert.Len(t, repo.keys, 1)
+	assert.Equal(t, "seed", keyring.Current().Id)
+
+	seedToken, err := keyring.Sign(jwt.MapClaims{"username": "testuser"})
+	assert.NoError(t, err)
+
+	// a rotated key is trusted right away but only signs once every
+	// instance has had a chance to reload it
+	next, err := service.Rotate(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "seed", keyring.Current().Id)
+	assert.Len(t, keyring.JWKS().Keys, 1)
+
+	nextToken, err := next.Sign(jwt.MapClaims{"username": "testuser"})
+	assert.NoError(t, err)
+	_, err = jwt.Parse(nextToken, keyring.Keyfunc)
+	assert.NoError(t, err)
+
+	// once the reload interval has passed the new key takes over and the
+	// replaced key keeps verifying during the overlap
+	repo.keys[1].CreatedAt = time.Now().Add(-2 * time.Minute)
+	assert.NoError(t, service.Load(ctx))
+	assert.Equal(t, next.Id, keyring.Current().Id)
+
+	_, err = jwt.Parse(seedToken, keyring.Keyfunc)
+	assert.NoError(t, err)
+
+	// after the overlap the replaced key is gone
+	expired := time.Now().Add(-time.Second)
+	repo.keys[0].ExpiresAt = &expired
+	assert.NoError(t, service.Load(ctx))
+
+	_, err = jwt.Parse(seedToken, keyring.Keyfunc)
+	assert.Error(t, err)
+}
+
+func TestKeyServiceRetiredKeyOutlivesTakeover(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	repo := &fakeSigningKeyRepository{clock: &now}
+	seed := token.NewHMACKey("seed", []byte("test-secret-key"))
+	keyring := token.NewKeyring(seed)
+	s := &keyService{
+		repository:  repo,
+		keyring:     keyring,
+		algorithm:   "ES256",
+		overlap:     time.Hour,
+		reloadEvery: time.Minute,
+		now:         func() time.Time { return now },
+	}
+
+	assert.NoError(t, s.Load(ctx))
+	seedToken, err := keyring.Sign(jwt.MapClaims{"username": "testuser"})
+	assert.NoError(t, err)
+
+	rotatedAt := now
+	next, err := s.Rotate(ctx)
+	assert.NoError(t, err)
+
+	// the new key takes over here once the reload interval has passed
+	now = rotatedAt.Add(time.Minute + time.Second)
+	assert.NoError(t, s.Load(ctx))
+	assert.Equal(t, next.Id, keyring.Current().Id)
+
+	// an instance that reloaded just before the rotation signs with the
+	// replaced key until its second reload after it, and those tokens live
+	// for the overlap
+	now = rotatedAt.Add(2*time.Minute + time.Hour - time.Second)
+	assert.NoError(t, s.Load(ctx))
+	_, err = jwt.Parse(seedToken, keyring.Keyfunc)
+	assert.NoError(t, err)
+
+	now = rotatedAt.Add(2*time.Minute + time.Hour + time.Second)
+	assert.NoError(t, s.Load(ctx))
+	_, err = jwt.Parse(seedToken, keyring.Keyfunc)
+	assert.Error(t, err)
+}
+
+func TestKeyServiceEncryptsKeyMaterial(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeSigningKeyRepository{}
+	keyCipher, err := token.NewKeyCipher("encryption-secret")
+	assert.NoError(t, err)
+
+	service := NewKeyService(repo, token.NewKeyring(token.NewHMACKey("seed", []byte("test-secret-key"))), keyCipher, "ES256", time.Hour, time.Minute)
+	assert.NoError(t, service.Load(ctx))
+	_, err = service.Rotate(ctx)
+	assert.NoError(t, err)
+
+	for _, k := range repo.keys {
+		assert.True(t, strings.HasPrefix(k.KeyMaterial, "sealed:"))
+	}
+
+	// another instance with the same secret reads the keys back
+	keyring := token.NewKeyring(token.NewHMACKey("other", []byte("other-secret-key")))
+	assert.NoError(t, NewKeyService(repo, keyring, keyCipher, "ES256", time.Hour, time.Minute).Load(ctx))
+	assert.Equal(t, "seed", keyring.Current().Id)
+
+	// one without it refuses to start
+	err = NewKeyService(repo, keyring, nil, "ES256", time.Hour, time.Minute).Load(ctx)
+	assert.ErrorIs(t, err, token.ErrKeyMaterialSealed)
+}
+
+func TestKeyServiceAdopt(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	repo := &fakeSigningKeyRepository{clock: &now}
+	seed := token.NewHMACKey("seed", []byte("test-secret-key"))
+	keyring := token.NewKeyring(seed)
+	s := &keyService{
+		repository:  repo,
+		keyring:     keyring,
+		algorithm:   "HS256",
+		overlap:     time.Hour,
+		reloadEvery: time.Minute,
+		now:         func() time.Time { return now },
+	}
+	assert.NoError(t, s.Load(ctx))
+
+	// the stored key is kept
+	adopted, err := s.Adopt(ctx, token.NewHMACKey("seed", []byte("test-secret-key")))
+	assert.NoError(t, err)
+	assert.False(t, adopted)
+	assert.Len(t, repo.keys, 1)
+
+	// a changed secret under the same id can't be told apart from the old
+	// key by the tokens, so it is refused
+	_, err = s.Adopt(ctx, token.NewHMACKey("seed", []byte("new-secret-key")))
+	assert.Error(t, err)
+	assert.Len(t, repo.keys, 1)
+
+	// a new key is rotated to and takes over after the reload interval
+	replacement := token.NewHMACKey("replacement", []byte("new-secret-key"))
+	adopted, err = s.Adopt(ctx, replacement)
+	assert.NoError(t, err)
+	assert.True(t, adopted)
+	assert.Len(t, repo.keys, 2)
+	assert.NotNil(t, repo.keys[0].RetiredAt)
+
+	now = now.Add(2 * time.Minute)
+	assert.NoError(t, s.Load(ctx))
+	assert.Equal(t, "replacement", keyring.Current().Id)
+
+	// and stays adopted after restarts, even once it has been rotated away
+	_, err = s.Rotate(ctx)
+	assert.NoError(t, err)
+	adopted, err = s.Adopt(ctx, replacement)
+	assert.NoError(t, err)
+	assert.False(t, adopted)
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/auth/totp"
+	"go-authentication-exercise/internal/user/entity"
+
+	"github.com/google/uuid"
+)
+
+const (
+	mfaChallengeTTL   = 5 * time.Minute
+	maxMFAAttempts    = 5
+	recoveryCodeCount = 10
+	// accepted clock drift of authenticator apps, in time steps
+	totpSkew = 1
+)
+
+var (
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled       = errors.New("two-factor authentication is not set up")
+	ErrInvalidMFACode      = errors.New("invalid two-factor authentication code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired login challenge")
+	ErrMFARequired         = errors.New("two-factor authentication code required")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// SetupTOTP generates a new authenticator secret for the user. It only
+// protects logins after ConfirmTOTP; until then setup can be restarted.
+func (s *authService) SetupTOTP(ctx context.Context, userId uuid.UUID) (*authEntity.TOTPSetup, error) {
+	user, err := s.repository.FindOneById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	existing, err := s.totpCredentials.FindOneByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil && existing.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.totpCredentials.Save(ctx, &authEntity.TOTPCredential{
+		UserId: userId,
+		Secret: secret,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := os.Getenv("APP_NAME")
+	if issuer == "" {
+		issuer = "go-authentication-exercise"
+	}
+
+	account := user.Email
+	if account == "" {
+		account = user.Username
+	}
+
+	return &authEntity.TOTPSetup{
+		Secret: credential.Secret,
+		URI:    totp.URI(issuer, account, credential.Secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication once the user proves their
+// app generates valid codes. It returns the recovery codes, which are only
+// shown this once.
+func (s *authService) ConfirmTOTP(ctx context.Context, userId uuid.UUID, code string) ([]string, error) {
+	credential, err := s.totpCredentials.FindOneByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if credential == nil {
+		return nil, ErrMFANotEnabled
+	}
+
+	if credential.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := totp.Validate(credential.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	confirmed, err := s.totpCredentials.Confirm(ctx, userId, step)
+	if err != nil {
+		return nil, err
+	}
+
+	if !confirmed {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	return s.replaceRecoveryCodes(ctx, userId)
+}
+
+// DisableTOTP turns two-factor authentication off. It takes the password
+// and a current code or recovery code, so a stolen session alone can't
+// remove the second factor.
+func (s *authService) DisableTOTP(ctx context.Context, userId uuid.UUID, password string, code string, recoveryCode string) error {
+	user, err := s.repository.FindOneById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if user == nil || !entity.CheckPassword(password, user.Password) {
+		return ErrIncorrectPassword
+	}
+
+	if err := s.verifySecondFactor(ctx, user, code, recoveryCode); err != nil {
+		return err
+	}
+
+	if err := s.totpCredentials.Delete(ctx, userId); err != nil {
+		return err
+	}
+
+	return s.recoveryCodes.DeleteAllForUser(ctx, userId)
+}
+
+// LoginMFA completes a login that returned a challenge, using either a code
+// from the authenticator app or one of the recovery codes
+func (s *authService) LoginMFA(ctx context.Context, challengeToken string, code string, recoveryCode string, client authEntity.Client) (*authEntity.TokenPair, error) {
+	challenge, err := s.mfaChallenges.FindOneByTokenHash(ctx, hashToken(challengeToken))
+	if err != nil {
+		return nil, err
+	}
+
+	if challenge == nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	// limits guessing, every attempt counts whether it succeeds or not
+	allowed, err := s.mfaChallenges.RecordAttempt(ctx, challenge.Id, maxMFAAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	if !allowed {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	user, err := s.repository.FindOneById(ctx, challenge.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	// a lockout from guessing codes holds for open challenges too
+	if err := s.throttle.Check(ctx, user.Username, client.IP); err != nil {
+		return nil, err
+	}
+
+	if err := s.verifySecondFactor(ctx, user, code, recoveryCode); err != nil {
+		// wrong codes count against the login like wrong passwords
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.throttle.Failure(ctx, user.Username, client.IP); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	completed, err := s.mfaChallenges.MarkUsed(ctx, challenge.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !completed {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	if err := s.throttle.Success(ctx, user.Username); err != nil {
+		return nil, err
+	}
+
+	return s.login(ctx, user, client)
+}
+
+// mfaChallenge starts the second login step when the user has two-factor
+// authentication enabled, and returns nil otherwise
+func (s *authService) mfaChallenge(ctx context.Context, user *entity.User) (*authEntity.LoginChallenge, error) {
+	credential, err := s.totpCredentials.FindOneByUserId(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if credential == nil || credential.ConfirmedAt == nil {
+		return nil, nil
+	}
+
+	challengeToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.mfaChallenges.Create(ctx, &authEntity.MFAChallenge{
+		Id:        uuid.New(),
+		UserId:    user.Id,
+		TokenHash: hashToken(challengeToken),
+		ExpiresAt: time.Now().Add(mfaChallengeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &authEntity.LoginChallenge{
+		MFARequired:    true,
+		ChallengeToken: challengeToken,
+		ExpiresIn:      int64(mfaChallengeTTL.Seconds()),
+		Methods:        []string{"totp", "recovery_code"},
+	}, nil
+}
+
+// verifySecondFactor checks a TOTP code, or a recovery code when no TOTP
+// code is given. Both can only be used once.
+func (s *authService) verifySecondFactor(ctx context.Context, user *entity.User, code string, recoveryCode string) error {
+	credential, err := s.totpCredentials.FindOneByUserId(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+
+	if credential == nil || credential.ConfirmedAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	if code != "" {
+		step, ok := totp.Validate(credential.Secret, code, time.Now(), totpSkew)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+
+		used, err := s.totpCredentials.UseStep(ctx, user.Id, step)
+		if err != nil {
+			return err
+		}
+
+		if !used {
+			return ErrInvalidMFACode
+		}
+
+		return nil
+	}
+
+	if recoveryCode == "" {
+		return ErrInvalidMFACode
+	}
+
+	consumed, err := s.recoveryCodes.Consume(ctx, user.Id, hashToken(normalizeRecoveryCode(recoveryCode)))
+	if err != nil {
+		return err
+	}
+
+	if !consumed {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// replaceRecoveryCodes generates a new set of recovery codes, invalidating
+// the previous ones. Only their hashes are stored.
+func (s *authService) replaceRecoveryCodes(ctx context.Context, userId uuid.UUID) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = hashToken(code)
+	}
+
+	if err := s.recoveryCodes.ReplaceAll(ctx, userId, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// normalizeRecoveryCode ignores case, dashes and spaces
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// Authenticate checks the credentials in a single step without starting a
+// session, for the OAuth login page. Users with two-factor authentication
+// must give a TOTP code or a recovery code as well.
+func (s *authService) Authenticate(ctx context.Context, username string, password string, code string, client authEntity.Client) (*entity.User, error) {
+	user, err := s.checkCredentials(ctx, username, password, client)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.totpCredentials.FindOneByUserId(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if credential == nil || credential.ConfirmedAt == nil {
+		if err := s.throttle.Success(ctx, username); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	if code == "" {
+		return nil, ErrMFARequired
+	}
+
+	// TOTP codes are all digits, recovery codes are longer
+	totpCode, recoveryCode := "", code
+	if len(code) == 6 && strings.Trim(code, "0123456789") == "" {
+		totpCode, recoveryCode = code, ""
+	}
+
+	if err := s.verifySecondFactor(ctx, user, totpCode, recoveryCode); err != nil {
+		// wrong codes count against the login like wrong passwords
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.throttle.Failure(ctx, username, client.IP); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	if err := s.throttle.Success(ctx, username); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	authRepository "go-authentication-exercise/internal/auth/repository"
+	userEntity "go-authentication-exercise/internal/user/entity"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTOTPCredentialRepository has a confirmed credential for every user
+type fakeTOTPCredentialRepository struct {
+	authRepository.TOTPCredentialRepository
+}
+
+func (r *fakeTOTPCredentialRepository) FindOneByUserId(ctx context.Context, userId uuid.UUID) (*authEntity.TOTPCredential, error) {
+	confirmedAt := time.Now()
+	return &authEntity.TOTPCredential{UserId: userId, Secret: "JBSWY3DPEHPK3PXP", ConfirmedAt: &confirmedAt}, nil
+}
+
+// fakeRecoveryCodeRepository knows no recovery codes
+type fakeRecoveryCodeRepository struct {
+	authRepository.RecoveryCodeRepository
+}
+
+func (r *fakeRecoveryCodeRepository) Consume(ctx context.Context, userId uuid.UUID, codeHash string) (bool, error) {
+	return false, nil
+}
+
+// fakeMFAChallengeRepository keeps challenges in memory
+type fakeMFAChallengeRepository struct {
+	authRepository.MFAChallengeRepository
+	challenges map[string]*authEntity.MFAChallenge
+}
+
+func (r *fakeMFAChallengeRepository) Create(ctx context.Context, c *authEntity.MFAChallenge) (*authEntity.MFAChallenge, error) {
+	r.challenges[c.TokenHash] = c
+	return c, nil
+}
+
+func (r *fakeMFAChallengeRepository) FindOneByTokenHash(ctx context.Context, tokenHash string) (*authEntity.MFAChallenge, error) {
+	return r.challenges[tokenHash], nil
+}
+
+func (r *fakeMFAChallengeRepository) RecordAttempt(ctx context.Context, id uuid.UUID, maxAttempts int) (bool, error) {
+	for _, c := range r.challenges {
+		if c.Id == id {
+			c.Attempts++
+			return c.Attempts <= maxAttempts, nil
+		}
+	}
+	return false, nil
+}
+
+func TestLoginMFALockout(t *testing.T) {
+	hash, err := hashPassword("password123")
+	require.NoError(t, err)
+
+	now := time.Now()
+	s := &authService{
+		repository:      &fakeUserRepository{users: []*userEntity.User{{Id: uuid.New(), Username: "testuser", Password: hash}}},
+		totpCredentials: &fakeTOTPCredentialRepository{},
+		recoveryCodes:   &fakeRecoveryCodeRepository{},
+		mfaChallenges:   &fakeMFAChallengeRepository{challenges: map[string]*authEntity.MFAChallenge{}},
+		throttle:        newTestThrottle(&now),
+	}
+	ctx := context.Background()
+
+	// every attempt starts a new challenge with the right password, after
+	// waiting out the backoff
+	for i := 0; i < 10; i++ {
+		now = now.Add(5 * time.Second)
+
+		_, challenge, err := s.Login(ctx, "testuser", "password123", authEntity.Client{})
+		require.NoError(t, err, "attempt %d", i+1)
+		require.NotNil(t, challenge)
+
+		_, err = s.LoginMFA(ctx, challenge.ChallengeToken, "", "wrong-recovery-code", authEntity.Client{})
+		assert.Equal(t, ErrInvalidMFACode, err)
+	}
+
+	now = now.Add(5 * time.Second)
+	_, _, err = s.Login(ctx, "testuser", "password123", authEntity.Client{})
+
+	var retry *RetryAfterError
+	require.ErrorAs(t, err, &retry)
+	assert.Equal(t, ErrAccountLocked, retry.Err)
+}
@@ -0,0 +1,444 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	apiKeyService "go-authentication-exercise/internal/apikey/service"
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	authRepository "go-authentication-exercise/internal/auth/repository"
+	"go-authentication-exercise/internal/auth/token"
+	"go-authentication-exercise/internal/user/entity"
+	"go-authentication-exercise/internal/user/repository"
+	"go-authentication-exercise/internal/util"
+
+	"github.com/google/uuid"
+)
+
+// CodeChallengeMethodS256 is the only PKCE method accepted, plain
+// challenges would leak the verifier with the authorization request
+const CodeChallengeMethodS256 = "S256"
+
+var (
+	ErrOAuthClientNotFound = errors.New("OAuth client not found")
+	ErrInvalidRedirectURI  = errors.New("redirect URI is not registered for the client")
+	ErrRedirectURIRequired = errors.New("public clients need at least one redirect URI")
+)
+
+// OAuthError is an error response of the OAuth endpoints, Code is one of
+// the error codes of RFC 6749
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code string, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// OAuthConfig holds the settings of the OAuth authorization server.
+// OpenID Connect is only offered with an Issuer, the device grant with a
+// DeviceVerificationURI.
+type OAuthConfig struct {
+	CodeTTL               time.Duration
+	Issuer                string
+	DeviceCodeTTL         time.Duration
+	DeviceInterval        time.Duration
+	DeviceVerificationURI string
+}
+
+// OAuthConfigFromEnv reads OAUTH_CODE_TTL, OAUTH_DEVICE_CODE_TTL,
+// OAUTH_DEVICE_INTERVAL and OAUTH_DEVICE_VERIFICATION_URI. The issuer is
+// the one of the access tokens, the verification page defaults to the
+// /device page of the issuer.
+func OAuthConfigFromEnv(tokenConfig token.Config) OAuthConfig {
+	verificationURI := os.Getenv("OAUTH_DEVICE_VERIFICATION_URI")
+	if verificationURI == "" && tokenConfig.Issuer != "" {
+		verificationURI = strings.TrimSuffix(tokenConfig.Issuer, "/") + "/device"
+	}
+
+	return OAuthConfig{
+		CodeTTL:               util.GetEnvDuration("OAUTH_CODE_TTL", time.Minute),
+		Issuer:                tokenConfig.Issuer,
+		DeviceCodeTTL:         util.GetEnvDuration("OAUTH_DEVICE_CODE_TTL", 10*time.Minute),
+		DeviceInterval:        util.GetEnvDuration("OAUTH_DEVICE_INTERVAL", 5*time.Second),
+		DeviceVerificationURI: verificationURI,
+	}
+}
+
+// ClientRateLimiter limits the requests of a client once it has been
+// authenticated, a middleware.RateLimiter does
+type ClientRateLimiter interface {
+	Allow(key string) (bool, time.Duration)
+}
+
+type oauthService struct {
+	auth    AuthService
+	users   repository.UserRepository
+	clients authRepository.OAuthClientRepository
+	codes   authRepository.AuthorizationCodeRepository
+	devices authRepository.DeviceCodeRepository
+
+	refreshTokens authRepository.RefreshTokenRepository
+	sessions      authRepository.SessionRepository
+	revokedTokens authRepository.RevokedTokenRepository
+	apiKeys       apiKeyService.APIKeyService
+
+	introspectLimit ClientRateLimiter
+
+	keyring     *token.Keyring
+	tokenConfig token.Config
+	config      OAuthConfig
+}
+
+func NewOAuthService(
+	auth AuthService,
+	users repository.UserRepository,
+	clients authRepository.OAuthClientRepository,
+	codes authRepository.AuthorizationCodeRepository,
+	devices authRepository.DeviceCodeRepository,
+	refreshTokens authRepository.RefreshTokenRepository,
+	sessions authRepository.SessionRepository,
+	revokedTokens authRepository.RevokedTokenRepository,
+	apiKeys apiKeyService.APIKeyService,
+	introspectLimit ClientRateLimiter,
+	keyring *token.Keyring,
+	tokenConfig token.Config,
+	config OAuthConfig,
+) OAuthService {
+	return &oauthService{
+		auth:    auth,
+		users:   users,
+		clients: clients,
+		codes:   codes,
+		devices: devices,
+
+		refreshTokens: refreshTokens,
+		sessions:      sessions,
+		revokedTokens: revokedTokens,
+		apiKeys:       apiKeys,
+
+		introspectLimit: introspectLimit,
+
+		keyring:     keyring,
+		tokenConfig: tokenConfig,
+		config:      config,
+	}
+}
+
+// RegisterClient registers a client. Redirect URIs must be absolute and
+// without a fragment, they are matched exactly. Confidential clients get a
+// secret that is only returned here.
+func (s *oauthService) RegisterClient(ctx context.Context, name string, redirectURIs []string, scopes []string, confidential bool) (*authEntity.NewOAuthClient, error) {
+	if !confidential && len(redirectURIs) == 0 {
+		return nil, ErrRedirectURIRequired
+	}
+
+	for _, redirectURI := range redirectURIs {
+		parsed, err := url.Parse(redirectURI)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			return nil, ErrInvalidRedirectURI
+		}
+	}
+
+	if redirectURIs == nil {
+		redirectURIs = []string{}
+	}
+
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	var secret string
+	if confidential {
+		var err error
+		secret, err = generateRefreshToken()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := s.clients.Create(ctx, &authEntity.OAuthClient{
+		Id:           uuid.New().String(),
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		Scopes:       scopes,
+		SecretHash:   hashSecret(secret),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &authEntity.NewOAuthClient{OAuthClient: client, ClientSecret: secret}, nil
+}
+
+// ListClients returns every registered client
+func (s *oauthService) ListClients(ctx context.Context) ([]*authEntity.OAuthClient, error) {
+	return s.clients.List(ctx)
+}
+
+// DeleteClient removes the client, its users are signed out of it
+func (s *oauthService) DeleteClient(ctx context.Context, clientId string) error {
+	deleted, err := s.clients.Delete(ctx, clientId)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ErrOAuthClientNotFound
+	}
+
+	return nil
+}
+
+// CheckAuthorization validates an authorization request and returns the
+// client and the requested scopes. Unknown clients and redirect URIs are
+// reported as ErrOAuthClientNotFound and ErrInvalidRedirectURI, which must
+// not be redirected; everything else is an *OAuthError for the client.
+func (s *oauthService) CheckAuthorization(ctx context.Context, req *authEntity.AuthorizationRequest) (*authEntity.OAuthClient, []string, error) {
+	client, err := s.clients.FindOneById(ctx, req.ClientId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if client == nil {
+		return nil, nil, ErrOAuthClientNotFound
+	}
+
+	if !contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, nil, ErrInvalidRedirectURI
+	}
+
+	if req.ResponseType != "code" {
+		return nil, nil, oauthError("unsupported_response_type", "only the code response type is supported")
+	}
+
+	if req.CodeChallengeMethod != CodeChallengeMethodS256 || len(req.CodeChallenge) != 43 {
+		return nil, nil, oauthError("invalid_request", "a PKCE code challenge with method S256 is required")
+	}
+
+	scopes, err := s.userScopes(client, req.Scope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, scopes, nil
+}
+
+// userScopes parses the scope of a grant on behalf of a user, every scope
+// must be allowed for the client
+func (s *oauthService) userScopes(client *authEntity.OAuthClient, scope string) ([]string, error) {
+	scopes := []string{}
+	for _, name := range strings.Fields(scope) {
+		if !contains(client.Scopes, name) {
+			return nil, oauthError("invalid_scope", "scope "+name+" is not allowed for the client")
+		}
+		if !contains(scopes, name) {
+			scopes = append(scopes, name)
+		}
+	}
+
+	if contains(scopes, ScopeOpenID) {
+		if err := s.checkOpenID(); err != nil {
+			return nil, oauthError("invalid_scope", err.Error())
+		}
+	}
+
+	return scopes, nil
+}
+
+// Authorize issues an authorization code for the request, once the user
+// has logged in and approved it
+func (s *oauthService) Authorize(ctx context.Context, req *authEntity.AuthorizationRequest, user *entity.User) (string, error) {
+	_, scopes, err := s.CheckAuthorization(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := generateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.codes.Create(ctx, &authEntity.AuthorizationCode{
+		Id:            uuid.New(),
+		ClientId:      req.ClientId,
+		UserId:        user.Id,
+		CodeHash:      hashToken(code),
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		Nonce:         req.Nonce,
+		AuthTime:      time.Now(),
+		ExpiresAt:     time.Now().Add(s.config.CodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// ExchangeCode redeems an authorization code for a token pair in a new
+// session of the client. The code is consumed even if the exchange fails.
+func (s *oauthService) ExchangeCode(ctx context.Context, clientId string, clientSecret string, code string, redirectURI string, codeVerifier string, client authEntity.Client) (*authEntity.TokenPair, error) {
+	if code == "" || codeVerifier == "" {
+		return nil, oauthError("invalid_request", "code and code_verifier are required")
+	}
+
+	if _, err := s.authenticateClient(ctx, clientId, clientSecret); err != nil {
+		return nil, err
+	}
+
+	stored, err := s.codes.Consume(ctx, hashToken(code))
+	if err != nil {
+		return nil, err
+	}
+
+	invalidGrant := oauthError("invalid_grant", "invalid or expired authorization code")
+	if stored == nil || stored.ClientId != clientId || stored.RedirectURI != redirectURI {
+		return nil, invalidGrant
+	}
+
+	if !verifyCodeChallenge(codeVerifier, stored.CodeChallenge) {
+		return nil, invalidGrant
+	}
+
+	user, err := s.users.FindOneById(ctx, stored.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, invalidGrant
+	}
+
+	tokens, err := s.auth.StartClientSession(ctx, user, client, &authEntity.ClientGrant{
+		ClientId: clientId,
+		Scopes:   stored.Scopes,
+		Nonce:    stored.Nonce,
+		AuthTime: stored.AuthTime,
+	})
+	if errors.Is(err, ErrAccountDisabled) {
+		return nil, invalidGrant
+	}
+
+	return tokens, err
+}
+
+// RefreshToken rotates a refresh token that was issued to the client
+func (s *oauthService) RefreshToken(ctx context.Context, clientId string, clientSecret string, refreshToken string) (*authEntity.TokenPair, error) {
+	if refreshToken == "" {
+		return nil, oauthError("invalid_request", "refresh_token is required")
+	}
+
+	if _, err := s.authenticateClient(ctx, clientId, clientSecret); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.auth.Refresh(ctx, refreshToken, clientId)
+	if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrRefreshTokenReused) || errors.Is(err, ErrAccountDisabled) {
+		return nil, oauthError("invalid_grant", err.Error())
+	}
+
+	return tokens, err
+}
+
+// ClientCredentials issues a confidential client an access token for
+// itself. Without a scope the client gets all of its scopes, except the
+// OpenID Connect ones which need a user.
+func (s *oauthService) ClientCredentials(ctx context.Context, clientId string, clientSecret string, scope string) (*authEntity.TokenPair, error) {
+	client, err := s.authenticateClient(ctx, clientId, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.Confidential {
+		return nil, oauthError("unauthorized_client", "only confidential clients may use the client credentials grant")
+	}
+
+	requested := strings.Fields(scope)
+	defaulted := len(requested) == 0
+	if defaulted {
+		requested = client.Scopes
+	}
+
+	scopes := []string{}
+	for _, name := range requested {
+		if name == ScopeOpenID || name == ScopeProfile {
+			if defaulted {
+				continue
+			}
+			return nil, oauthError("invalid_scope", "scope "+name+" needs a user")
+		}
+		if !contains(client.Scopes, name) {
+			return nil, oauthError("invalid_scope", "scope "+name+" is not allowed for the client")
+		}
+		if !contains(scopes, name) {
+			scopes = append(scopes, name)
+		}
+	}
+
+	return s.auth.IssueClientToken(ctx, clientId, scopes)
+}
+
+// authenticateClient returns the client of a token request. Confidential
+// clients must present their secret.
+func (s *oauthService) authenticateClient(ctx context.Context, clientId string, clientSecret string) (*authEntity.OAuthClient, error) {
+	client, err := s.clients.FindOneById(ctx, clientId)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		return nil, oauthError("invalid_client", "unknown client")
+	}
+
+	if client.Confidential && subtle.ConstantTimeCompare([]byte(hashSecret(clientSecret)), []byte(client.SecretHash)) != 1 {
+		return nil, oauthError("invalid_client", "invalid client secret")
+	}
+
+	return client, nil
+}
+
+// hashSecret hashes a client secret for storage, public clients have none
+func hashSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	return hashToken(secret)
+}
+
+// verifyCodeChallenge checks a PKCE verifier against its S256 challenge
+func verifyCodeChallenge(verifier string, challenge string) bool {
+	// RFC 7636 verifiers are 43 to 128 characters long
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,460 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	authRepository "go-authentication-exercise/internal/auth/repository"
+	"go-authentication-exercise/internal/auth/token"
+	userEntity "go-authentication-exercise/internal/user/entity"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// a PKCE verifier and its S256 challenge
+const (
+	testCodeVerifier  = "dBjftJeZ4CVP-mJ92K1qUVHeI6Fr6hzhwqdAVoi_dH4"
+	testCodeChallenge = "z92fqn7-MrtuNLCjr2ThIncee_04tWHNKN_YmjQB0GE"
+)
+
+// fakeOAuthClientRepository looks clients up in a fixed list
+type fakeOAuthClientRepository struct {
+	authRepository.OAuthClientRepository
+	clients []*authEntity.OAuthClient
+}
+
+func (r *fakeOAuthClientRepository) FindOneById(ctx context.Context, id string) (*authEntity.OAuthClient, error) {
+	for _, c := range r.clients {
+		if c.Id == id {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeOAuthClientRepository) Create(ctx context.Context, m *authEntity.OAuthClient) (*authEntity.OAuthClient, error) {
+	m.Confidential = m.SecretHash != ""
+	r.clients = append(r.clients, m)
+	return m, nil
+}
+
+// fakeAuthorizationCodeRepository keeps codes in memory
+type fakeAuthorizationCodeRepository struct {
+	codes map[string]*authEntity.AuthorizationCode
+}
+
+func (r *fakeAuthorizationCodeRepository) Create(ctx context.Context, m *authEntity.AuthorizationCode) (*authEntity.AuthorizationCode, error) {
+	r.codes[m.CodeHash] = m
+	return m, nil
+}
+
+func (r *fakeAuthorizationCodeRepository) Consume(ctx context.Context, codeHash string) (*authEntity.AuthorizationCode, error) {
+	code, ok := r.codes[codeHash]
+	if !ok || code.UsedAt != nil || !time.Now().Before(code.ExpiresAt) {
+		return nil, nil
+	}
+
+	now := time.Now()
+	code.UsedAt = &now
+	return code, nil
+}
+
+// fakeSessionStarter records the client sessions it starts and the client
+// tokens it issues
+type fakeSessionStarter struct {
+	AuthService
+	grant    *authEntity.ClientGrant
+	clientId string
+	scopes   []string
+}
+
+func (s *fakeSessionStarter) IssueClientToken(ctx context.Context, clientId string, scopes []string) (*authEntity.TokenPair, error) {
+	s.clientId, s.scopes = clientId, scopes
+	return &authEntity.TokenPair{AccessToken: "client-token"}, nil
+}
+
+func (s *fakeSessionStarter) StartClientSession(ctx context.Context, user *userEntity.User, client authEntity.Client, grant *authEntity.ClientGrant) (*authEntity.TokenPair, error) {
+	s.grant = grant
+	return &authEntity.TokenPair{AccessToken: "access-token", Scope: "users:read"}, nil
+}
+
+func newOAuthTestService(user *userEntity.User) (*oauthService, *fakeSessionStarter) {
+	auth := &fakeSessionStarter{}
+	// ID tokens need a key that is published in the JWKS
+	key, err := token.GenerateKey("ES256")
+	if err != nil {
+		panic(err)
+	}
+
+	return &oauthService{
+		auth:  auth,
+		users: &fakeUserRepository{users: []*userEntity.User{user}},
+		clients: &fakeOAuthClientRepository{clients: []*authEntity.OAuthClient{{
+			Id:           "spa",
+			Name:         "Single page app",
+			RedirectURIs: []string{"https://app.example.com/callback"},
+			Scopes:       []string{"users:read", "users:write", ScopeOpenID, ScopeProfile},
+		}, {
+			Id:           "worker",
+			Name:         "Background worker",
+			Scopes:       []string{"users:read", "users:write", ScopeOpenID},
+			Confidential: true,
+			SecretHash:   hashToken("worker-secret"),
+		}}},
+		codes:   &fakeAuthorizationCodeRepository{codes: map[string]*authEntity.AuthorizationCode{}},
+		devices: &fakeDeviceCodeRepository{},
+		keyring: token.NewKeyring(key),
+		config: OAuthConfig{
+			CodeTTL:               time.Minute,
+			Issuer:                "https://auth.example.com",
+			DeviceCodeTTL:         10 * time.Minute,
+			DeviceInterval:        5 * time.Second,
+			DeviceVerificationURI: "https://auth.example.com/device",
+		},
+	}, auth
+}
+
+func validAuthorizationRequest() *authEntity.AuthorizationRequest {
+	return &authEntity.AuthorizationRequest{
+		ResponseType:        "code",
+		ClientId:            "spa",
+		RedirectURI:         "https://app.example.com/callback",
+		Scope:               "users:read",
+		State:               "xyz",
+		CodeChallenge:       testCodeChallenge,
+		CodeChallengeMethod: CodeChallengeMethodS256,
+		Nonce:               "n-0S6_WzA2Mj",
+	}
+}
+
+func TestCheckAuthorization(t *testing.T) {
+	tests := []struct {
+		name           string
+		modify         func(*authEntity.AuthorizationRequest)
+		expectedError  error
+		expectedCode   string
+		expectedScopes []string
+	}{
+		{
+			name:           "Valid request",
+			modify:         func(req *authEntity.AuthorizationRequest) {},
+			expectedScopes: []string{"users:read"},
+		},
+		{
+			name:           "Duplicate scopes",
+			modify:         func(req *authEntity.AuthorizationRequest) { req.Scope = "users:read users:write users:read" },
+			expectedScopes: []string{"users:read", "users:write"},
+		},
+		{
+			name:           "OpenID Connect",
+			modify:         func(req *authEntity.AuthorizationRequest) { req.Scope = "openid profile" },
+			expectedScopes: []string{ScopeOpenID, ScopeProfile},
+		},
+		{
+			name:          "Unknown client",
+			modify:        func(req *authEntity.AuthorizationRequest) { req.ClientId = "unknown" },
+			expectedError: ErrOAuthClientNotFound,
+		},
+		{
+			name:          "Redirect URI with extra path",
+			modify:        func(req *authEntity.AuthorizationRequest) { req.RedirectURI += "/evil" },
+			expectedError: ErrInvalidRedirectURI,
+		},
+		{
+			name:          "Missing redirect URI",
+			modify:        func(req *authEntity.AuthorizationRequest) { req.RedirectURI = "" },
+			expectedError: ErrInvalidRedirectURI,
+		},
+		{
+			name:         "Implicit grant",
+			modify:       func(req *authEntity.AuthorizationRequest) { req.ResponseType = "token" },
+			expectedCode: "unsupported_response_type",
+		},
+		{
+			name:         "Missing PKCE",
+			modify:       func(req *authEntity.AuthorizationRequest) { req.CodeChallenge, req.CodeChallengeMethod = "", "" },
+			expectedCode: "invalid_request",
+		},
+		{
+			name:         "Plain PKCE",
+			modify:       func(req *authEntity.AuthorizationRequest) { req.CodeChallengeMethod = "plain" },
+			expectedCode: "invalid_request",
+		},
+		{
+			name:         "Scope not allowed for the client",
+			modify:       func(req *authEntity.AuthorizationRequest) { req.Scope = "roles:write" },
+			expectedCode: "invalid_scope",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newOAuthTestService(&userEntity.User{Id: uuid.New()})
+			req := validAuthorizationRequest()
+			tt.modify(req)
+
+			client, scopes, err := s.CheckAuthorization(context.Background(), req)
+
+			switch {
+			case tt.expectedError != nil:
+				assert.Equal(t, tt.expectedError, err)
+			case tt.expectedCode != "":
+				var oauthErr *OAuthError
+				if assert.ErrorAs(t, err, &oauthErr) {
+					assert.Equal(t, tt.expectedCode, oauthErr.Code)
+				}
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, "spa", client.Id)
+				assert.Equal(t, tt.expectedScopes, scopes)
+			}
+		})
+	}
+}
+
+func TestExchangeCode(t *testing.T) {
+	user := &userEntity.User{Id: uuid.New()}
+
+	tests := []struct {
+		name         string
+		clientId     string
+		redirectURI  string
+		codeVerifier string
+		expectedCode string
+	}{
+		{
+			name:         "Valid exchange",
+			clientId:     "spa",
+			redirectURI:  "https://app.example.com/callback",
+			codeVerifier: testCodeVerifier,
+		},
+		{
+			name:         "Wrong verifier",
+			clientId:     "spa",
+			redirectURI:  "https://app.example.com/callback",
+			codeVerifier: testCodeVerifier[1:] + "x",
+			expectedCode: "invalid_grant",
+		},
+		{
+			name:         "Different redirect URI",
+			clientId:     "spa",
+			redirectURI:  "https://app.example.com/other",
+			codeVerifier: testCodeVerifier,
+			expectedCode: "invalid_grant",
+		},
+		{
+			name:         "Unknown client",
+			clientId:     "unknown",
+			redirectURI:  "https://app.example.com/callback",
+			codeVerifier: testCodeVerifier,
+			expectedCode: "invalid_client",
+		},
+		{
+			name:         "Missing verifier",
+			clientId:     "spa",
+			redirectURI:  "https://app.example.com/callback",
+			expectedCode: "invalid_request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, auth := newOAuthTestService(user)
+			code, err := s.Authorize(context.Background(), validAuthorizationRequest(), user)
+			require.NoError(t, err)
+
+			tokens, err := s.ExchangeCode(context.Background(), tt.clientId, "", code, tt.redirectURI, tt.codeVerifier, authEntity.Client{})
+
+			if tt.expectedCode != "" {
+				var oauthErr *OAuthError
+				if assert.ErrorAs(t, err, &oauthErr) {
+					assert.Equal(t, tt.expectedCode, oauthErr.Code)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, "access-token", tokens.AccessToken)
+			assert.Equal(t, "spa", auth.grant.ClientId)
+			assert.Equal(t, []string{"users:read"}, auth.grant.Scopes)
+			assert.Equal(t, "n-0S6_WzA2Mj", auth.grant.Nonce)
+			assert.WithinDuration(t, time.Now(), auth.grant.AuthTime, time.Minute)
+
+			// codes can only be exchanged once
+			_, err = s.ExchangeCode(context.Background(), tt.clientId, "", code, tt.redirectURI, tt.codeVerifier, authEntity.Client{})
+			var oauthErr *OAuthError
+			if assert.ErrorAs(t, err, &oauthErr) {
+				assert.Equal(t, "invalid_grant", oauthErr.Code)
+			}
+		})
+	}
+}
+
+func TestRegisterClientRedirectURIs(t *testing.T) {
+	s, _ := newOAuthTestService(&userEntity.User{Id: uuid.New()})
+
+	for _, redirectURI := range []string{"/callback", "https://app.example.com/callback#fragment"} {
+		_, err := s.RegisterClient(context.Background(), "app", []string{redirectURI}, nil, false)
+		assert.Equal(t, ErrInvalidRedirectURI, err, redirectURI)
+	}
+
+	_, err := s.RegisterClient(context.Background(), "app", nil, nil, false)
+	assert.Equal(t, ErrRedirectURIRequired, err)
+}
+
+func TestRegisterConfidentialClient(t *testing.T) {
+	s, _ := newOAuthTestService(&userEntity.User{Id: uuid.New()})
+
+	client, err := s.RegisterClient(context.Background(), "worker", nil, []string{"users:read"}, true)
+	require.NoError(t, err)
+
+	assert.True(t, client.Confidential)
+	assert.NotEmpty(t, client.ClientSecret)
+	assert.Equal(t, hashToken(client.ClientSecret), client.SecretHash)
+	assert.Equal(t, []string{}, client.RedirectURIs)
+
+	tokens, err := s.ClientCredentials(context.Background(), client.Id, client.ClientSecret, "")
+	assert.NoError(t, err)
+	assert.NotNil(t, tokens)
+}
+
+func TestClientCredentials(t *testing.T) {
+	tests := []struct {
+		name           string
+		clientId       string
+		clientSecret   string
+		scope          string
+		expectedScopes []string
+		expectedCode   string
+	}{
+		{
+			name:           "All client scopes by default",
+			clientId:       "worker",
+			clientSecret:   "worker-secret",
+			expectedScopes: []string{"users:read", "users:write"},
+		},
+		{
+			name:           "Requested scope",
+			clientId:       "worker",
+			clientSecret:   "worker-secret",
+			scope:          "users:read users:read",
+			expectedScopes: []string{"users:read"},
+		},
+		{
+			name:         "Scope not allowed for the client",
+			clientId:     "worker",
+			clientSecret: "worker-secret",
+			scope:        "roles:write",
+			expectedCode: "invalid_scope",
+		},
+		{
+			name:         "OpenID Connect scope",
+			clientId:     "worker",
+			clientSecret: "worker-secret",
+			scope:        "openid",
+			expectedCode: "invalid_scope",
+		},
+		{
+			name:         "Wrong secret",
+			clientId:     "worker",
+			clientSecret: "wrong",
+			expectedCode: "invalid_client",
+		},
+		{
+			name:         "Missing secret",
+			clientId:     "worker",
+			expectedCode: "invalid_client",
+		},
+		{
+			name:         "Public client",
+			clientId:     "spa",
+			expectedCode: "unauthorized_client",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, auth := newOAuthTestService(&userEntity.User{Id: uuid.New()})
+
+			_, err := s.ClientCredentials(context.Background(), tt.clientId, tt.clientSecret, tt.scope)
+
+			if tt.expectedCode != "" {
+				var oauthErr *OAuthError
+				if assert.ErrorAs(t, err, &oauthErr) {
+					assert.Equal(t, tt.expectedCode, oauthErr.Code)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.clientId, auth.clientId)
+			assert.Equal(t, tt.expectedScopes, auth.scopes)
+		})
+	}
+}
+
+func TestCheckAuthorizationWithoutIssuer(t *testing.T) {
+	s, _ := newOAuthTestService(&userEntity.User{Id: uuid.New()})
+	s.config.Issuer = ""
+
+	req := validAuthorizationRequest()
+	req.Scope = "openid"
+	_, _, err := s.CheckAuthorization(context.Background(), req)
+
+	var oauthErr *OAuthError
+	if assert.ErrorAs(t, err, &oauthErr) {
+		assert.Equal(t, "invalid_scope", oauthErr.Code)
+	}
+
+	_, err = s.OpenIDConfiguration()
+	assert.Equal(t, ErrIssuerNotConfigured, err)
+}
+
+func TestCheckAuthorizationWithSymmetricKey(t *testing.T) {
+	s, _ := newOAuthTestService(&userEntity.User{Id: uuid.New()})
+	s.keyring = token.NewKeyring(token.NewHMACKey("test", []byte("secret")))
+
+	req := validAuthorizationRequest()
+	req.Scope = "openid"
+	_, _, err := s.CheckAuthorization(context.Background(), req)
+
+	var oauthErr *OAuthError
+	if assert.ErrorAs(t, err, &oauthErr) {
+		assert.Equal(t, "invalid_scope", oauthErr.Code)
+		assert.Equal(t, ErrSymmetricSigningKey.Error(), oauthErr.Description)
+	}
+
+	// other scopes are still granted
+	req.Scope = "users:read"
+	_, _, err = s.CheckAuthorization(context.Background(), req)
+	assert.NoError(t, err)
+
+	_, err = s.OpenIDConfiguration()
+	assert.Equal(t, ErrSymmetricSigningKey, err)
+}
+
+func TestIssueClientToken(t *testing.T) {
+	keyring := token.NewKeyring(token.NewHMACKey("test", []byte("secret")))
+	s := &authService{keyring: keyring, tokenConfig: token.Config{AccessTokenTTL: 15 * time.Minute}}
+
+	tokens, err := s.IssueClientToken(context.Background(), "worker", []string{"users:read"})
+	require.NoError(t, err)
+	assert.Empty(t, tokens.RefreshToken)
+	assert.Equal(t, "users:read", tokens.Scope)
+
+	parsed, err := jwt.Parse(tokens.AccessToken, keyring.Keyfunc)
+	require.NoError(t, err)
+	claims := parsed.Claims.(jwt.MapClaims)
+
+	// the client is the subject, there is no user
+	assert.Equal(t, "worker", claims["sub"])
+	assert.Equal(t, "worker", claims["client_id"])
+	assert.Equal(t, "users:read", claims["scope"])
+	assert.NotContains(t, claims, "username")
+	assert.NotContains(t, claims, "sid")
+}
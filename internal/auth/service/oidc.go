@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/user/entity"
+
+	"github.com/google/uuid"
+)
+
+// OpenID Connect scopes, clients must be registered with them like with
+// any other scope
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+)
+
+var (
+	ErrIssuerNotConfigured = errors.New("OpenID Connect requires JWT_ISSUER to be set")
+	ErrSymmetricSigningKey = errors.New("OpenID Connect requires an asymmetric JWT_ALGORITHM, clients can't verify ID tokens signed with a shared secret")
+)
+
+// checkOpenID tells why OpenID Connect can't be offered. ID tokens are
+// signed with the current key, which clients must find in the JWKS.
+func (s *oauthService) checkOpenID() error {
+	if s.config.Issuer == "" {
+		return ErrIssuerNotConfigured
+	}
+
+	if s.keyring.Current().Symmetric() {
+		return ErrSymmetricSigningKey
+	}
+
+	return nil
+}
+
+// OpenIDConfiguration returns the discovery document, its endpoints are
+// relative to the issuer
+func (s *oauthService) OpenIDConfiguration() (*authEntity.OpenIDConfiguration, error) {
+	if err := s.checkOpenID(); err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimSuffix(s.config.Issuer, "/")
+	config := &authEntity.OpenIDConfiguration{
+		Issuer:                            s.config.Issuer,
+		AuthorizationEndpoint:             base + "/oauth/authorize",
+		TokenEndpoint:                     base + "/oauth/token",
+		UserInfoEndpoint:                  base + "/userinfo",
+		IntrospectionEndpoint:             base + "/oauth/introspect",
+		RevocationEndpoint:                base + "/oauth/revoke",
+		JWKSURI:                           base + "/.well-known/jwks.json",
+		ScopesSupported:                   []string{ScopeOpenID, ScopeProfile},
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{s.keyring.Current().Method.Alg()},
+		TokenEndpointAuthMethodsSupported: []string{"none", "client_secret_basic", "client_secret_post"},
+		CodeChallengeMethodsSupported:     []string{CodeChallengeMethodS256},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "preferred_username", "updated_at"},
+	}
+
+	if s.config.DeviceVerificationURI != "" {
+		config.DeviceAuthorizationEndpoint = base + "/oauth/device/code"
+		config.GrantTypesSupported = append(config.GrantTypesSupported, GrantTypeDeviceCode)
+	}
+
+	return config, nil
+}
+
+// UserInfo returns the claims about the user that the scopes of the access
+// token allow, it needs the openid scope
+func (s *oauthService) UserInfo(ctx context.Context, userId uuid.UUID, scopes []string) (*authEntity.UserInfo, error) {
+	if !contains(scopes, ScopeOpenID) {
+		return nil, oauthError("insufficient_scope", "the openid scope is required")
+	}
+
+	user, err := s.users.FindOneById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, oauthError("invalid_token", "user not found")
+	}
+
+	info := &authEntity.UserInfo{Subject: user.Id.String()}
+	if contains(scopes, ScopeProfile) {
+		info.Name = user.Fullname
+		info.PreferredUsername = user.Username
+		info.UpdatedAt = user.UpdatedAt.Unix()
+	}
+
+	return info, nil
+}
+
+// profileClaims returns the claims of the profile scope
+func profileClaims(user *entity.User) map[string]interface{} {
+	return map[string]interface{}{
+		"name":               user.Fullname,
+		"preferred_username": user.Username,
+		"updated_at":         user.UpdatedAt.Unix(),
+	}
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/auth/token"
+	userEntity "go-authentication-exercise/internal/user/entity"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateIdToken(t *testing.T) {
+	keyring := token.NewKeyring(token.NewHMACKey("test", []byte("secret")))
+	s := &authService{
+		keyring:     keyring,
+		tokenConfig: token.Config{Issuer: "https://auth.example.com", AccessTokenTTL: 15 * time.Minute},
+	}
+	user := &userEntity.User{Id: uuid.New(), Username: "testuser", Fullname: "Test User", UpdatedAt: time.Unix(1700000000, 0)}
+	authTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name          string
+		scopes        []string
+		nonce         string
+		expectProfile bool
+	}{
+		{
+			name:   "openid only",
+			scopes: []string{ScopeOpenID},
+			nonce:  "nonce",
+		},
+		{
+			name:          "with profile, without nonce",
+			scopes:        []string{ScopeOpenID, ScopeProfile},
+			expectProfile: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &authEntity.Session{Id: uuid.New(), ClientId: "spa", Scopes: tt.scopes, AuthTime: authTime}
+
+			idToken, err := s.generateIdToken(user, session, tt.nonce)
+			require.NoError(t, err)
+
+			parsed, err := jwt.Parse(idToken, keyring.Keyfunc)
+			require.NoError(t, err)
+			claims := parsed.Claims.(jwt.MapClaims)
+
+			assert.Equal(t, user.Id.String(), claims["sub"])
+			assert.Equal(t, "spa", claims["aud"])
+			assert.Equal(t, "https://auth.example.com", claims["iss"])
+			assert.Equal(t, float64(authTime.Unix()), claims["auth_time"])
+			// ID tokens must not pass as access tokens
+			assert.NotContains(t, claims, "jti")
+
+			if tt.nonce != "" {
+				assert.Equal(t, tt.nonce, claims["nonce"])
+			} else {
+				assert.NotContains(t, claims, "nonce")
+			}
+
+			if tt.expectProfile {
+				assert.Equal(t, "testuser", claims["preferred_username"])
+				assert.Equal(t, "Test User", claims["name"])
+			} else {
+				assert.NotContains(t, claims, "name")
+			}
+		})
+	}
+}
+
+func TestIdTokenVerifiesWithJWKS(t *testing.T) {
+	key, err := token.GenerateKey("ES256")
+	require.NoError(t, err)
+	s := &authService{
+		keyring:     token.NewKeyring(key),
+		tokenConfig: token.Config{Issuer: "https://auth.example.com", AccessTokenTTL: 15 * time.Minute},
+	}
+	user := &userEntity.User{Id: uuid.New(), Username: "testuser"}
+	session := &authEntity.Session{Id: uuid.New(), ClientId: "spa", Scopes: []string{ScopeOpenID}, AuthTime: time.Now()}
+
+	idToken, err := s.generateIdToken(user, session, "nonce")
+	require.NoError(t, err)
+
+	// a client only has the published key set
+	published, err := json.Marshal(s.keyring.JWKS())
+	require.NoError(t, err)
+	var jwks token.JWKS
+	require.NoError(t, json.Unmarshal(published, &jwks))
+
+	parsed, err := jwt.Parse(idToken, func(t *jwt.Token) (interface{}, error) {
+		for _, jwk := range jwks.Keys {
+			if jwk.Kid == t.Header["kid"] && jwk.Alg == t.Method.Alg() && jwk.Kty == "EC" {
+				return &ecdsa.PublicKey{Curve: elliptic.P256(), X: decodeBigInt(jwk.X), Y: decodeBigInt(jwk.Y)}, nil
+			}
+		}
+		return nil, errors.New("key not in the JWKS")
+	})
+	require.NoError(t, err)
+	assert.True(t, parsed.Valid)
+	assert.Equal(t, user.Id.String(), parsed.Claims.(jwt.MapClaims)["sub"])
+}
+
+func TestIssueTokensWithoutPublishedKey(t *testing.T) {
+	user := &userEntity.User{Id: uuid.New(), Username: "testuser"}
+	session := &authEntity.Session{Id: uuid.New(), UserId: user.Id, ClientId: "spa", Scopes: []string{ScopeOpenID}, AuthTime: time.Now()}
+	key, err := token.GenerateKey("ES256")
+	require.NoError(t, err)
+
+	s := &authService{
+		refreshTokens: &fakeRefreshTokenRepository{},
+		keyring:       token.NewKeyring(key),
+		tokenConfig:   token.Config{Issuer: "https://auth.example.com", AccessTokenTTL: 15 * time.Minute},
+	}
+
+	tokens, err := s.issueTokens(context.Background(), user, session, "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.IDToken)
+
+	// a session granted openid before the switch to a shared secret gets
+	// no ID token that clients couldn't verify
+	s.keyring = token.NewKeyring(token.NewHMACKey("test", []byte("secret")))
+	tokens, err = s.issueTokens(context.Background(), user, session, "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.Empty(t, tokens.IDToken)
+}
+
+func decodeBigInt(value string) *big.Int {
+	b, _ := base64.RawURLEncoding.DecodeString(value)
+	return new(big.Int).SetBytes(b)
+}
+
+func TestUserInfo(t *testing.T) {
+	user := &userEntity.User{Id: uuid.New(), Username: "testuser", Fullname: "Test User", UpdatedAt: time.Unix(1700000000, 0)}
+	s, _ := newOAuthTestService(user)
+
+	tests := []struct {
+		name         string
+		userId       uuid.UUID
+		scopes       []string
+		expected     *authEntity.UserInfo
+		expectedCode string
+	}{
+		{
+			name:     "openid only",
+			userId:   user.Id,
+			scopes:   []string{ScopeOpenID},
+			expected: &authEntity.UserInfo{Subject: user.Id.String()},
+		},
+		{
+			name:   "with profile",
+			userId: user.Id,
+			scopes: []string{ScopeOpenID, ScopeProfile},
+			expected: &authEntity.UserInfo{
+				Subject:           user.Id.String(),
+				Name:              "Test User",
+				PreferredUsername: "testuser",
+				UpdatedAt:         1700000000,
+			},
+		},
+		{
+			name:         "without openid",
+			userId:       user.Id,
+			scopes:       []string{ScopeProfile, "users:read"},
+			expectedCode: "insufficient_scope",
+		},
+		{
+			name:         "unknown user",
+			userId:       uuid.New(),
+			scopes:       []string{ScopeOpenID},
+			expectedCode: "invalid_token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := s.UserInfo(context.Background(), tt.userId, tt.scopes)
+
+			if tt.expectedCode != "" {
+				var oauthErr *OAuthError
+				if assert.ErrorAs(t, err, &oauthErr) {
+					assert.Equal(t, tt.expectedCode, oauthErr.Code)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, info)
+		})
+	}
+}
+
+func TestOpenIDConfiguration(t *testing.T) {
+	s, _ := newOAuthTestService(&userEntity.User{Id: uuid.New()})
+
+	config, err := s.OpenIDConfiguration()
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://auth.example.com", config.Issuer)
+	assert.Equal(t, "https://auth.example.com/oauth/authorize", config.AuthorizationEndpoint)
+	assert.Equal(t, "https://auth.example.com/userinfo", config.UserInfoEndpoint)
+	assert.Equal(t, "https://auth.example.com/oauth/introspect", config.IntrospectionEndpoint)
+	assert.Equal(t, "https://auth.example.com/.well-known/jwks.json", config.JWKSURI)
+	assert.Equal(t, []string{"ES256"}, config.IDTokenSigningAlgValuesSupported)
+	// public clients send no secret, confidential ones send it with Basic
+	// authentication or in the form
+	assert.Equal(t, []string{"none", "client_secret_basic", "client_secret_post"}, config.TokenEndpointAuthMethodsSupported)
+	assert.Equal(t, []string{"authorization_code", "refresh_token", "client_credentials", GrantTypeDeviceCode}, config.GrantTypesSupported)
+}
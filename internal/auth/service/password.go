@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/user/entity"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrPasswordPolicy    = errors.New("password doesn't meet the policy")
+)
+
+// ChangePassword replaces the user's password after checking the current
+// one. Every session and token issued before the change stops working, and
+// a new session is started so the caller stays logged in.
+func (s *authService) ChangePassword(ctx context.Context, userId uuid.UUID, currentPassword string, newPassword string, client authEntity.Client) (*authEntity.TokenPair, error) {
+	user, err := s.repository.FindOneById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || !entity.CheckPassword(currentPassword, user.Password) {
+		return nil, ErrIncorrectPassword
+	}
+
+	if err := checkPasswordPolicy(newPassword); err != nil {
+		return nil, err
+	}
+
+	if newPassword == currentPassword {
+		return nil, fmt.Errorf("%w: the new password must be different", ErrPasswordPolicy)
+	}
+
+	hashedPassword, err := hashPassword(newPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	// tokens only carry second precision, so anything issued from this
+	// second onwards remains valid
+	validAfter := time.Now().Truncate(time.Second)
+	if err := s.repository.UpdatePassword(ctx, user.Id, hashedPassword, validAfter); err != nil {
+		return nil, err
+	}
+
+	if err := s.sessions.RevokeAllForUser(ctx, user.Id); err != nil {
+		return nil, err
+	}
+
+	if err := s.refreshTokens.RevokeAllForUser(ctx, user.Id); err != nil {
+		return nil, err
+	}
+
+	// a pending reset link would otherwise undo the change
+	if err := s.resetTokens.InvalidateAllForUser(ctx, user.Id); err != nil {
+		return nil, err
+	}
+
+	return s.login(ctx, user, client)
+}
+
+// checkPasswordPolicy enforces the length limits on new passwords. The
+// minimum length is read from PASSWORD_MIN_LENGTH.
+func checkPasswordPolicy(password string) error {
+	minLength, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH"))
+	if err != nil || minLength < 1 {
+		minLength = 5
+	}
+
+	if len([]rune(password)) < minLength {
+		return fmt.Errorf("%w: it must be at least %d characters", ErrPasswordPolicy, minLength)
+	}
+
+	if len(password) > entity.MaxPasswordLength {
+		return fmt.Errorf("%w: it must be at most %d bytes", ErrPasswordPolicy, entity.MaxPasswordLength)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/mailer"
+	"go-authentication-exercise/internal/user/entity"
+	"go-authentication-exercise/internal/util"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// PasswordResetConfig holds the settings of the forgot password flow. The
+// token is appended to URL as the `token` query parameter; without a URL
+// the bare token is mailed.
+type PasswordResetConfig struct {
+	URL string
+	TTL time.Duration
+}
+
+// PasswordResetConfigFromEnv reads PASSWORD_RESET_URL and PASSWORD_RESET_TTL
+func PasswordResetConfigFromEnv() PasswordResetConfig {
+	return PasswordResetConfig{
+		URL: os.Getenv("PASSWORD_RESET_URL"),
+		TTL: util.GetEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+	}
+}
+
+// ForgotPassword mails a password reset link to the user's email address.
+// Unknown usernames are silently ignored, and the mail is sent in the
+// background so the response time doesn't reveal whether the account
+// exists either.
+func (s *authService) ForgotPassword(ctx context.Context, username string) error {
+	user, err := s.repository.FindOneByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return nil
+	}
+
+	s.inBackground("password reset", user, s.sendPasswordReset)
+	return nil
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword. Like
+// a password change, it logs the user out everywhere.
+func (s *authService) ResetPassword(ctx context.Context, resetToken string, newPassword string) error {
+	// check the policy first so a rejected password doesn't burn the token
+	if err := checkPasswordPolicy(newPassword); err != nil {
+		return err
+	}
+
+	stored, err := s.resetTokens.Consume(ctx, hashToken(resetToken))
+	if err != nil {
+		return err
+	}
+
+	if stored == nil {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.repository.FindOneById(ctx, stored.UserId)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return ErrInvalidResetToken
+	}
+
+	hashedPassword, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repository.UpdatePassword(ctx, user.Id, hashedPassword, time.Now().Truncate(time.Second)); err != nil {
+		return err
+	}
+
+	if err := s.resetTokens.InvalidateAllForUser(ctx, user.Id); err != nil {
+		return err
+	}
+
+	if err := s.sessions.RevokeAllForUser(ctx, user.Id); err != nil {
+		return err
+	}
+
+	return s.refreshTokens.RevokeAllForUser(ctx, user.Id)
+}
+
+// SendPasswordReset mails the user a reset link right away and reports a
+// failed delivery, unlike ForgotPassword which mails it in the background
+func (s *authService) SendPasswordReset(ctx context.Context, user *entity.User) error {
+	return s.sendPasswordReset(ctx, user)
+}
+
+// sendPasswordReset replaces any outstanding reset token of the user with a
+// new one and mails it
+func (s *authService) sendPasswordReset(ctx context.Context, user *entity.User) error {
+	if user.Email == "" {
+		return errors.New("user has no email address")
+	}
+
+	if err := s.resetTokens.InvalidateAllForUser(ctx, user.Id); err != nil {
+		return err
+	}
+
+	resetToken, err := generateRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.resetTokens.Create(ctx, &authEntity.PasswordResetToken{
+		Id:        uuid.New(),
+		UserId:    user.Id,
+		TokenHash: hashToken(resetToken),
+		ExpiresAt: time.Now().Add(s.resetConfig.TTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link, err := tokenLink(s.resetConfig.URL, resetToken)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"We received a request to reset your password. Use the following within %s to choose a new one:\n\n"+
+			"%s\n\n"+
+			"If you didn't request this, you can ignore this email.\n",
+			user.Fullname, s.resetConfig.TTL, link),
+	})
+}
@@ -2,10 +2,20 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
-	"os"
+	"strings"
+	"sync"
 	"time"
 
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	authRepository "go-authentication-exercise/internal/auth/repository"
+	"go-authentication-exercise/internal/auth/token"
+	"go-authentication-exercise/internal/mailer"
+	roleRepository "go-authentication-exercise/internal/role/repository"
 	"go-authentication-exercise/internal/user/entity"
 	"go-authentication-exercise/internal/user/repository"
 
@@ -14,72 +24,373 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrInvalidCredentials  = errors.New("invalid username or password")
+	ErrAccountDisabled     = errors.New("account is disabled")
+	ErrUsernameTaken       = errors.New("username exists")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
 type authService struct {
-	repository repository.UserRepository
+	repository    repository.UserRepository
+	roles         roleRepository.RoleRepository
+	refreshTokens authRepository.RefreshTokenRepository
+	sessions      authRepository.SessionRepository
+	revokedTokens authRepository.RevokedTokenRepository
+	resetTokens   authRepository.PasswordResetTokenRepository
+	keyring       *token.Keyring
+	tokenConfig   token.Config
+	mailer        mailer.Mailer
+	resetConfig   PasswordResetConfig
+
+	verificationTokens authRepository.EmailVerificationTokenRepository
+	verificationConfig EmailVerificationConfig
+
+	totpCredentials authRepository.TOTPCredentialRepository
+	recoveryCodes   authRepository.RecoveryCodeRepository
+	mfaChallenges   authRepository.MFAChallengeRepository
+
+	throttle   LoginThrottle
+	signupMode SignupMode
 }
 
-func NewService(repo repository.UserRepository) AuthService {
+func NewService(
+	repo repository.UserRepository,
+	roles roleRepository.RoleRepository,
+	refreshTokens authRepository.RefreshTokenRepository,
+	sessions authRepository.SessionRepository,
+	revokedTokens authRepository.RevokedTokenRepository,
+	resetTokens authRepository.PasswordResetTokenRepository,
+	verificationTokens authRepository.EmailVerificationTokenRepository,
+	totpCredentials authRepository.TOTPCredentialRepository,
+	recoveryCodes authRepository.RecoveryCodeRepository,
+	mfaChallenges authRepository.MFAChallengeRepository,
+	throttle LoginThrottle,
+	signupMode SignupMode,
+	keyring *token.Keyring,
+	tokenConfig token.Config,
+	mailer mailer.Mailer,
+	resetConfig PasswordResetConfig,
+	verificationConfig EmailVerificationConfig,
+) AuthService {
 	return &authService{
-		repository: repo,
+		repository:    repo,
+		roles:         roles,
+		refreshTokens: refreshTokens,
+		sessions:      sessions,
+		revokedTokens: revokedTokens,
+		resetTokens:   resetTokens,
+		keyring:       keyring,
+		tokenConfig:   tokenConfig,
+		mailer:        mailer,
+		resetConfig:   resetConfig,
+
+		verificationTokens: verificationTokens,
+		verificationConfig: verificationConfig,
+
+		totpCredentials: totpCredentials,
+		recoveryCodes:   recoveryCodes,
+		mfaChallenges:   mfaChallenges,
+
+		throttle:   throttle,
+		signupMode: signupMode,
 	}
 }
 
-func (s *authService) Signup(ctx context.Context, username string, fullname string, password string) (*entity.User, error) {
-	// get user
-	user, err := s.repository.FindOneByUsername(ctx, username)
+// Signup creates a user and mails the email verification link. In quiet
+// mode no user is returned, and taken usernames or emails are reported by
+// mail instead, so the response doesn't reveal existing accounts.
+func (s *authService) Signup(ctx context.Context, username string, email string, fullname string, password string) (*entity.User, error) {
+	if err := checkPasswordPolicy(password); err != nil {
+		return nil, err
+	}
+
+	// hash the password first, so a taken username or email doesn't answer
+	// faster than a new account
+	hashedPassword, err := hashPassword(password)
 	if err != nil {
 		return nil, err
 	}
 
-	if user != nil {
-		return nil, errors.New("username exists")
+	byUsername, err := s.repository.FindOneByUsername(ctx, username)
+	if err != nil {
+		return nil, err
 	}
 
-	// hash the password
-	hashedPassword, err := hashPassword(password)
+	byEmail, err := s.repository.FindOneByEmail(ctx, email)
 	if err != nil {
 		return nil, err
 	}
 
+	if byUsername != nil || byEmail != nil {
+		if s.signupMode == SignupModeQuiet {
+			s.notifySignupConflict(byEmail, username, email, fullname)
+			return nil, nil
+		}
+
+		if byUsername != nil {
+			return nil, ErrUsernameTaken
+		}
+		return nil, ErrEmailTaken
+	}
+
 	newUser := &entity.User{
 		Id:       uuid.New(),
 		Username: username,
 		Fullname: fullname,
+		Email:    email,
 		Password: hashedPassword,
 	}
 
+	// a concurrent signup may have taken the username or email since the
+	// checks above
 	res, err := s.repository.Create(ctx, newUser)
-	if err != nil {
+	switch {
+	case errors.Is(err, repository.ErrDuplicateUsername), errors.Is(err, repository.ErrDuplicateEmail):
+		if s.signupMode == SignupModeQuiet {
+			return nil, nil
+		}
+		if errors.Is(err, repository.ErrDuplicateUsername) {
+			return nil, ErrUsernameTaken
+		}
+		return nil, ErrEmailTaken
+	case err != nil:
 		return nil, err
 	}
 
+	s.inBackground("email verification", res, s.sendEmailVerification)
+
+	if s.signupMode == SignupModeQuiet {
+		return nil, nil
+	}
+
 	return res, nil
 }
 
-func (s *authService) Login(ctx context.Context, username string, password string) (string, error) {
+// Login checks the credentials and returns a token pair. Users with
+// two-factor authentication get a challenge instead, to be completed with
+// LoginMFA. Failed attempts are counted per username and client IP, and
+// each login starts a new session for the client.
+func (s *authService) Login(ctx context.Context, username string, password string, client authEntity.Client) (*authEntity.TokenPair, *authEntity.LoginChallenge, error) {
+	user, err := s.checkCredentials(ctx, username, password, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	challenge, err := s.mfaChallenge(ctx, user)
+	if err != nil || challenge != nil {
+		return nil, challenge, err
+	}
+
+	if err := s.throttle.Success(ctx, username); err != nil {
+		return nil, nil, err
+	}
+
+	// success, now generate the tokens in a new session
+	tokens, err := s.login(ctx, user, client)
+	return tokens, nil, err
+}
+
+// checkCredentials returns the user if the password is right and the
+// account may log in, counting failures like Login. The failures are only
+// reset once the login is complete, so the second factor can't be guessed
+// with ever new challenges.
+func (s *authService) checkCredentials(ctx context.Context, username string, password string, client authEntity.Client) (*entity.User, error) {
+	if err := s.throttle.Check(ctx, username, client.IP); err != nil {
+		return nil, err
+	}
+
 	// get user
 	user, err := s.repository.FindOneByUsername(ctx, username)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	// unknown usernames are checked against a dummy hash, so they take as
+	// long as a wrong password and get the same error
+	hash := dummyPasswordHash()
+	if user != nil {
+		hash = user.Password
+	}
+
+	if !entity.CheckPassword(password, hash) || user == nil {
+		if err := s.throttle.Failure(ctx, username, client.IP); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
 	}
 
-	if user == nil {
-		return "", errors.New("user doesn't exist")
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
 	}
 
-	// Simulate user login
-	loginSuccess := checkPasswordHash(password, user.Password)
-	if !loginSuccess {
-		return "", errors.New("invalid login")
+	if s.verificationConfig.Required && user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
 	}
 
-	// success, now generate the token
-	accessToken, err := generateJwtAccessToken(user.Username)
+	return user, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented
+// token is consumed; presenting it again revokes every token in its family.
+// Tokens issued to an OAuth client can only be refreshed by that client,
+// clientId is empty for first-party logins.
+func (s *authService) Refresh(ctx context.Context, refreshToken string, clientId string) (*authEntity.TokenPair, error) {
+	stored, err := s.refreshTokens.FindOneByTokenHash(ctx, hashToken(refreshToken))
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if stored == nil || stored.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// a token that was already rotated is being replayed
+	if stored.UsedAt != nil {
+		return nil, s.revokeFamily(ctx, stored.FamilyId)
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// consume the token, losing the race means someone else replayed it
+	marked, err := s.refreshTokens.MarkUsed(ctx, stored.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !marked {
+		return nil, s.revokeFamily(ctx, stored.FamilyId)
+	}
+
+	user, err := s.repository.FindOneById(ctx, stored.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	// the user was signed out everywhere after the token was issued
+	if user == nil || (user.TokensValidAfter != nil && stored.CreatedAt.Before(*user.TokensValidAfter)) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := s.resumeSession(ctx, user, stored.FamilyId, clientId)
+	if err != nil {
+		return nil, err
 	}
 
-	return accessToken, nil
+	// ID tokens issued on refresh carry no nonce
+	return s.issueTokens(ctx, user, session, "")
+}
+
+// Logout revokes the access token identified by jti until it expires, and
+// its session. If a refresh token of the user is given, its session is
+// revoked as well; refresh tokens of other users are ignored.
+func (s *authService) Logout(ctx context.Context, userId uuid.UUID, jti string, expiresAt time.Time, sessionId uuid.UUID, refreshToken string) error {
+	if err := s.revokedTokens.Revoke(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+
+	if sessionId != uuid.Nil {
+		if err := s.revokeSession(ctx, sessionId); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	stored, err := s.refreshTokens.FindOneByTokenHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		return err
+	}
+
+	if stored == nil || stored.UserId != userId || stored.FamilyId == sessionId {
+		return nil
+	}
+
+	return s.revokeSession(ctx, stored.FamilyId)
+}
+
+// issueTokens generates an access token and persists a new refresh token,
+// along with an ID token when the session was granted the openid scope
+// belonging to the family of the session
+func (s *authService) issueTokens(ctx context.Context, user *entity.User, session *authEntity.Session, nonce string) (*authEntity.TokenPair, error) {
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+
+	accessToken, err := s.generateJwtAccessToken(ctx, user, session)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.refreshTokens.Create(ctx, &authEntity.RefreshToken{
+		Id:        uuid.New(),
+		UserId:    user.Id,
+		FamilyId:  session.Id,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.tokenConfig.RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := &authEntity.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.tokenConfig.AccessTokenTTL.Seconds()),
+	}
+	if session.ClientId != "" {
+		tokens.Scope = strings.Join(session.Scopes, " ")
+	}
+
+	// only with a key that clients can look up in the JWKS, sessions granted
+	// openid before the server switched to a shared secret get none
+	if session.ClientId != "" && contains(session.Scopes, ScopeOpenID) && !s.keyring.Current().Symmetric() {
+		tokens.IDToken, err = s.generateIdToken(user, session, nonce)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tokens, nil
+}
+
+// IssueClientToken issues an access token to an OAuth client acting on its
+// own behalf. It has the client as subject, no user and no refresh token.
+func (s *authService) IssueClientToken(ctx context.Context, clientId string, scopes []string) (*authEntity.TokenPair, error) {
+	claims := s.tokenConfig.NewClaims(clientId, time.Now())
+	claims["client_id"] = clientId
+	claims["scope"] = strings.Join(scopes, " ")
+
+	accessToken, err := s.keyring.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authEntity.TokenPair{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.tokenConfig.AccessTokenTTL.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+func (s *authService) revokeFamily(ctx context.Context, familyId uuid.UUID) error {
+	if err := s.refreshTokens.RevokeFamily(ctx, familyId); err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
 }
 
 // Function to hash the user's password
@@ -92,30 +403,101 @@ func hashPassword(password string) (string, error) {
 	return string(hashedPassword), nil
 }
 
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// dummyPasswordHash returns a bcrypt hash with the default cost that no
+// password matches
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		hash, err := hashPassword(uuid.New().String())
+		if err != nil {
+			panic(err)
+		}
+		dummyHash = hash
+	})
+
+	return dummyHash
+}
+
 // Function to check if the provided password matches the hashed password
-func checkPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// JWKS returns the public keys that verify access tokens
+func (s *authService) JWKS() *token.JWKS {
+	return s.keyring.JWKS()
 }
 
-func generateJwtAccessToken(username string) (string, error) {
-	secretKey := os.Getenv("JWT_SECRET_KEY")
+func (s *authService) generateJwtAccessToken(ctx context.Context, user *entity.User, session *authEntity.Session) (string, error) {
+	// registered claims, with the user id as subject
+	claims := s.tokenConfig.NewClaims(user.Id.String(), time.Now())
+	claims["username"] = user.Username
+	claims["sid"] = session.Id.String()
+
+	// tokens of OAuth clients are limited to the granted scopes and carry
+	// no roles
+	if session.ClientId != "" {
+		claims["client_id"] = session.ClientId
+		claims["scope"] = strings.Join(session.Scopes, " ")
+	} else {
+		access, err := s.roles.FindAccessByUserId(ctx, user.Id)
+		if err != nil {
+			return "", err
+		}
+		claims["roles"] = access.Roles
+	}
+
+	// Create the token and sign it with the current key
+	signedToken, err := s.keyring.Sign(claims)
+	if err != nil {
+		return "", err
+	}
 
-	expirationTime := time.Now().Add(24 * time.Hour)
+	return signedToken, nil
+}
 
+// generateIdToken creates the OpenID Connect ID token of a client session.
+// It has no jti, so it is never accepted as an access token.
+func (s *authService) generateIdToken(user *entity.User, session *authEntity.Session, nonce string) (string, error) {
+	now := time.Now()
 	claims := jwt.MapClaims{
-		"username": username,
-		"exp":      expirationTime.Unix(),
+		"sub":       user.Id.String(),
+		"aud":       session.ClientId,
+		"iat":       now.Unix(),
+		"exp":       now.Add(s.tokenConfig.AccessTokenTTL).Unix(),
+		"auth_time": session.AuthTime.Unix(),
 	}
 
-	// Create the token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if s.tokenConfig.Issuer != "" {
+		claims["iss"] = s.tokenConfig.Issuer
+	}
 
-	// Sign the token with the secret key
-	signedToken, err := token.SignedString([]byte(secretKey))
-	if err != nil {
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	if contains(session.Scopes, ScopeProfile) {
+		for name, value := range profileClaims(user) {
+			claims[name] = value
+		}
+	}
+
+	return s.keyring.Sign(claims)
+}
+
+// generateRefreshToken returns a random, URL-safe opaque token. It is used
+// for password reset tokens as well.
+func generateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
 
-	return signedToken, nil
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex encoded SHA-256 digest used to store opaque tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
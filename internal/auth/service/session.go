@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/user/entity"
+
+	"github.com/google/uuid"
+)
+
+// maxUserAgentLength caps the stored user agent
+const maxUserAgentLength = 512
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// ListSessions returns the user's sessions that are neither revoked nor
+// idle for longer than a refresh token lives
+func (s *authService) ListSessions(ctx context.Context, userId uuid.UUID) ([]*authEntity.Session, error) {
+	return s.sessions.ListActiveByUserId(ctx, userId, time.Now().Add(-s.tokenConfig.RefreshTokenTTL))
+}
+
+// RevokeSession signs one of the user's sessions out. Its refresh tokens
+// stop working at once, its access tokens on their next use.
+func (s *authService) RevokeSession(ctx context.Context, userId uuid.UUID, sessionId uuid.UUID) error {
+	session, err := s.sessions.FindOneById(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+
+	if session == nil || session.UserId != userId || session.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+
+	return s.revokeSession(ctx, sessionId)
+}
+
+// StartClientSession starts a session for the user on behalf of an OAuth
+// client, whose tokens are limited to the granted scopes
+func (s *authService) StartClientSession(ctx context.Context, user *entity.User, client authEntity.Client, grant *authEntity.ClientGrant) (*authEntity.TokenPair, error) {
+	return s.startSession(ctx, user, client, grant)
+}
+
+// login starts a session for the user on client and issues its first
+// token pair
+func (s *authService) login(ctx context.Context, user *entity.User, client authEntity.Client) (*authEntity.TokenPair, error) {
+	return s.startSession(ctx, user, client, &authEntity.ClientGrant{AuthTime: time.Now()})
+}
+
+func (s *authService) startSession(ctx context.Context, user *entity.User, client authEntity.Client, grant *authEntity.ClientGrant) (*authEntity.TokenPair, error) {
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	session, err := s.sessions.Create(ctx, &authEntity.Session{
+		Id:        uuid.New(),
+		UserId:    user.Id,
+		UserAgent: userAgent,
+		IP:        client.IP,
+		ClientId:  grant.ClientId,
+		Scopes:    grant.Scopes,
+		AuthTime:  grant.AuthTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user, session, grant.Nonce)
+}
+
+// resumeSession checks that the session of a refresh token family is still
+// active and belongs to clientId, and marks it as seen. Families from
+// before sessions existed get a session without client details.
+func (s *authService) resumeSession(ctx context.Context, user *entity.User, familyId uuid.UUID, clientId string) (*authEntity.Session, error) {
+	session, err := s.sessions.FindOneById(ctx, familyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil {
+		if clientId != "" {
+			return nil, ErrInvalidRefreshToken
+		}
+		return s.sessions.Create(ctx, &authEntity.Session{Id: familyId, UserId: user.Id, AuthTime: time.Now()})
+	}
+
+	if session.RevokedAt != nil || session.UserId != user.Id || session.ClientId != clientId {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if err := s.sessions.Touch(ctx, familyId, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// revokeSession revokes the session and its refresh token family
+func (s *authService) revokeSession(ctx context.Context, sessionId uuid.UUID) error {
+	if err := s.sessions.Revoke(ctx, sessionId); err != nil {
+		return err
+	}
+
+	return s.refreshTokens.RevokeFamily(ctx, sessionId)
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	authRepository "go-authentication-exercise/internal/auth/repository"
+	userEntity "go-authentication-exercise/internal/user/entity"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSessionRepository keeps sessions in memory
+type fakeSessionRepository struct {
+	authRepository.SessionRepository
+	sessions map[uuid.UUID]*authEntity.Session
+}
+
+func (r *fakeSessionRepository) Create(ctx context.Context, m *authEntity.Session) (*authEntity.Session, error) {
+	r.sessions[m.Id] = m
+	return m, nil
+}
+
+func (r *fakeSessionRepository) FindOneById(ctx context.Context, id uuid.UUID) (*authEntity.Session, error) {
+	return r.sessions[id], nil
+}
+
+func (r *fakeSessionRepository) Touch(ctx context.Context, id uuid.UUID, at time.Time) error {
+	r.sessions[id].LastSeenAt = at
+	return nil
+}
+
+func (r *fakeSessionRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	r.sessions[id].RevokedAt = &now
+	return nil
+}
+
+// fakeRefreshTokenRepository looks tokens up in a fixed list and records
+// the revoked families
+type fakeRefreshTokenRepository struct {
+	authRepository.RefreshTokenRepository
+	tokens          []*authEntity.RefreshToken
+	revokedFamilies []uuid.UUID
+}
+
+func (r *fakeRefreshTokenRepository) FindOneByTokenHash(ctx context.Context, tokenHash string) (*authEntity.RefreshToken, error) {
+	for _, t := range r.tokens {
+		if t.TokenHash == tokenHash {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeRefreshTokenRepository) Create(ctx context.Context, m *authEntity.RefreshToken) (*authEntity.RefreshToken, error) {
+	r.tokens = append(r.tokens, m)
+	return m, nil
+}
+
+func (r *fakeRefreshTokenRepository) RevokeFamily(ctx context.Context, familyId uuid.UUID) error {
+	r.revokedFamilies = append(r.revokedFamilies, familyId)
+	return nil
+}
+
+func TestRevokeSession(t *testing.T) {
+	userId := uuid.New()
+	revokedAt := time.Now()
+	own := &authEntity.Session{Id: uuid.New(), UserId: userId}
+	foreign := &authEntity.Session{Id: uuid.New(), UserId: uuid.New()}
+	revoked := &authEntity.Session{Id: uuid.New(), UserId: userId, RevokedAt: &revokedAt}
+
+	tests := []struct {
+		name          string
+		sessionId     uuid.UUID
+		expectedError error
+	}{
+		{name: "Own session", sessionId: own.Id},
+		{name: "Session of another user", sessionId: foreign.Id, expectedError: ErrSessionNotFound},
+		{name: "Already revoked", sessionId: revoked.Id, expectedError: ErrSessionNotFound},
+		{name: "Unknown session", sessionId: uuid.New(), expectedError: ErrSessionNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := &fakeSessionRepository{sessions: map[uuid.UUID]*authEntity.Session{
+				own.Id:     {Id: own.Id, UserId: own.UserId},
+				foreign.Id: foreign,
+				revoked.Id: revoked,
+			}}
+			refreshTokens := &fakeRefreshTokenRepository{}
+			s := &authService{sessions: sessions, refreshTokens: refreshTokens}
+
+			err := s.RevokeSession(context.Background(), userId, tt.sessionId)
+			assert.Equal(t, tt.expectedError, err)
+
+			// the session's refresh tokens go with it
+			if tt.expectedError == nil {
+				assert.NotNil(t, sessions.sessions[tt.sessionId].RevokedAt)
+				assert.Equal(t, []uuid.UUID{tt.sessionId}, refreshTokens.revokedFamilies)
+			} else {
+				assert.Empty(t, refreshTokens.revokedFamilies)
+			}
+		})
+	}
+}
+
+func TestResumeSession(t *testing.T) {
+	user := &userEntity.User{Id: uuid.New()}
+	revokedAt := time.Now()
+	active := &authEntity.Session{Id: uuid.New(), UserId: user.Id}
+	revoked := &authEntity.Session{Id: uuid.New(), UserId: user.Id, RevokedAt: &revokedAt}
+	legacy := uuid.New()
+
+	sessions := &fakeSessionRepository{sessions: map[uuid.UUID]*authEntity.Session{
+		active.Id:  active,
+		revoked.Id: revoked,
+	}}
+	s := &authService{sessions: sessions}
+
+	session, err := s.resumeSession(context.Background(), user, active.Id, "")
+	assert.NoError(t, err)
+	assert.Equal(t, active, session)
+	assert.False(t, active.LastSeenAt.IsZero())
+
+	_, err = s.resumeSession(context.Background(), user, revoked.Id, "")
+	assert.Equal(t, ErrInvalidRefreshToken, err)
+
+	// first-party sessions can't be refreshed by an OAuth client
+	_, err = s.resumeSession(context.Background(), user, active.Id, "some-client")
+	assert.Equal(t, ErrInvalidRefreshToken, err)
+
+	// refresh tokens from before sessions existed get one
+	_, err = s.resumeSession(context.Background(), user, legacy, "")
+	assert.NoError(t, err)
+	assert.Equal(t, user.Id, sessions.sessions[legacy].UserId)
+}
+
+func TestLogoutIgnoresRefreshTokensOfOtherUsers(t *testing.T) {
+	userId := uuid.New()
+	own := &authEntity.Session{Id: uuid.New(), UserId: userId}
+	foreign := &authEntity.Session{Id: uuid.New(), UserId: uuid.New()}
+	sessions := &fakeSessionRepository{sessions: map[uuid.UUID]*authEntity.Session{
+		own.Id:     own,
+		foreign.Id: foreign,
+	}}
+	refreshTokens := &fakeRefreshTokenRepository{tokens: []*authEntity.RefreshToken{
+		{TokenHash: hashToken("foreign-token"), UserId: foreign.UserId, FamilyId: foreign.Id},
+	}}
+	s := &authService{
+		sessions:      sessions,
+		refreshTokens: refreshTokens,
+		revokedTokens: &fakeRevokedTokenRepository{revoked: map[string]time.Time{}},
+	}
+
+	err := s.Logout(context.Background(), userId, "token-id", time.Now().Add(time.Hour), own.Id, "foreign-token")
+	assert.NoError(t, err)
+	assert.NotNil(t, sessions.sessions[own.Id].RevokedAt)
+	assert.Nil(t, sessions.sessions[foreign.Id].RevokedAt)
+	assert.Equal(t, []uuid.UUID{own.Id}, refreshTokens.revokedFamilies)
+}
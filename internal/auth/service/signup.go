@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go-authentication-exercise/internal/mailer"
+	"go-authentication-exercise/internal/user/entity"
+)
+
+// SignupMode decides whether signup reveals taken usernames and emails
+type SignupMode string
+
+const (
+	// SignupModeOpen rejects taken usernames and emails with an error
+	SignupModeOpen SignupMode = "open"
+	// SignupModeQuiet answers every signup the same way and tells the
+	// owner of the email address by mail instead
+	SignupModeQuiet SignupMode = "quiet"
+)
+
+// SignupModeFromEnv reads SIGNUP_MODE, defaulting to open
+func SignupModeFromEnv() SignupMode {
+	if SignupMode(os.Getenv("SIGNUP_MODE")) == SignupModeQuiet {
+		return SignupModeQuiet
+	}
+
+	return SignupModeOpen
+}
+
+// notifySignupConflict tells the signup email address why no account was
+// created. If the email belongs to an account its owner is told that
+// someone tried to sign up with it, otherwise the username was taken.
+func (s *authService) notifySignupConflict(byEmail *entity.User, username string, email string, fullname string) {
+	if byEmail != nil {
+		s.inBackground("signup notice", byEmail, s.sendAccountExists)
+		return
+	}
+
+	requester := &entity.User{Username: username, Email: email, Fullname: fullname}
+	s.inBackground("signup notice", requester, s.sendUsernameTaken)
+}
+
+func (s *authService) sendAccountExists(ctx context.Context, user *entity.User) error {
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Sign up attempt with your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone tried to create an account with this email address, which already belongs to your account %s.\n\n"+
+			"If it was you, log in instead or reset your password if you forgot it. Otherwise you can ignore this email.\n",
+			user.Fullname, user.Username),
+	})
+}
+
+func (s *authService) sendUsernameTaken(ctx context.Context, user *entity.User) error {
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Finish signing up",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"The username %s is not available, so no account was created. Please sign up again with a different username.\n",
+			user.Fullname, user.Username),
+	})
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/mailer"
+	userEntity "go-authentication-exercise/internal/user/entity"
+	userRepository "go-authentication-exercise/internal/user/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUserRepository looks users up in a fixed list and fails the test on
+// any write
+type fakeUserRepository struct {
+	userRepository.UserRepository
+	users []*userEntity.User
+}
+
+func (r *fakeUserRepository) FindOneByUsername(ctx context.Context, username string) (*userEntity.User, error) {
+	for _, u := range r.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepository) FindOneByEmail(ctx context.Context, email string) (*userEntity.User, error) {
+	for _, u := range r.users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepository) FindOneById(ctx context.Context, id uuid.UUID) (*userEntity.User, error) {
+	for _, u := range r.users {
+		if u.Id == id {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeMailer chan mailer.Message
+
+func (m fakeMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m <- msg
+	return nil
+}
+
+func (m fakeMailer) next(t *testing.T) mailer.Message {
+	select {
+	case msg := <-m:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no mail sent")
+		return mailer.Message{}
+	}
+}
+
+func newSignupTestService(t *testing.T, mode SignupMode) (*authService, fakeMailer) {
+	hash, err := hashPassword("password123")
+	require.NoError(t, err)
+
+	now := time.Now()
+	mail := make(fakeMailer, 1)
+	return &authService{
+		repository: &fakeUserRepository{users: []*userEntity.User{{
+			Username: "existinguser",
+			Fullname: "Existing User",
+			Email:    "existing@example.com",
+			Password: hash,
+		}}},
+		throttle:   newTestThrottle(&now),
+		mailer:     mail,
+		signupMode: mode,
+	}, mail
+}
+
+func TestLoginUniformError(t *testing.T) {
+	s, _ := newSignupTestService(t, SignupModeOpen)
+
+	_, _, unknownErr := s.Login(context.Background(), "nouser", "password123", authEntity.Client{IP: "192.0.2.1"})
+	_, _, wrongErr := s.Login(context.Background(), "existinguser", "wrong-password", authEntity.Client{IP: "192.0.2.1"})
+
+	assert.Equal(t, ErrInvalidCredentials, unknownErr)
+	assert.Equal(t, ErrInvalidCredentials, wrongErr)
+}
+
+func TestSignupConflict(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          SignupMode
+		username      string
+		email         string
+		expectedError error
+		expectedTo    string
+		expectedMail  string
+	}{
+		{
+			name:          "Open mode reports taken username",
+			mode:          SignupModeOpen,
+			username:      "existinguser",
+			email:         "new@example.com",
+			expectedError: ErrUsernameTaken,
+		},
+		{
+			name:          "Open mode reports taken email",
+			mode:          SignupModeOpen,
+			username:      "newuser",
+			email:         "Existing@Example.com",
+			expectedError: ErrEmailTaken,
+		},
+		{
+			name:         "Quiet mode mails the owner of a taken email",
+			mode:         SignupModeQuiet,
+			username:     "newuser",
+			email:        "Existing@Example.com",
+			expectedTo:   "existing@example.com",
+			expectedMail: "already belongs to your account existinguser",
+		},
+		{
+			name:         "Quiet mode mails the requester of a taken username",
+			mode:         SignupModeQuiet,
+			username:     "existinguser",
+			email:        "new@example.com",
+			expectedTo:   "new@example.com",
+			expectedMail: "The username existinguser is not available",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mail := newSignupTestService(t, tt.mode)
+
+			user, err := s.Signup(context.Background(), tt.username, tt.email, "New User", "password123")
+			assert.Nil(t, user)
+			assert.Equal(t, tt.expectedError, err)
+
+			if tt.expectedTo != "" {
+				msg := mail.next(t)
+				assert.Equal(t, tt.expectedTo, msg.To)
+				assert.Contains(t, msg.Body, tt.expectedMail)
+			}
+		})
+	}
+}
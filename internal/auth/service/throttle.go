@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	authRepository "go-authentication-exercise/internal/auth/repository"
+	"go-authentication-exercise/internal/util"
+)
+
+var (
+	ErrAccountLocked   = errors.New("account is temporarily locked")
+	ErrTooManyAttempts = errors.New("too many failed login attempts")
+)
+
+// RetryAfterError is returned while logins are refused, RetryAfter tells
+// when the next attempt is allowed
+type RetryAfterError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// ThrottleConfig holds the brute force protection settings. Failures older
+// than Window are forgotten. After BackoffAfter failures for a username
+// every further failure doubles the wait, starting at BackoffBase up to
+// BackoffMax, and after LockoutAfter failures the account is locked for
+// LockoutDuration. Client IPs get the same backoff after IPBackoffAfter
+// failures but are never locked.
+type ThrottleConfig struct {
+	Window          time.Duration
+	BackoffAfter    int
+	BackoffBase     time.Duration
+	BackoffMax      time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	IPBackoffAfter  int
+}
+
+// ThrottleConfigFromEnv reads the LOGIN_* settings
+func ThrottleConfigFromEnv() ThrottleConfig {
+	return ThrottleConfig{
+		Window:          util.GetEnvDuration("LOGIN_ATTEMPT_WINDOW", 15*time.Minute),
+		BackoffAfter:    util.GetEnvInt("LOGIN_BACKOFF_AFTER", 3),
+		BackoffBase:     util.GetEnvDuration("LOGIN_BACKOFF_BASE", time.Second),
+		BackoffMax:      util.GetEnvDuration("LOGIN_BACKOFF_MAX", time.Minute),
+		LockoutAfter:    util.GetEnvInt("LOGIN_LOCKOUT_AFTER", 10),
+		LockoutDuration: util.GetEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		IPBackoffAfter:  util.GetEnvInt("LOGIN_IP_BACKOFF_AFTER", 20),
+	}
+}
+
+type loginThrottle struct {
+	repository authRepository.LoginAttemptRepository
+	config     ThrottleConfig
+	now        func() time.Time
+}
+
+// NewLoginThrottle tracks failed logins per username and client IP in repo
+func NewLoginThrottle(repo authRepository.LoginAttemptRepository, config ThrottleConfig) LoginThrottle {
+	return &loginThrottle{
+		repository: repo,
+		config:     config,
+		now:        time.Now,
+	}
+}
+
+// Check refuses the attempt while the username or the client IP is blocked
+func (t *loginThrottle) Check(ctx context.Context, username string, clientIP string) error {
+	now := t.now()
+
+	for _, key := range t.keys(username, clientIP) {
+		attempt, err := t.repository.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		if attempt == nil || attempt.BlockedUntil == nil || !attempt.BlockedUntil.After(now) {
+			continue
+		}
+
+		reason := ErrTooManyAttempts
+		if isUserKey(key) && t.config.LockoutAfter > 0 && attempt.Failures >= t.config.LockoutAfter {
+			reason = ErrAccountLocked
+		}
+
+		return &RetryAfterError{Err: reason, RetryAfter: attempt.BlockedUntil.Sub(now)}
+	}
+
+	return nil
+}
+
+// Failure counts a failed attempt and blocks the username or client IP
+// once they are over their limit
+func (t *loginThrottle) Failure(ctx context.Context, username string, clientIP string) error {
+	now := t.now()
+
+	for _, key := range t.keys(username, clientIP) {
+		attempt, err := t.repository.RecordFailure(ctx, key, now, t.config.Window)
+		if err != nil {
+			return err
+		}
+
+		if delay := t.delay(key, attempt.Failures); delay > 0 {
+			if err := t.repository.Block(ctx, key, now.Add(delay)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Success forgets the failures of the username. Client IPs aren't reset,
+// otherwise an attacker could clear their counter by logging into their own
+// account in between.
+func (t *loginThrottle) Success(ctx context.Context, username string) error {
+	return t.repository.Reset(ctx, userKey(username))
+}
+
+// Unlock lifts a lockout of the username
+func (t *loginThrottle) Unlock(ctx context.Context, username string) error {
+	return t.repository.Reset(ctx, userKey(username))
+}
+
+// delay returns how long the key is blocked after the given number of
+// failures
+func (t *loginThrottle) delay(key string, failures int) time.Duration {
+	backoffAfter := t.config.IPBackoffAfter
+	if isUserKey(key) {
+		if t.config.LockoutAfter > 0 && failures >= t.config.LockoutAfter {
+			return t.config.LockoutDuration
+		}
+		backoffAfter = t.config.BackoffAfter
+	}
+
+	if backoffAfter <= 0 || failures <= backoffAfter {
+		return 0
+	}
+
+	delay := t.config.BackoffBase
+	for i := backoffAfter + 1; i < failures && delay < t.config.BackoffMax; i++ {
+		delay *= 2
+	}
+
+	if delay > t.config.BackoffMax {
+		delay = t.config.BackoffMax
+	}
+
+	return delay
+}
+
+func (t *loginThrottle) keys(username string, clientIP string) []string {
+	keys := []string{userKey(username)}
+	if clientIP != "" {
+		keys = append(keys, "ip:"+clientIP)
+	}
+
+	return keys
+}
+
+func userKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func isUserKey(key string) bool {
+	return strings.HasPrefix(key, "user:")
+}
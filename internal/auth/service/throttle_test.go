@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	authRepository "go-authentication-exercise/internal/auth/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestThrottle(now *time.Time) *loginThrottle {
+	return &loginThrottle{
+		repository: authRepository.NewMemoryLoginAttemptRepository(),
+		config: ThrottleConfig{
+			Window:          15 * time.Minute,
+			BackoffAfter:    3,
+			BackoffBase:     time.Second,
+			BackoffMax:      4 * time.Second,
+			LockoutAfter:    10,
+			LockoutDuration: 15 * time.Minute,
+			IPBackoffAfter:  5,
+		},
+		now: func() time.Time { return *now },
+	}
+}
+
+// retryAfter returns the wait reported by Check, or zero when allowed
+func retryAfter(t *testing.T, throttle *loginThrottle, username string, clientIP string) (time.Duration, error) {
+	err := throttle.Check(context.Background(), username, clientIP)
+	if err == nil {
+		return 0, nil
+	}
+
+	var retry *RetryAfterError
+	if !errors.As(err, &retry) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return retry.RetryAfter, retry.Err
+}
+
+func TestLoginThrottleBackoff(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	throttle := newTestThrottle(&now)
+
+	expected := []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+	for i, delay := range expected {
+		assert.NoError(t, throttle.Failure(ctx, "testuser", ""))
+
+		wait, reason := retryAfter(t, throttle, "testuser", "")
+		assert.Equal(t, delay, wait, "failure %d", i+1)
+		if delay > 0 {
+			assert.Equal(t, ErrTooManyAttempts, reason)
+		}
+
+		// wait out the delay before the next attempt
+		now = now.Add(delay)
+	}
+
+	// usernames are case-insensitive
+	wait, _ := retryAfter(t, throttle, "TestUser", "")
+	assert.Equal(t, time.Duration(0), wait)
+	assert.NoError(t, throttle.Failure(ctx, "TestUser", ""))
+	wait, _ = retryAfter(t, throttle, "testuser", "")
+	assert.Equal(t, 4*time.Second, wait)
+
+	// a successful login starts over
+	assert.NoError(t, throttle.Success(ctx, "testuser"))
+	wait, _ = retryAfter(t, throttle, "testuser", "")
+	assert.Equal(t, time.Duration(0), wait)
+}
+
+func TestLoginThrottleLockout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	throttle := newTestThrottle(&now)
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, throttle.Failure(ctx, "testuser", ""))
+		now = now.Add(5 * time.Second)
+	}
+
+	wait, reason := retryAfter(t, throttle, "testuser", "")
+	assert.Equal(t, ErrAccountLocked, reason)
+	assert.Equal(t, 15*time.Minute-5*time.Second, wait)
+
+	// other accounts aren't affected
+	wait, _ = retryAfter(t, throttle, "otheruser", "")
+	assert.Equal(t, time.Duration(0), wait)
+
+	assert.NoError(t, throttle.Unlock(ctx, "testuser"))
+	wait, _ = retryAfter(t, throttle, "testuser", "")
+	assert.Equal(t, time.Duration(0), wait)
+}
+
+func TestLoginThrottleClientIP(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	throttle := newTestThrottle(&now)
+
+	// spraying one password over many accounts from the same address
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, throttle.Failure(ctx, fmt.Sprintf("user%d", i), "192.0.2.1"))
+	}
+
+	wait, reason := retryAfter(t, throttle, "someone", "192.0.2.1")
+	assert.Equal(t, ErrTooManyAttempts, reason)
+	assert.Equal(t, time.Second, wait)
+
+	wait, _ = retryAfter(t, throttle, "someone", "192.0.2.2")
+	assert.Equal(t, time.Duration(0), wait)
+
+	// logging into an own account doesn't clear the address
+	assert.NoError(t, throttle.Success(ctx, "someone"))
+	wait, _ = retryAfter(t, throttle, "someone", "192.0.2.1")
+	assert.Equal(t, time.Second, wait)
+}
+
+func TestLoginThrottleWindow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	throttle := newTestThrottle(&now)
+
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, throttle.Failure(ctx, "testuser", ""))
+	}
+
+	// failures older than the window are forgotten
+	now = now.Add(16 * time.Minute)
+	assert.NoError(t, throttle.Failure(ctx, "testuser", ""))
+
+	wait, _ := retryAfter(t, throttle, "testuser", "")
+	assert.Equal(t, time.Duration(0), wait)
+}
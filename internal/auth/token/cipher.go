@@ -0,0 +1,96 @@
+package token
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+)
+
+// sealedPrefix marks encrypted key material, plaintext material is base64
+// or PEM and never starts with it
+const sealedPrefix = "sealed:"
+
+var ErrKeyMaterialSealed = errors.New("signing key material is encrypted but JWT_KEY_ENCRYPTION_KEY is not set")
+
+// KeyCipher encrypts signing key material at rest with AES-256-GCM. A nil
+// KeyCipher stores material as plaintext.
+type KeyCipher struct {
+	aead cipher.AEAD
+}
+
+// NewKeyCipher derives the encryption key from secret
+func NewKeyCipher(secret string) (*KeyCipher, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyCipher{aead: aead}, nil
+}
+
+// KeyCipherFromEnv builds the cipher from JWT_KEY_ENCRYPTION_KEY, it
+// returns nil when the variable is not set
+func KeyCipherFromEnv() (*KeyCipher, error) {
+	secret := os.Getenv("JWT_KEY_ENCRYPTION_KEY")
+	if secret == "" {
+		return nil, nil
+	}
+
+	return NewKeyCipher(secret)
+}
+
+// Seal encrypts the material of the key id, the id is authenticated so
+// material can't be moved to another key
+func (c *KeyCipher) Seal(id string, material string) (string, error) {
+	if c == nil {
+		return material, nil
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(material), []byte(id))
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts material sealed by Seal. Plaintext material, stored before
+// encryption was enabled, is returned as is.
+func (c *KeyCipher) Open(id string, stored string) (string, error) {
+	if !strings.HasPrefix(stored, sealedPrefix) {
+		return stored, nil
+	}
+
+	if c == nil {
+		return "", ErrKeyMaterialSealed
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, sealedPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("sealed key material is too short")
+	}
+
+	material, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(id))
+	if err != nil {
+		return "", errors.New("signing key material can't be decrypted, check JWT_KEY_ENCRYPTION_KEY")
+	}
+
+	return string(material), nil
+}
@@ -0,0 +1,43 @@
+package token
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyCipher(t *testing.T) {
+	c, err := NewKeyCipher("encryption-secret")
+	assert.NoError(t, err)
+
+	sealed, err := c.Seal("key-1", "material")
+	assert.NoError(t, err)
+	assert.NotContains(t, sealed, "material")
+
+	opened, err := c.Open("key-1", sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, "material", opened)
+
+	// material is bound to its key id
+	_, err = c.Open("key-2", sealed)
+	assert.Error(t, err)
+
+	other, _ := NewKeyCipher("other-secret")
+	_, err = other.Open("key-1", sealed)
+	assert.Error(t, err)
+
+	// a nil cipher can't read sealed material
+	var none *KeyCipher
+	_, err = none.Open("key-1", sealed)
+	assert.ErrorIs(t, err, ErrKeyMaterialSealed)
+
+	// plaintext material from before encryption was enabled stays readable
+	opened, err = c.Open("key-1", "plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain", opened)
+
+	plain, err := none.Seal("key-1", "material")
+	assert.NoError(t, err)
+	assert.False(t, strings.HasPrefix(plain, sealedPrefix))
+}
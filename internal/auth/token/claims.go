@@ -0,0 +1,82 @@
+package token
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"go-authentication-exercise/internal/util"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+// Config holds the settings for issuing and validating tokens. An empty
+// Issuer or Audience is neither emitted nor enforced.
+type Config struct {
+	Issuer          string
+	Audience        string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	Leeway          time.Duration
+}
+
+// ConfigFromEnv reads JWT_ISSUER, JWT_AUDIENCE, JWT_ACCESS_TOKEN_TTL,
+// JWT_REFRESH_TOKEN_TTL and JWT_LEEWAY
+func ConfigFromEnv() Config {
+	return Config{
+		Issuer:          os.Getenv("JWT_ISSUER"),
+		Audience:        os.Getenv("JWT_AUDIENCE"),
+		AccessTokenTTL:  util.GetEnvDuration("JWT_ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: util.GetEnvDuration("JWT_REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		Leeway:          util.GetEnvDuration("JWT_LEEWAY", 30*time.Second),
+	}
+}
+
+// NewClaims returns the registered claims of an access token for subject
+// issued at now
+func (c Config) NewClaims(subject string, now time.Time) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"jti": uuid.New().String(),
+		"sub": subject,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(c.AccessTokenTTL).Unix(),
+	}
+
+	if c.Issuer != "" {
+		claims["iss"] = c.Issuer
+	}
+
+	if c.Audience != "" {
+		claims["aud"] = c.Audience
+	}
+
+	return claims
+}
+
+// Validate checks exp, nbf and iat allowing for Leeway of clock skew, and
+// iss and aud when configured
+func (c Config) Validate(claims jwt.MapClaims, now time.Time) error {
+	if !claims.VerifyExpiresAt(now.Add(-c.Leeway).Unix(), true) {
+		return errors.New("token is expired")
+	}
+
+	if !claims.VerifyNotBefore(now.Add(c.Leeway).Unix(), false) {
+		return errors.New("token is not valid yet")
+	}
+
+	if !claims.VerifyIssuedAt(now.Add(c.Leeway).Unix(), false) {
+		return errors.New("token used before issued")
+	}
+
+	if c.Issuer != "" && !claims.VerifyIssuer(c.Issuer, true) {
+		return errors.New("invalid issuer")
+	}
+
+	if c.Audience != "" && !claims.VerifyAudience(c.Audience, true) {
+		return errors.New("invalid audience")
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewClaims(t *testing.T) {
+	now := time.Now()
+	config := Config{
+		Issuer:         "https://auth.example.com",
+		Audience:       "api",
+		AccessTokenTTL: 10 * time.Minute,
+	}
+
+	claims := config.NewClaims("user-id", now)
+
+	assert.Equal(t, "user-id", claims["sub"])
+	assert.Equal(t, "https://auth.example.com", claims["iss"])
+	assert.Equal(t, "api", claims["aud"])
+	assert.Equal(t, now.Unix(), claims["iat"])
+	assert.Equal(t, now.Unix(), claims["nbf"])
+	assert.Equal(t, now.Add(10*time.Minute).Unix(), claims["exp"])
+	assert.NotEmpty(t, claims["jti"])
+
+	// issuer and audience are left out when not configured
+	claims = Config{AccessTokenTTL: time.Minute}.NewClaims("user-id", now)
+	assert.NotContains(t, claims, "iss")
+	assert.NotContains(t, claims, "aud")
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Now()
+	config := Config{
+		Issuer:   "https://auth.example.com",
+		Audience: "api",
+		Leeway:   30 * time.Second,
+	}
+
+	// valid returns claims that pass validation, with overrides applied
+	valid := func(overrides jwt.MapClaims) jwt.MapClaims {
+		claims := jwt.MapClaims{
+			"iss": "https://auth.example.com",
+			"aud": "api",
+			"iat": float64(now.Unix()),
+			"nbf": float64(now.Unix()),
+			"exp": float64(now.Add(time.Minute).Unix()),
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(claims, k)
+				continue
+			}
+			claims[k] = v
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name        string
+		claims      jwt.MapClaims
+		expectError bool
+	}{
+		{
+			name:   "Valid claims",
+			claims: valid(nil),
+		},
+		{
+			name:   "Expired within leeway",
+			claims: valid(jwt.MapClaims{"exp": float64(now.Add(-10 * time.Second).Unix())}),
+		},
+		{
+			name:        "Expired beyond leeway",
+			claims:      valid(jwt.MapClaims{"exp": float64(now.Add(-time.Minute).Unix())}),
+			expectError: true,
+		},
+		{
+			name:        "Missing expiry",
+			claims:      valid(jwt.MapClaims{"exp": nil}),
+			expectError: true,
+		},
+		{
+			name:   "Not before within leeway",
+			claims: valid(jwt.MapClaims{"nbf": float64(now.Add(10 * time.Second).Unix())}),
+		},
+		{
+			name:        "Not before beyond leeway",
+			claims:      valid(jwt.MapClaims{"nbf": float64(now.Add(time.Minute).Unix())}),
+			expectError: true,
+		},
+		{
+			name:        "Issued in the future",
+			claims:      valid(jwt.MapClaims{"iat": float64(now.Add(time.Minute).Unix())}),
+			expectError: true,
+		},
+		{
+			name:        "Wrong issuer",
+			claims:      valid(jwt.MapClaims{"iss": "https://evil.example.com"}),
+			expectError: true,
+		},
+		{
+			name:        "Missing issuer",
+			claims:      valid(jwt.MapClaims{"iss": nil}),
+			expectError: true,
+		},
+		{
+			name:   "Audience in list",
+			claims: valid(jwt.MapClaims{"aud": []interface{}{"other", "api"}}),
+		},
+		{
+			name:        "Wrong audience",
+			claims:      valid(jwt.MapClaims{"aud": "other"}),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := config.Validate(tt.claims, now)
+
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+// JWK is the public part of a signing key as described in RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+var errSymmetricKey = errors.New("symmetric keys can't be published")
+
+// JWK returns the public key in JWK form. It fails for HMAC keys.
+func (k *Key) JWK() (*JWK, error) {
+	jwk := &JWK{
+		Kid: k.Id,
+		Use: "sig",
+		Alg: k.Method.Alg(),
+	}
+
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeBase64(pub.N.Bytes())
+		jwk.E = encodeBase64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = encodeBase64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = encodeBase64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encodeBase64(pub)
+	default:
+		return nil, errSymmetricKey
+	}
+
+	return jwk, nil
+}
+
+// Thumbprint computes the RFC 7638 SHA-256 thumbprint of the key
+func (j *JWK) Thumbprint() string {
+	// only the required members, in lexicographic order
+	var members interface{}
+	switch j.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{j.E, j.Kty, j.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{j.Crv, j.Kty, j.X, j.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Crv, j.Kty, j.X}
+	}
+
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+
+	return encodeBase64(sum[:])
+}
+
+// NewJWKS builds the key set from the publishable keys
+func NewJWKS(keys ...*Key) *JWKS {
+	jwks := &JWKS{
+		Keys: []JWK{},
+	}
+
+	for _, k := range keys {
+		jwk, err := k.JWK()
+		if err != nil {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, *jwk)
+	}
+
+	return jwks
+}
+
+func encodeBase64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,249 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Key is a JWT signing key together with the key id advertised in the
+// `kid` header. For asymmetric algorithms only the public half is used
+// for verification and published in the JWKS. A non-zero NotAfter stops
+// the key from verifying tokens once it has passed.
+type Key struct {
+	Id         string
+	Method     jwt.SigningMethod
+	NotAfter   time.Time
+	signingKey interface{}
+	verifyKey  interface{}
+}
+
+// NewHMACKey creates a HS256 key from a shared secret
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{
+		Id:         id,
+		Method:     jwt.SigningMethodHS256,
+		signingKey: secret,
+		verifyKey:  secret,
+	}
+}
+
+// NewKey wraps a private key for the given algorithm (RS256, ES256 or
+// EdDSA). If id is empty the RFC 7638 thumbprint of the public key is used.
+func NewKey(id string, algorithm string, privateKey interface{}) (*Key, error) {
+	var publicKey interface{}
+
+	switch algorithm {
+	case jwt.SigningMethodRS256.Alg():
+		k, ok := privateKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s requires an RSA private key", algorithm)
+		}
+		publicKey = &k.PublicKey
+	case jwt.SigningMethodES256.Alg():
+		k, ok := privateKey.(*ecdsa.PrivateKey)
+		if !ok || k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%s requires a P-256 EC private key", algorithm)
+		}
+		publicKey = &k.PublicKey
+	case jwt.SigningMethodEdDSA.Alg():
+		k, ok := privateKey.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s requires an Ed25519 private key", algorithm)
+		}
+		publicKey = k.Public()
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+
+	key := &Key{
+		Id:         id,
+		Method:     jwt.GetSigningMethod(algorithm),
+		signingKey: privateKey,
+		verifyKey:  publicKey,
+	}
+
+	if key.Id == "" {
+		jwk, err := key.JWK()
+		if err != nil {
+			return nil, err
+		}
+		key.Id = jwk.Thumbprint()
+	}
+
+	return key, nil
+}
+
+// GenerateKey creates a new random key for the given algorithm
+func GenerateKey(algorithm string) (*Key, error) {
+	var privateKey interface{}
+	var err error
+
+	switch algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return nil, err
+		}
+		return NewHMACKey(hex.EncodeToString(id), secret), nil
+	case jwt.SigningMethodRS256.Alg():
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodES256.Alg():
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwt.SigningMethodEdDSA.Alg():
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKey("", algorithm, privateKey)
+}
+
+// ParseKey restores a key serialized with MarshalPrivateKey
+func ParseKey(id string, algorithm string, material string) (*Key, error) {
+	if algorithm == jwt.SigningMethodHS256.Alg() {
+		secret, err := base64.StdEncoding.DecodeString(material)
+		if err != nil {
+			return nil, err
+		}
+		return NewHMACKey(id, secret), nil
+	}
+
+	privateKey, err := parsePrivateKey([]byte(material))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKey(id, algorithm, privateKey)
+}
+
+// LoadKey reads a PEM encoded private key (PKCS#8, PKCS#1 or SEC 1) from path
+func LoadKey(id string, algorithm string, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return NewKey(id, algorithm, privateKey)
+}
+
+// LoadKeyFromEnv builds the signing key from JWT_ALGORITHM. HS256, the
+// default, uses JWT_SECRET_KEY; asymmetric algorithms load the private key
+// from JWT_PRIVATE_KEY_FILE. JWT_KEY_ID optionally overrides the key id.
+func LoadKeyFromEnv() (*Key, error) {
+	algorithm := os.Getenv("JWT_ALGORITHM")
+	if algorithm == "" {
+		algorithm = jwt.SigningMethodHS256.Alg()
+	}
+
+	id := os.Getenv("JWT_KEY_ID")
+
+	if algorithm == jwt.SigningMethodHS256.Alg() {
+		secretKey := os.Getenv("JWT_SECRET_KEY")
+		if secretKey == "" {
+			return nil, errors.New("JWT_SECRET_KEY is not set")
+		}
+		if id == "" {
+			id = "default"
+		}
+		return NewHMACKey(id, []byte(secretKey)), nil
+	}
+
+	path := os.Getenv("JWT_PRIVATE_KEY_FILE")
+	if path == "" {
+		return nil, errors.New("JWT_PRIVATE_KEY_FILE is not set")
+	}
+
+	return LoadKey(id, algorithm, path)
+}
+
+// Symmetric tells whether the key is an HMAC secret, which can't be
+// published for others to verify its tokens
+func (k *Key) Symmetric() bool {
+	_, ok := k.verifyKey.([]byte)
+	return ok
+}
+
+// MarshalPrivateKey serializes the key material, as a PKCS#8 PEM block for
+// asymmetric keys and base64 for HMAC secrets
+func (k *Key) MarshalPrivateKey() (string, error) {
+	if secret, ok := k.signingKey.([]byte); ok {
+		return base64.StdEncoding.EncodeToString(secret), nil
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(k.signingKey)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// Sign creates a token with the given claims, signed by this key
+func (k *Key) Sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(k.Method, claims)
+	token.Header["kid"] = k.Id
+
+	return token.SignedString(k.signingKey)
+}
+
+// Keyfunc is a jwt.Keyfunc accepting only tokens signed with this key's
+// algorithm and, when present, its key id
+func (k *Key) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	if kid, ok := token.Header["kid"]; ok && kid != k.Id {
+		return nil, fmt.Errorf("unknown key id: %v", kid)
+	}
+
+	return k.verifyKey, nil
+}
+
+func (k *Key) validAt(now time.Time) bool {
+	return k.NotAfter.IsZero() || now.Before(k.NotAfter)
+}
+
+func parsePrivateKey(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+}
@@ -0,0 +1,172 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+// writePrivateKey stores key as a PKCS#8 PEM file and returns its path
+func writePrivateKey(t *testing.T, key crypto.PrivateKey) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Error marshalling private key: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Error writing private key: %v", err)
+	}
+
+	return path
+}
+
+func TestLoadKey(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name        string
+		algorithm   string
+		privateKey  crypto.PrivateKey
+		expectedKty string
+		expectError bool
+	}{
+		{
+			name:        "RS256",
+			algorithm:   "RS256",
+			privateKey:  rsaKey,
+			expectedKty: "RSA",
+		},
+		{
+			name:        "ES256",
+			algorithm:   "ES256",
+			privateKey:  ecKey,
+			expectedKty: "EC",
+		},
+		{
+			name:        "EdDSA",
+			algorithm:   "EdDSA",
+			privateKey:  edKey,
+			expectedKty: "OKP",
+		},
+		{
+			name:        "Key doesn't match algorithm",
+			algorithm:   "ES256",
+			privateKey:  rsaKey,
+			expectError: true,
+		},
+		{
+			name:        "Unsupported algorithm",
+			algorithm:   "PS256",
+			privateKey:  rsaKey,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := LoadKey("", tt.algorithm, writePrivateKey(t, tt.privateKey))
+
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+
+			// the key id defaults to the thumbprint of the public key
+			jwk, err := key.JWK()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedKty, jwk.Kty)
+			assert.Equal(t, tt.algorithm, jwk.Alg)
+			assert.Equal(t, jwk.Thumbprint(), key.Id)
+
+			// tokens signed by the key verify with its public half
+			signed, err := key.Sign(jwt.MapClaims{
+				"username": "testuser",
+				"exp":      time.Now().Add(time.Hour).Unix(),
+			})
+			assert.NoError(t, err)
+
+			parsed, err := jwt.Parse(signed, key.Keyfunc)
+			assert.NoError(t, err)
+			assert.True(t, parsed.Valid)
+			assert.Equal(t, key.Id, parsed.Header["kid"])
+		})
+	}
+}
+
+func TestKeyfuncRejectsOtherAlgorithms(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	key, err := NewKey("rsa-key", "RS256", rsaKey)
+	assert.NoError(t, err)
+
+	// a HS256 token using the public key as secret must not verify
+	forged := NewHMACKey("rsa-key", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))
+	signed, err := forged.Sign(jwt.MapClaims{"username": "testuser"})
+	assert.NoError(t, err)
+
+	_, err = jwt.Parse(signed, key.Keyfunc)
+	assert.Error(t, err)
+}
+
+func TestLoadKeyFromEnv(t *testing.T) {
+	t.Setenv("JWT_ALGORITHM", "")
+	t.Setenv("JWT_KEY_ID", "")
+
+	// HS256 requires a secret
+	t.Setenv("JWT_SECRET_KEY", "")
+	_, err := LoadKeyFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("JWT_SECRET_KEY", "test-secret-key")
+	key, err := LoadKeyFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, "HS256", key.Method.Alg())
+	assert.Equal(t, "default", key.Id)
+
+	// symmetric keys are never published
+	assert.True(t, key.Symmetric())
+	assert.Empty(t, NewJWKS(key).Keys)
+
+	// asymmetric algorithms require a key file
+	t.Setenv("JWT_ALGORITHM", "ES256")
+	t.Setenv("JWT_PRIVATE_KEY_FILE", "")
+	_, err = LoadKeyFromEnv()
+	assert.Error(t, err)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	t.Setenv("JWT_PRIVATE_KEY_FILE", writePrivateKey(t, ecKey))
+	t.Setenv("JWT_KEY_ID", "ec-key")
+	key, err = LoadKeyFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, "ec-key", key.Id)
+	assert.False(t, key.Symmetric())
+	assert.Len(t, NewJWKS(key).Keys, 1)
+}
+
+func TestThumbprint(t *testing.T) {
+	// example from RFC 7638 section 3.1
+	jwk := &JWK{
+		Kty: "RSA",
+		E:   "AQAB",
+		N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	}
+
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", jwk.Thumbprint())
+}
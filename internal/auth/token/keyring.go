@@ -0,0 +1,96 @@
+package token
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Keyring signs tokens with the current key and verifies them against the
+// current key or any previous key that hasn't reached its NotAfter yet,
+// selected by the `kid` header. It is safe for concurrent use.
+type Keyring struct {
+	mu       sync.RWMutex
+	current  *Key
+	previous []*Key
+}
+
+func NewKeyring(current *Key, previous ...*Key) *Keyring {
+	return &Keyring{
+		current:  current,
+		previous: previous,
+	}
+}
+
+// Set replaces the keys held by the keyring
+func (k *Keyring) Set(current *Key, previous ...*Key) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.current = current
+	k.previous = previous
+}
+
+// Current returns the key used for signing
+func (k *Keyring) Current() *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.current
+}
+
+// Sign signs the claims with the current key
+func (k *Keyring) Sign(claims jwt.MapClaims) (string, error) {
+	return k.Current().Sign(claims)
+}
+
+// Keyfunc is a jwt.Keyfunc that picks the verification key by `kid`.
+// Tokens without a key id are checked against the current key.
+func (k *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key := k.lookup(kid, time.Now())
+	if key == nil {
+		return nil, fmt.Errorf("unknown key id: %v", kid)
+	}
+
+	return key.Keyfunc(token)
+}
+
+// JWKS returns the public keys of every key that can still verify tokens
+func (k *Keyring) JWKS() *JWKS {
+	return NewJWKS(k.active(time.Now())...)
+}
+
+func (k *Keyring) lookup(kid string, now time.Time) *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if kid == "" || kid == k.current.Id {
+		return k.current
+	}
+
+	for _, key := range k.previous {
+		if key.Id == kid && key.validAt(now) {
+			return key
+		}
+	}
+
+	return nil
+}
+
+func (k *Keyring) active(now time.Time) []*Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := []*Key{k.current}
+	for _, key := range k.previous {
+		if key.validAt(now) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
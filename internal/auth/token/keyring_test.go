@@ -0,0 +1,77 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyring(t *testing.T) {
+	oldKey, _ := GenerateKey("ES256")
+	newKey, _ := GenerateKey("EdDSA")
+	expiredKey, _ := GenerateKey("ES256")
+	expiredKey.NotAfter = time.Now().Add(-time.Minute)
+	oldKey.NotAfter = time.Now().Add(time.Hour)
+
+	claims := jwt.MapClaims{"username": "testuser"}
+
+	oldToken, _ := oldKey.Sign(claims)
+	expiredToken, _ := expiredKey.Sign(claims)
+	unknownKey, _ := GenerateKey("ES256")
+	unknownToken, _ := unknownKey.Sign(claims)
+
+	keyring := NewKeyring(newKey, oldKey, expiredKey)
+
+	// new tokens are signed by the current key
+	newToken, err := keyring.Sign(claims)
+	assert.NoError(t, err)
+	parsed, err := jwt.Parse(newToken, keyring.Keyfunc)
+	assert.NoError(t, err)
+	assert.Equal(t, newKey.Id, parsed.Header["kid"])
+
+	// tokens signed by a previous key still verify until it expires
+	_, err = jwt.Parse(oldToken, keyring.Keyfunc)
+	assert.NoError(t, err)
+
+	_, err = jwt.Parse(expiredToken, keyring.Keyfunc)
+	assert.Error(t, err)
+
+	_, err = jwt.Parse(unknownToken, keyring.Keyfunc)
+	assert.Error(t, err)
+
+	// only keys that can still verify are published
+	var kids []string
+	for _, jwk := range keyring.JWKS().Keys {
+		kids = append(kids, jwk.Kid)
+	}
+	assert.ElementsMatch(t, []string{newKey.Id, oldKey.Id}, kids)
+
+	// replacing the keys drops the previous ones
+	keyring.Set(newKey)
+	_, err = jwt.Parse(oldToken, keyring.Keyfunc)
+	assert.Error(t, err)
+}
+
+func TestGenerateAndParseKey(t *testing.T) {
+	for _, algorithm := range []string{"HS256", "RS256", "ES256", "EdDSA"} {
+		t.Run(algorithm, func(t *testing.T) {
+			key, err := GenerateKey(algorithm)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, key.Id)
+
+			material, err := key.MarshalPrivateKey()
+			assert.NoError(t, err)
+
+			restored, err := ParseKey(key.Id, algorithm, material)
+			assert.NoError(t, err)
+
+			// a token signed before serializing verifies with the restored key
+			signed, err := key.Sign(jwt.MapClaims{"username": "testuser"})
+			assert.NoError(t, err)
+			_, err = jwt.Parse(signed, restored.Keyfunc)
+			assert.NoError(t, err)
+		})
+	}
+}
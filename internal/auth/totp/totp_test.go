@@ -0,0 +1,89 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the SHA-1 secret of the RFC 6238 test vectors
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		time     int64
+		expected string
+	}{
+		{time: 59, expected: "287082"},
+		{time: 1111111109, expected: "081804"},
+		{time: 1111111111, expected: "050471"},
+		{time: 1234567890, expected: "005924"},
+		{time: 2000000000, expected: "279037"},
+		{time: 20000000000, expected: "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			code, err := Code(rfcSecret, Step(time.Unix(tt.time, 0)))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, code)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current, _ := Code(rfcSecret, Step(now))
+	previous, _ := Code(rfcSecret, Step(now)-1)
+	old, _ := Code(rfcSecret, Step(now)-2)
+
+	tests := []struct {
+		name         string
+		secret       string
+		code         string
+		expectValid  bool
+		expectedStep int64
+	}{
+		{name: "Current code", secret: rfcSecret, code: current, expectValid: true, expectedStep: Step(now)},
+		{name: "Lowercase secret", secret: strings.ToLower(rfcSecret), code: current, expectValid: true, expectedStep: Step(now)},
+		{name: "Previous code within skew", secret: rfcSecret, code: previous, expectValid: true, expectedStep: Step(now) - 1},
+		{name: "Code outside skew", secret: rfcSecret, code: old, expectValid: false},
+		{name: "Wrong code", secret: rfcSecret, code: "000000", expectValid: false},
+		{name: "Wrong length", secret: rfcSecret, code: "12345", expectValid: false},
+		{name: "Invalid secret", secret: "not base32!", code: current, expectValid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(tt.secret, tt.code, now, 1)
+
+			assert.Equal(t, tt.expectValid, ok)
+			if tt.expectValid {
+				assert.Equal(t, tt.expectedStep, step)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	code, err := Code(secret, Step(time.Now()))
+	assert.NoError(t, err)
+	assert.Len(t, code, Digits)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("go-authentication-exercise", "alice@example.com", "JBSWY3DPEHPK3PXP")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/go-authentication-exercise:alice@example.com?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=go-authentication-exercise")
+	assert.Contains(t, uri, "digits=6")
+	assert.Contains(t, uri, "period=30")
+}
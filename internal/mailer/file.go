@@ -0,0 +1,68 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fileMailer struct {
+	from string
+	dir  string
+}
+
+// NewFileMailer writes every message to its own .eml file in dir, which is
+// handy for local development without a mail server
+func NewFileMailer(from, dir string) (Mailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &fileMailer{
+		from: from,
+		dir:  dir,
+	}, nil
+}
+
+func (m *fileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	body, err := format(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405"), uuid.New())
+	return os.WriteFile(filepath.Join(m.dir, name), body, 0o600)
+}
+
+type logMailer struct {
+	from   string
+	logger *log.Logger
+}
+
+// NewLogMailer prints messages to logger, or the standard logger when nil
+func NewLogMailer(from string, logger *log.Logger) Mailer {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return &logMailer{
+		from:   from,
+		logger: logger,
+	}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	body, err := format(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	m.logger.Printf("mail to %s:\n%s", msg.To, body)
+	return nil
+}
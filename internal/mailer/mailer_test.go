@@ -0,0 +1,97 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		message     Message
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:    "Plain text message",
+			message: Message{To: "user@example.com", Subject: "Hello", Body: "line one\nline two"},
+			expected: []string{
+				"From: no-reply@example.com\r\n",
+				"To: user@example.com\r\n",
+				"Subject: Hello\r\n",
+				"Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n",
+				"\r\n\r\nline one\r\nline two",
+			},
+		},
+		{
+			name:     "Non ASCII subject is encoded",
+			message:  Message{To: "user@example.com", Subject: "Grüße", Body: "hi"},
+			expected: []string{"Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=\r\n"},
+		},
+		{
+			name:        "Header injection in subject",
+			message:     Message{To: "user@example.com", Subject: "Hello\r\nBcc: evil@example.com", Body: "hi"},
+			expectError: true,
+		},
+		{
+			name:        "Header injection in recipient",
+			message:     Message{To: "user@example.com\nBcc: evil@example.com", Subject: "Hello", Body: "hi"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := format("no-reply@example.com", tt.message, now)
+
+			if tt.expectError {
+				assert.ErrorIs(t, err, ErrInvalidHeader)
+				return
+			}
+
+			assert.NoError(t, err)
+			for _, part := range tt.expected {
+				assert.Contains(t, string(body), part)
+			}
+		})
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer("no-reply@example.com", dir)
+	require.NoError(t, err)
+
+	err = m.Send(context.Background(), Message{To: "user@example.com", Subject: "Hello", Body: "hi"})
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	body, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "To: user@example.com\r\n")
+	assert.True(t, strings.HasSuffix(string(body), "\r\n\r\nhi"))
+}
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer("no-reply@example.com", log.New(&buf, "", 0))
+
+	err := m.Send(context.Background(), Message{To: "user@example.com", Subject: "Hello", Body: "hi"})
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "mail to user@example.com")
+	assert.Contains(t, buf.String(), "Subject: Hello")
+}
@@ -0,0 +1,55 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+type smtpMailer struct {
+	from string
+	addr string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer sends messages through the SMTP server at host:port. The
+// connection is upgraded with STARTTLS when the server offers it, and
+// credentials are only used when a username is set.
+func NewSMTPMailer(from, host, port, username, password string) (Mailer, error) {
+	if host == "" {
+		return nil, errors.New("SMTP_HOST is required for the smtp mailer")
+	}
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &smtpMailer{
+		from: from,
+		addr: net.JoinHostPort(host, port),
+		auth: auth,
+	}, nil
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg Message) error {
+	body, err := format(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// net/smtp has no context support, give up waiting on cancellation
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, body)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
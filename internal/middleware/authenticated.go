@@ -3,55 +3,290 @@ package middleware
 import (
 	"context"
 	"errors"
-	"fmt"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 
+	apiKeyEntity "go-authentication-exercise/internal/apikey/entity"
+	apiKeyService "go-authentication-exercise/internal/apikey/service"
+	"go-authentication-exercise/internal/auth/repository"
+	"go-authentication-exercise/internal/auth/token"
+	roleEntity "go-authentication-exercise/internal/role/entity"
+	roleRepository "go-authentication-exercise/internal/role/repository"
 	"go-authentication-exercise/internal/user/entity"
+	userRepository "go-authentication-exercise/internal/user/repository"
 	"go-authentication-exercise/internal/util"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 )
 
-// Authenticated middleware checks if the request has a valid JWT token
-// and adds the user information to the request context
-func Authenticated(next http.Handler) http.Handler {
+type authMiddleware struct {
+	keyring       *token.Keyring
+	tokenConfig   token.Config
+	revokedTokens repository.RevokedTokenRepository
+	sessions      repository.SessionRepository
+	clients       repository.OAuthClientRepository
+	users         userRepository.UserRepository
+	roles         roleRepository.RoleRepository
+	apiKeys       apiKeyService.APIKeyService
+	cookies       CookieConfig
+}
+
+func NewAuthMiddleware(
+	keyring *token.Keyring,
+	tokenConfig token.Config,
+	revokedTokens repository.RevokedTokenRepository,
+	sessions repository.SessionRepository,
+	clients repository.OAuthClientRepository,
+	users userRepository.UserRepository,
+	roles roleRepository.RoleRepository,
+	apiKeys apiKeyService.APIKeyService,
+	cookies CookieConfig,
+) AuthMiddleware {
+	return &authMiddleware{
+		keyring:       keyring,
+		tokenConfig:   tokenConfig,
+		revokedTokens: revokedTokens,
+		sessions:      sessions,
+		clients:       clients,
+		users:         users,
+		roles:         roles,
+		apiKeys:       apiKeys,
+		cookies:       cookies,
+	}
+}
+
+// Authenticated middleware checks if the request has a valid JWT token or
+// API key and adds the user information to the request context. Tokens of
+// the client credentials grant add the OAuth client instead of a user.
+func (m *authMiddleware) Authenticated(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract token from header or query parameter
-		token, err := extractToken(r)
+		// Extract token from header, query parameter or cookie
+		token, fromCookie := m.cookieToken(r)
+		var err error
+		if !fromCookie {
+			token, err = extractToken(r)
+			if err != nil {
+				util.Error(w, http.StatusUnauthorized, nil, err.Error())
+				return
+			}
+		}
+
+		// Browsers send cookies on cross-site requests too, so state
+		// changes must prove they come from our own pages
+		if fromCookie && !isSafeMethod(r.Method) {
+			if err := CheckCSRF(r); err != nil {
+				util.Error(w, http.StatusForbidden, nil, err.Error())
+				return
+			}
+		}
+
+		var userId uuid.UUID
+		var claims jwt.MapClaims
+		var key *apiKeyEntity.APIKey
+
+		if apiKeyService.IsAPIKey(token) {
+			key, err = m.apiKeys.Authenticate(r.Context(), token)
+			if err != nil {
+				util.Error(w, http.StatusUnauthorized, nil, err.Error())
+				return
+			}
+			userId = key.UserId
+		} else {
+			// Validate the token
+			claims, err = m.validateToken(r.Context(), token)
+			if err != nil {
+				util.Error(w, http.StatusUnauthorized, nil, "Invalid token: "+err.Error())
+				return
+			}
+
+			if isClientToken(claims) {
+				m.serveClient(w, r, next, claims)
+				return
+			}
+
+			// Get user id from token claims
+			userId, err = getUserIdFromJwt(claims)
+			if err != nil {
+				util.Error(w, http.StatusUnauthorized, nil, "Invalid token: "+err.Error())
+				return
+			}
+		}
+
+		// Load the user, deleted users are not found
+		user, err := m.users.FindOneById(r.Context(), userId)
 		if err != nil {
-			util.Error(w, http.StatusUnauthorized, nil, err.Error())
+			util.Error(w, http.StatusInternalServerError, nil, err.Error())
 			return
 		}
 
-		// Validate the token
-		claims, err := validateToken(token)
-		if err != nil {
-			util.Error(w, http.StatusUnauthorized, nil, "Invalid token: "+err.Error())
+		if user == nil {
+			util.Error(w, http.StatusUnauthorized, nil, "Invalid token: user not found")
+			return
+		}
+
+		if user.DisabledAt != nil {
+			util.Error(w, http.StatusUnauthorized, nil, "Invalid token: account is disabled")
 			return
 		}
 
-		// Get username from token claims
-		username, err := getUsernameFromJwt(claims)
+		// Reject tokens issued before the user's credentials changed, API
+		// keys outlive password changes
+		if claims != nil {
+			if err := checkTokensValidAfter(claims, user); err != nil {
+				util.Error(w, http.StatusUnauthorized, nil, "Invalid token: "+err.Error())
+				return
+			}
+
+			if err := m.checkSession(r.Context(), claims, user); err != nil {
+				util.Error(w, http.StatusUnauthorized, nil, "Invalid token: "+err.Error())
+				return
+			}
+		}
+
+		// Permissions follow the current roles, not the role claims
+		access, err := m.roles.FindAccessByUserId(r.Context(), user.Id)
 		if err != nil {
-			util.Error(w, http.StatusUnauthorized, nil, "Invalid token: "+err.Error())
+			util.Error(w, http.StatusInternalServerError, nil, err.Error())
 			return
 		}
 
 		// Create user context and proceed with request
-		user := &entity.User{
-			Username: username,
+		ctx := WithUser(r.Context(), user)
+		if key != nil {
+			ctx = WithAPIKey(ctx, key)
+			access = scopeAccess(access, key.Scopes)
+		} else {
+			ctx = WithClaims(ctx, claims)
+			// tokens issued to OAuth clients only carry the granted scopes
+			if scope, ok := claims["scope"].(string); ok {
+				access = scopeAccess(access, strings.Fields(scope))
+			}
 		}
-
-		ctx := context.WithValue(r.Context(), "user", user)
+		ctx = WithAccess(ctx, access)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// extractToken gets the JWT token from either the Authorization header
-// or from a query parameter
+// CookieSession signs the user of the access token cookie in on the HTML
+// pages of the server, in cookie mode. Requests without a valid cookie, or
+// form posts without the CSRF token, are served without a user so the page
+// asks for credentials instead.
+func (m *authMiddleware) CookieSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, claims := m.cookieUser(r); user != nil {
+			ctx := WithClaims(WithUser(r.Context(), user), claims)
+			r = r.WithContext(ctx)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cookieUser returns the user signed in by the access token cookie, or nil
+// when the cookie wouldn't pass Authenticated. Tokens of OAuth clients never
+// sign a user in.
+func (m *authMiddleware) cookieUser(r *http.Request) (*entity.User, jwt.MapClaims) {
+	token, ok := m.cookieToken(r)
+	if !ok || apiKeyService.IsAPIKey(token) {
+		return nil, nil
+	}
+
+	if !isSafeMethod(r.Method) && CheckCSRF(r) != nil {
+		return nil, nil
+	}
+
+	claims, err := m.validateToken(r.Context(), token)
+	if err != nil || claims["client_id"] != nil {
+		return nil, nil
+	}
+
+	userId, err := getUserIdFromJwt(claims)
+	if err != nil {
+		return nil, nil
+	}
+
+	user, err := m.users.FindOneById(r.Context(), userId)
+	if err != nil || user == nil || user.DisabledAt != nil {
+		return nil, nil
+	}
+
+	if checkTokensValidAfter(claims, user) != nil || m.checkSession(r.Context(), claims, user) != nil {
+		return nil, nil
+	}
+
+	return user, claims
+}
+
+// serveClient authenticates an OAuth client principal. Its access is the
+// granted scopes the client is still registered with.
+func (m *authMiddleware) serveClient(w http.ResponseWriter, r *http.Request, next http.Handler, claims jwt.MapClaims) {
+	clientId, _ := claims["client_id"].(string)
+	client, err := m.clients.FindOneById(r.Context(), clientId)
+	if err != nil {
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	if client == nil || !client.Confidential {
+		util.Error(w, http.StatusUnauthorized, nil, "Invalid token: client not found")
+		return
+	}
+
+	scope, _ := claims["scope"].(string)
+	access := scopeAccess(&roleEntity.Access{Permissions: client.Scopes}, strings.Fields(scope))
+
+	ctx := WithClient(r.Context(), client)
+	ctx = WithClaims(ctx, claims)
+	ctx = WithAccess(ctx, access)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// isClientToken reports whether the token was issued to an OAuth client for
+// itself, which makes the client its subject
+func isClientToken(claims jwt.MapClaims) bool {
+	clientId, _ := claims["client_id"].(string)
+	return clientId != "" && claims["sub"] == clientId
+}
+
+// cookieToken returns the access token cookie in cookie mode, when the
+// request carries no other credentials
+func (m *authMiddleware) cookieToken(r *http.Request) (string, bool) {
+	if !m.cookies.Enabled || r.Header.Get("X-API-Key") != "" ||
+		r.Header.Get("Authorization") != "" || r.URL.Query().Get("authorization") != "" {
+		return "", false
+	}
+
+	cookie, err := r.Cookie(AccessTokenCookie)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+
+	return cookie.Value, true
+}
+
+// scopeAccess limits access to the permissions in scopes. Roles are
+// dropped, so RequireRole never admits an API key or OAuth client.
+func scopeAccess(access *roleEntity.Access, scopes []string) *roleEntity.Access {
+	scoped := &roleEntity.Access{Roles: []string{}, Permissions: []string{}}
+	for _, permission := range scopes {
+		if access.HasPermission(permission) {
+			scoped.Permissions = append(scoped.Permissions, permission)
+		}
+	}
+
+	return scoped
+}
+
+// extractToken gets the API key from the X-API-Key header, or the JWT
+// token or API key from either the Authorization header or from a query
+// parameter
 func extractToken(r *http.Request) (string, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key, nil
+	}
+
 	// Check Authorization header first
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
@@ -72,46 +307,96 @@ func extractToken(r *http.Request) (string, error) {
 	return parts[1], nil
 }
 
-// validateToken verifies that the token is valid and returns its claims
-func validateToken(tokenString string) (jwt.MapClaims, error) {
-	secretKey := os.Getenv("JWT_SECRET_KEY")
-	if secretKey == "" {
-		return nil, errors.New("JWT_SECRET_KEY is not set")
-	}
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secretKey), nil
-	})
-
+// validateToken verifies that the token is valid and hasn't been revoked,
+// and returns its claims
+func (m *authMiddleware) validateToken(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	// the keyring picks the key by id and checks the signing method, the
+	// claims are validated below to allow for clock skew
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	parsed, err := parser.Parse(tokenString, m.keyring.Keyfunc)
 	if err != nil {
 		return nil, err
 	}
 
-	if !token.Valid {
+	if !parsed.Valid {
 		return nil, errors.New("invalid token")
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
+	claims, ok := parsed.Claims.(jwt.MapClaims)
 	if !ok {
 		return nil, errors.New("invalid token claims")
 	}
 
+	if err := m.tokenConfig.Validate(claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return nil, errors.New("jti claim not found in token")
+	}
+
+	revoked, err := m.revokedTokens.IsRevoked(ctx, jti)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, errors.New("token has been revoked")
+	}
+
 	return claims, nil
 }
 
-// getUsernameFromJwt extracts the username from JWT claims
-func getUsernameFromJwt(data map[string]interface{}) (string, error) {
-	if val, ok := data["username"]; ok {
-		username, ok := val.(string)
-		if !ok || username == "" {
-			return "", errors.New("invalid username in token")
+// getUserIdFromJwt extracts the user id from the JWT subject claim
+func getUserIdFromJwt(data map[string]interface{}) (uuid.UUID, error) {
+	if val, ok := data["sub"]; ok {
+		sub, ok := val.(string)
+		if !ok || sub == "" {
+			return uuid.Nil, errors.New("invalid subject in token")
 		}
-		return username, nil
+
+		userId, err := uuid.Parse(sub)
+		if err != nil {
+			return uuid.Nil, errors.New("invalid subject in token")
+		}
+		return userId, nil
+	}
+
+	return uuid.Nil, errors.New("sub claim not found in token")
+}
+
+// checkSession rejects tokens whose session was revoked and marks the
+// session as seen. Tokens issued before sessions existed carry no session.
+func (m *authMiddleware) checkSession(ctx context.Context, claims jwt.MapClaims, user *entity.User) error {
+	sessionId := SessionIdFromClaims(claims)
+	if sessionId == uuid.Nil {
+		return nil
+	}
+
+	session, err := m.sessions.FindOneById(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+
+	if session == nil || session.UserId != user.Id || session.RevokedAt != nil {
+		return errors.New("session has been revoked")
+	}
+
+	return m.sessions.Touch(ctx, session.Id, time.Now())
+}
+
+// checkTokensValidAfter rejects tokens issued before the user's
+// TokensValidAfter, which is set when the password changes
+func checkTokensValidAfter(claims jwt.MapClaims, user *entity.User) error {
+	if user.TokensValidAfter == nil {
+		return nil
+	}
+
+	iat, ok := claims["iat"].(float64)
+	if !ok || int64(iat) < user.TokensValidAfter.Unix() {
+		return errors.New("token was issued before the credentials changed")
 	}
 
-	return "", errors.New("username claim not found in token")
+	return nil
 }
@@ -1,17 +1,121 @@
 package middleware
 
 import (
+	"context"
+	apiKeyEntity "go-authentication-exercise/internal/apikey/entity"
+	apiKeyService "go-authentication-exercise/internal/apikey/service"
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/auth/repository"
+	"go-authentication-exercise/internal/auth/token"
+	roleEntity "go-authentication-exercise/internal/role/entity"
+	roleRepository "go-authentication-exercise/internal/role/repository"
 	"go-authentication-exercise/internal/user/entity"
+	userRepository "go-authentication-exercise/internal/user/repository"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
+// fakeRevokedTokenRepository is an in-memory RevokedTokenRepository
+type fakeRevokedTokenRepository struct {
+	revoked map[string]time.Time
+}
+
+func newFakeRevokedTokenRepository() *fakeRevokedTokenRepository {
+	return &fakeRevokedTokenRepository{
+		revoked: make(map[string]time.Time),
+	}
+}
+
+func (r *fakeRevokedTokenRepository) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	r.revoked[jti] = expiresAt
+	return nil
+}
+
+func (r *fakeRevokedTokenRepository) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	_, ok := r.revoked[jti]
+	return ok, nil
+}
+
+// fakeSessionRepository is an in-memory SessionRepository that only
+// supports the calls done by the middleware
+type fakeSessionRepository struct {
+	repository.SessionRepository
+	sessions map[uuid.UUID]*authEntity.Session
+}
+
+func (r *fakeSessionRepository) FindOneById(ctx context.Context, id uuid.UUID) (*authEntity.Session, error) {
+	return r.sessions[id], nil
+}
+
+func (r *fakeSessionRepository) Touch(ctx context.Context, id uuid.UUID, at time.Time) error {
+	r.sessions[id].LastSeenAt = at
+	return nil
+}
+
+// fakeOAuthClientRepository looks clients up in a fixed map
+type fakeOAuthClientRepository struct {
+	repository.OAuthClientRepository
+	clients map[string]*authEntity.OAuthClient
+}
+
+func (r *fakeOAuthClientRepository) FindOneById(ctx context.Context, id string) (*authEntity.OAuthClient, error) {
+	return r.clients[id], nil
+}
+
+// fakeUserRepository is an in-memory UserRepository that only supports
+// the lookups done by the middleware
+type fakeUserRepository struct {
+	userRepository.UserRepository
+	users map[uuid.UUID]*entity.User
+}
+
+func newFakeUserRepository(users ...*entity.User) *fakeUserRepository {
+	r := &fakeUserRepository{
+		users: make(map[uuid.UUID]*entity.User),
+	}
+	for _, u := range users {
+		r.users[u.Id] = u
+	}
+	return r
+}
+
+func (r *fakeUserRepository) FindOneById(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	return r.users[id], nil
+}
+
+// fakeRoleRepository is an in-memory RoleRepository that only supports
+// the lookups done by the middleware
+type fakeRoleRepository struct {
+	roleRepository.RoleRepository
+	access map[uuid.UUID]*roleEntity.Access
+}
+
+func (r *fakeRoleRepository) FindAccessByUserId(ctx context.Context, userId uuid.UUID) (*roleEntity.Access, error) {
+	if access, ok := r.access[userId]; ok {
+		return access, nil
+	}
+	return &roleEntity.Access{Roles: []string{}, Permissions: []string{}}, nil
+}
+
+// fakeAPIKeyService accepts the keys in its map
+type fakeAPIKeyService struct {
+	apiKeyService.APIKeyService
+	keys map[string]*apiKeyEntity.APIKey
+}
+
+func (s *fakeAPIKeyService) Authenticate(ctx context.Context, key string) (*apiKeyEntity.APIKey, error) {
+	if stored, ok := s.keys[key]; ok {
+		return stored, nil
+	}
+	return nil, apiKeyService.ErrInvalidAPIKey
+}
+
 func TestExtractToken(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -41,6 +145,16 @@ func TestExtractToken(t *testing.T) {
 			expectedToken: "valid-token",
 			expectError:   false,
 		},
+		{
+			name: "API key header",
+			setupRequest: func() *http.Request {
+				req := httptest.NewRequest("GET", "/user/list", nil)
+				req.Header.Set("X-API-Key", "pat_valid-key")
+				return req
+			},
+			expectedToken: "pat_valid-key",
+			expectError:   false,
+		},
 		{
 			name: "Missing token",
 			setupRequest: func() *http.Request {
@@ -79,75 +193,119 @@ func TestExtractToken(t *testing.T) {
 
 func TestValidateToken(t *testing.T) {
 	// Setup
-	originalSecret := os.Getenv("JWT_SECRET_KEY")
-	defer func() {
-		os.Setenv("JWT_SECRET_KEY", originalSecret)
-	}()
-
-	// Set a test secret key
 	testSecret := "test-secret-key"
-	os.Setenv("JWT_SECRET_KEY", testSecret)
+	key := token.NewHMACKey("test-key", []byte(testSecret))
 
 	// Create a valid token
-	validClaims := jwt.MapClaims{
+	validTokenString, err := key.Sign(jwt.MapClaims{
+		"jti":      "valid-jti",
 		"username": "testuser",
 		"exp":      time.Now().Add(time.Hour).Unix(),
-	}
-	validToken := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims)
-	validTokenString, err := validToken.SignedString([]byte(testSecret))
+	})
 	if err != nil {
 		t.Fatalf("Error creating test token: %v", err)
 	}
 
 	// Create an expired token
-	expiredClaims := jwt.MapClaims{
+	expiredTokenString, err := key.Sign(jwt.MapClaims{
+		"jti":      "expired-jti",
 		"username": "testuser",
 		"exp":      time.Now().Add(-time.Hour).Unix(),
-	}
-	expiredToken := jwt.NewWithClaims(jwt.SigningMethodHS256, expiredClaims)
-	expiredTokenString, err := expiredToken.SignedString([]byte(testSecret))
+	})
 	if err != nil {
 		t.Fatalf("Error creating expired token: %v", err)
 	}
 
+	// Create a token signed with another secret
+	otherKey := token.NewHMACKey("test-key", []byte("other-secret-key"))
+	wrongSecretTokenString, err := otherKey.Sign(jwt.MapClaims{
+		"jti":      "wrong-secret-jti",
+		"username": "testuser",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("Error creating token with wrong secret: %v", err)
+	}
+
+	// Create an unsigned token
+	unsignedToken := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"jti":      "unsigned-jti",
+		"username": "testuser",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	})
+	unsignedTokenString, err := unsignedToken.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("Error creating unsigned token: %v", err)
+	}
+
+	// Create a token without jti
+	noJtiTokenString, err := key.Sign(jwt.MapClaims{
+		"username": "testuser",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("Error creating token without jti: %v", err)
+	}
+
+	// Create a revoked token
+	revokedTokenString, err := key.Sign(jwt.MapClaims{
+		"jti":      "revoked-jti",
+		"username": "testuser",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("Error creating revoked token: %v", err)
+	}
+
+	revokedTokens := newFakeRevokedTokenRepository()
+	revokedTokens.Revoke(context.Background(), "revoked-jti", time.Now().Add(time.Hour))
+	m := &authMiddleware{keyring: token.NewKeyring(key), tokenConfig: token.Config{}, revokedTokens: revokedTokens}
+
 	tests := []struct {
 		name        string
-		setupEnv    func()
 		tokenString string
 		expectError bool
 	}{
 		{
 			name:        "Valid token",
-			setupEnv:    func() {},
 			tokenString: validTokenString,
 			expectError: false,
 		},
 		{
 			name:        "Expired token",
-			setupEnv:    func() {},
 			tokenString: expiredTokenString,
 			expectError: true,
 		},
 		{
-			name: "Missing secret key",
-			setupEnv: func() {
-				os.Setenv("JWT_SECRET_KEY", "")
-			},
-			tokenString: validTokenString,
+			name:        "Wrong secret key",
+			tokenString: wrongSecretTokenString,
+			expectError: true,
+		},
+		{
+			name:        "Unexpected signing method",
+			tokenString: unsignedTokenString,
 			expectError: true,
 		},
 		{
 			name:        "Invalid token",
-			setupEnv:    func() {},
 			tokenString: "invalid-token",
 			expectError: true,
 		},
+		{
+			name:        "Missing jti",
+			tokenString: noJtiTokenString,
+			expectError: true,
+		},
+		{
+			name:        "Revoked token",
+			tokenString: revokedTokenString,
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.setupEnv()
-			claims, err := validateToken(tt.tokenString)
+			claims, err := m.validateToken(context.Background(), tt.tokenString)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -156,63 +314,70 @@ func TestValidateToken(t *testing.T) {
 				assert.NotNil(t, claims)
 				assert.Equal(t, "testuser", claims["username"])
 			}
-
-			// Reset environment
-			os.Setenv("JWT_SECRET_KEY", testSecret)
 		})
 	}
 }
 
-func TestGetUsernameFromJwt(t *testing.T) {
+func TestGetUserIdFromJwt(t *testing.T) {
+	userId := uuid.New()
+
 	tests := []struct {
 		name           string
 		claims         map[string]interface{}
-		expectedResult string
+		expectedResult uuid.UUID
 		expectError    bool
 	}{
 		{
-			name: "Valid username",
+			name: "Valid subject",
 			claims: map[string]interface{}{
-				"username": "testuser",
+				"sub": userId.String(),
 			},
-			expectedResult: "testuser",
+			expectedResult: userId,
 			expectError:    false,
 		},
 		{
-			name: "Missing username",
+			name: "Missing subject",
 			claims: map[string]interface{}{
-				"other": "value",
+				"username": "testuser",
 			},
-			expectedResult: "",
+			expectedResult: uuid.Nil,
 			expectError:    true,
 		},
 		{
-			name: "Empty username",
+			name: "Empty subject",
 			claims: map[string]interface{}{
-				"username": "",
+				"sub": "",
 			},
-			expectedResult: "",
+			expectedResult: uuid.Nil,
 			expectError:    true,
 		},
 		{
-			name: "Non-string username",
+			name: "Non-string subject",
 			claims: map[string]interface{}{
-				"username": 123,
+				"sub": 123,
 			},
-			expectedResult: "",
+			expectedResult: uuid.Nil,
+			expectError:    true,
+		},
+		{
+			name: "Subject is not a user id",
+			claims: map[string]interface{}{
+				"sub": "testuser",
+			},
+			expectedResult: uuid.Nil,
 			expectError:    true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			username, err := getUsernameFromJwt(tt.claims)
+			userId, err := getUserIdFromJwt(tt.claims)
 
 			if tt.expectError {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.expectedResult, username)
+				assert.Equal(t, tt.expectedResult, userId)
 			}
 		})
 	}
@@ -220,30 +385,111 @@ func TestGetUsernameFromJwt(t *testing.T) {
 
 func TestAuthenticatedMiddleware(t *testing.T) {
 	// Setup
-	originalSecret := os.Getenv("JWT_SECRET_KEY")
-	defer func() {
-		os.Setenv("JWT_SECRET_KEY", originalSecret)
-	}()
+	key := token.NewHMACKey("test-key", []byte("test-secret-key"))
 
-	testSecret := "test-secret-key"
-	os.Setenv("JWT_SECRET_KEY", testSecret)
+	testUser := &entity.User{
+		Id:       uuid.New(),
+		Username: "testuser",
+		Fullname: "Test User",
+	}
+	disabledAt := time.Now()
+	disabledUser := &entity.User{
+		Id:         uuid.New(),
+		Username:   "disableduser",
+		DisabledAt: &disabledAt,
+	}
+	users := newFakeUserRepository(testUser, disabledUser)
+	roles := &fakeRoleRepository{access: map[uuid.UUID]*roleEntity.Access{
+		testUser.Id: {Roles: []string{"admin"}, Permissions: []string{"users:read"}},
+	}}
 
 	// Create a valid token
-	validClaims := jwt.MapClaims{
+	validTokenString, err := key.Sign(jwt.MapClaims{
+		"jti":      "valid-jti",
+		"sub":      testUser.Id.String(),
 		"username": "testuser",
 		"exp":      time.Now().Add(time.Hour).Unix(),
-	}
-	validToken := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims)
-	validTokenString, err := validToken.SignedString([]byte(testSecret))
+	})
 	if err != nil {
 		t.Fatalf("Error creating test token: %v", err)
 	}
 
+	// Create a revoked token
+	revokedTokenString, err := key.Sign(jwt.MapClaims{
+		"jti":      "revoked-jti",
+		"sub":      testUser.Id.String(),
+		"username": "testuser",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("Error creating revoked token: %v", err)
+	}
+
+	// Create a token for a user that has been deleted
+	deletedUserTokenString, err := key.Sign(jwt.MapClaims{
+		"jti":      "deleted-user-jti",
+		"sub":      uuid.New().String(),
+		"username": "deleteduser",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("Error creating token for deleted user: %v", err)
+	}
+
+	// Create a token for a user that has been disabled
+	disabledUserTokenString, err := key.Sign(jwt.MapClaims{
+		"jti":      "disabled-user-jti",
+		"sub":      disabledUser.Id.String(),
+		"username": "disableduser",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("Error creating token for disabled user: %v", err)
+	}
+
+	// Create tokens for an active and a revoked session
+	revokedAt := time.Now()
+	activeSession := &authEntity.Session{Id: uuid.New(), UserId: testUser.Id}
+	revokedSession := &authEntity.Session{Id: uuid.New(), UserId: testUser.Id, RevokedAt: &revokedAt}
+	sessions := &fakeSessionRepository{sessions: map[uuid.UUID]*authEntity.Session{
+		activeSession.Id:  activeSession,
+		revokedSession.Id: revokedSession,
+	}}
+
+	activeSessionTokenString, err := key.Sign(jwt.MapClaims{
+		"jti": "active-session-jti",
+		"sub": testUser.Id.String(),
+		"sid": activeSession.Id.String(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("Error creating token for active session: %v", err)
+	}
+
+	revokedSessionTokenString, err := key.Sign(jwt.MapClaims{
+		"jti": "revoked-session-jti",
+		"sub": testUser.Id.String(),
+		"sid": revokedSession.Id.String(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("Error creating token for revoked session: %v", err)
+	}
+
+	revokedTokens := newFakeRevokedTokenRepository()
+	revokedTokens.Revoke(context.Background(), "revoked-jti", time.Now().Add(time.Hour))
+
+	apiKeys := &fakeAPIKeyService{keys: map[string]*apiKeyEntity.APIKey{
+		"pat_valid-key": {Id: uuid.New(), UserId: testUser.Id, Scopes: []string{"users:read", "users:write"}},
+	}}
+
 	tests := []struct {
-		name           string
-		setupRequest   func() *http.Request
-		expectedStatus int
-		expectedUser   *entity.User
+		name                string
+		setupRequest        func() *http.Request
+		expectedStatus      int
+		expectedUser        *entity.User
+		expectedRoles       []string
+		expectedPermissions []string
 	}{
 		{
 			name: "Valid authentication",
@@ -252,10 +498,66 @@ func TestAuthenticatedMiddleware(t *testing.T) {
 				req.Header.Set("Authorization", "Bearer "+validTokenString)
 				return req
 			},
-			expectedStatus: http.StatusOK,
-			expectedUser: &entity.User{
-				Username: "testuser",
+			expectedStatus:      http.StatusOK,
+			expectedUser:        testUser,
+			expectedRoles:       []string{"admin"},
+			expectedPermissions: []string{"users:read"},
+		},
+		{
+			name: "Active session",
+			setupRequest: func() *http.Request {
+				req := httptest.NewRequest("GET", "/user/list", nil)
+				req.Header.Set("Authorization", "Bearer "+activeSessionTokenString)
+				return req
 			},
+			expectedStatus:      http.StatusOK,
+			expectedUser:        testUser,
+			expectedRoles:       []string{"admin"},
+			expectedPermissions: []string{"users:read"},
+		},
+		{
+			name: "Revoked session",
+			setupRequest: func() *http.Request {
+				req := httptest.NewRequest("GET", "/user/list", nil)
+				req.Header.Set("Authorization", "Bearer "+revokedSessionTokenString)
+				return req
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedUser:   nil,
+		},
+		{
+			name: "API key header",
+			setupRequest: func() *http.Request {
+				req := httptest.NewRequest("GET", "/user/list", nil)
+				req.Header.Set("X-API-Key", "pat_valid-key")
+				return req
+			},
+			expectedStatus:      http.StatusOK,
+			expectedUser:        testUser,
+			expectedRoles:       []string{},
+			expectedPermissions: []string{"users:read"},
+		},
+		{
+			name: "API key as bearer token",
+			setupRequest: func() *http.Request {
+				req := httptest.NewRequest("GET", "/user/list", nil)
+				req.Header.Set("Authorization", "Bearer pat_valid-key")
+				return req
+			},
+			expectedStatus:      http.StatusOK,
+			expectedUser:        testUser,
+			expectedRoles:       []string{},
+			expectedPermissions: []string{"users:read"},
+		},
+		{
+			name: "Invalid API key",
+			setupRequest: func() *http.Request {
+				req := httptest.NewRequest("GET", "/user/list", nil)
+				req.Header.Set("X-API-Key", "pat_unknown-key")
+				return req
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedUser:   nil,
 		},
 		{
 			name: "Missing token",
@@ -276,25 +578,54 @@ func TestAuthenticatedMiddleware(t *testing.T) {
 			expectedStatus: http.StatusUnauthorized,
 			expectedUser:   nil,
 		},
+		{
+			name: "Revoked token",
+			setupRequest: func() *http.Request {
+				req := httptest.NewRequest("GET", "/user/list", nil)
+				req.Header.Set("Authorization", "Bearer "+revokedTokenString)
+				return req
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedUser:   nil,
+		},
+		{
+			name: "Deleted user",
+			setupRequest: func() *http.Request {
+				req := httptest.NewRequest("GET", "/user/list", nil)
+				req.Header.Set("Authorization", "Bearer "+deletedUserTokenString)
+				return req
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedUser:   nil,
+		},
+		{
+			name: "Disabled user",
+			setupRequest: func() *http.Request {
+				req := httptest.NewRequest("GET", "/user/list", nil)
+				req.Header.Set("Authorization", "Bearer "+disabledUserTokenString)
+				return req
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedUser:   nil,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			var capturedUser *entity.User
+			var capturedAccess *roleEntity.Access
 			var handlerCalled bool
 
 			// Mock handler that will capture the user from context
 			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				handlerCalled = true
-				userVal := r.Context().Value("user")
-				if userVal != nil {
-					capturedUser = userVal.(*entity.User)
-				}
+				capturedUser = UserFromContext(r.Context())
+				capturedAccess = AccessFromContext(r.Context())
 				w.WriteHeader(http.StatusOK)
 			})
 
 			// Create the middleware chain
-			middleware := Authenticated(nextHandler)
+			middleware := NewAuthMiddleware(token.NewKeyring(key), token.Config{}, revokedTokens, sessions, &fakeOAuthClientRepository{}, users, roles, apiKeys, CookieConfig{}).Authenticated(nextHandler)
 
 			// Create a response recorder and request
 			recorder := httptest.NewRecorder()
@@ -309,10 +640,159 @@ func TestAuthenticatedMiddleware(t *testing.T) {
 			if tt.expectedUser != nil {
 				assert.True(t, handlerCalled, "Next handler should have been called")
 				assert.NotNil(t, capturedUser, "User should be in context")
+				assert.Equal(t, tt.expectedUser.Id, capturedUser.Id)
 				assert.Equal(t, tt.expectedUser.Username, capturedUser.Username)
+				assert.Equal(t, tt.expectedUser.Fullname, capturedUser.Fullname)
+				assert.Equal(t, tt.expectedRoles, capturedAccess.Roles)
+				assert.Equal(t, tt.expectedPermissions, capturedAccess.Permissions)
 			} else {
 				assert.False(t, handlerCalled, "Next handler should not have been called")
 			}
 		})
 	}
 }
+
+func TestAuthenticatedClientPrincipal(t *testing.T) {
+	key := token.NewHMACKey("test-key", []byte("test-secret-key"))
+	clients := &fakeOAuthClientRepository{clients: map[string]*authEntity.OAuthClient{
+		"worker": {Id: "worker", Confidential: true, Scopes: []string{"users:read"}},
+		"spa":    {Id: "spa", Scopes: []string{"users:read"}},
+	}}
+
+	tests := []struct {
+		name                string
+		clientId            string
+		scope               string
+		expectedStatus      int
+		expectedPermissions []string
+	}{
+		{
+			name:                "Client credentials token",
+			clientId:            "worker",
+			scope:               "users:read",
+			expectedStatus:      http.StatusOK,
+			expectedPermissions: []string{"users:read"},
+		},
+		{
+			name:                "Scope the client no longer has",
+			clientId:            "worker",
+			scope:               "users:read users:write",
+			expectedStatus:      http.StatusOK,
+			expectedPermissions: []string{"users:read"},
+		},
+		{
+			name:           "Deleted client",
+			clientId:       "deleted",
+			scope:          "users:read",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Public client",
+			clientId:       "spa",
+			scope:          "users:read",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientToken, err := key.Sign(jwt.MapClaims{
+				"jti":       uuid.New().String(),
+				"sub":       tt.clientId,
+				"client_id": tt.clientId,
+				"scope":     tt.scope,
+				"exp":       time.Now().Add(time.Hour).Unix(),
+			})
+			assert.NoError(t, err)
+
+			var capturedClient *authEntity.OAuthClient
+			var capturedUser *entity.User
+			var capturedAccess *roleEntity.Access
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				capturedClient = ClientFromContext(r.Context())
+				capturedUser = UserFromContext(r.Context())
+				capturedAccess = AccessFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})
+
+			middleware := NewAuthMiddleware(
+				token.NewKeyring(key),
+				token.Config{},
+				newFakeRevokedTokenRepository(),
+				&fakeSessionRepository{},
+				clients,
+				newFakeUserRepository(),
+				&fakeRoleRepository{},
+				&fakeAPIKeyService{},
+				CookieConfig{}).Authenticated(nextHandler)
+
+			req := httptest.NewRequest("GET", "/user/list", nil)
+			req.Header.Set("Authorization", "Bearer "+clientToken)
+			recorder := httptest.NewRecorder()
+
+			middleware.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, tt.clientId, capturedClient.Id)
+				assert.Nil(t, capturedUser)
+				assert.Equal(t, tt.expectedPermissions, capturedAccess.Permissions)
+			}
+		})
+	}
+}
+
+func TestCheckTokensValidAfter(t *testing.T) {
+	changedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	tests := []struct {
+		name        string
+		claims      jwt.MapClaims
+		validAfter  *time.Time
+		expectError bool
+	}{
+		{
+			name:        "Password never changed",
+			claims:      jwt.MapClaims{"iat": float64(changedAt.Add(-time.Hour).Unix())},
+			validAfter:  nil,
+			expectError: false,
+		},
+		{
+			name:        "Issued after the change",
+			claims:      jwt.MapClaims{"iat": float64(changedAt.Add(time.Second).Unix())},
+			validAfter:  &changedAt,
+			expectError: false,
+		},
+		{
+			name:        "Issued in the second of the change",
+			claims:      jwt.MapClaims{"iat": float64(changedAt.Unix())},
+			validAfter:  &changedAt,
+			expectError: false,
+		},
+		{
+			name:        "Issued before the change",
+			claims:      jwt.MapClaims{"iat": float64(changedAt.Add(-time.Second).Unix())},
+			validAfter:  &changedAt,
+			expectError: true,
+		},
+		{
+			name:        "Missing issued at",
+			claims:      jwt.MapClaims{},
+			validAfter:  &changedAt,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &entity.User{Id: uuid.New(), TokensValidAfter: tt.validAfter}
+			err := checkTokensValidAfter(tt.claims, user)
+
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go-authentication-exercise/internal/role/entity"
+	"go-authentication-exercise/internal/util"
+)
+
+// RequirePermission only lets authenticated users through whose roles
+// grant permission. It must be used after Authenticated.
+func RequirePermission(permission string) func(http.Handler) http.Handler {
+	return require(func(access *entity.Access) bool {
+		return access.HasPermission(permission)
+	})
+}
+
+// RequireRole only lets authenticated users with the role through. It must
+// be used after Authenticated.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return require(func(access *entity.Access) bool {
+		return access.HasRole(role)
+	})
+}
+
+// RequireUser only lets requests authenticated as a user through, OAuth
+// client principals are refused. It must be used after Authenticated.
+func RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if UserFromContext(r.Context()) == nil {
+			util.Error(w, http.StatusForbidden, nil, "This endpoint requires a user")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireFirstParty refuses access tokens issued to OAuth clients on behalf
+// of the user, so a client can't manage the account it was granted access
+// to. It must be used after Authenticated.
+func RequireFirstParty(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if DelegatedClientId(r.Context()) != "" {
+			util.Error(w, http.StatusForbidden, nil, "This endpoint can't be used with a token issued to an OAuth client")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func require(allowed func(*entity.Access) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			access := AccessFromContext(r.Context())
+			if access == nil {
+				util.Error(w, http.StatusUnauthorized, nil, "Unauthorized")
+				return
+			}
+
+			if !allowed(access) {
+				util.Error(w, http.StatusForbidden, nil, "Forbidden")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/role/entity"
+	userEntity "go-authentication-exercise/internal/user/entity"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequirePermission(t *testing.T) {
+	admin := &entity.Access{Roles: []string{"admin"}, Permissions: []string{"users:read", "roles:read"}}
+	member := &entity.Access{Roles: []string{}, Permissions: []string{}}
+
+	tests := []struct {
+		name           string
+		middleware     func(http.Handler) http.Handler
+		access         *entity.Access
+		expectedStatus int
+	}{
+		{
+			name:           "Permission granted",
+			middleware:     RequirePermission("users:read"),
+			access:         admin,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Permission missing",
+			middleware:     RequirePermission("users:write"),
+			access:         admin,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "User without roles",
+			middleware:     RequirePermission("users:read"),
+			access:         member,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Not authenticated",
+			middleware:     RequirePermission("users:read"),
+			access:         nil,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Role granted",
+			middleware:     RequireRole("admin"),
+			access:         admin,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Role missing",
+			middleware:     RequireRole("admin"),
+			access:         member,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("GET", "/user/list", nil)
+			if tt.access != nil {
+				req = req.WithContext(WithAccess(req.Context(), tt.access))
+			}
+			res := httptest.NewRecorder()
+
+			tt.middleware(next).ServeHTTP(res, req)
+
+			assert.Equal(t, tt.expectedStatus, res.Code)
+		})
+	}
+}
+
+func TestRequireFirstParty(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("POST", "/auth/password", nil)
+	res := httptest.NewRecorder()
+	RequireFirstParty(next).ServeHTTP(res, req.WithContext(WithClaims(req.Context(), jwt.MapClaims{"sub": "user-id"})))
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	// tokens issued to OAuth clients on behalf of the user
+	res = httptest.NewRecorder()
+	RequireFirstParty(next).ServeHTTP(res, req.WithContext(WithClaims(req.Context(), jwt.MapClaims{"sub": "user-id", "client_id": "webapp"})))
+	assert.Equal(t, http.StatusForbidden, res.Code)
+}
+
+func TestRequireUser(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/user/me", nil)
+	res := httptest.NewRecorder()
+	RequireUser(next).ServeHTTP(res, req.WithContext(WithUser(req.Context(), &userEntity.User{})))
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	// client principals have no user
+	res = httptest.NewRecorder()
+	RequireUser(next).ServeHTTP(res, req.WithContext(WithClient(req.Context(), &authEntity.OAuthClient{Id: "worker"})))
+	assert.Equal(t, http.StatusForbidden, res.Code)
+}
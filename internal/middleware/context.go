@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+
+	apiKeyEntity "go-authentication-exercise/internal/apikey/entity"
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	roleEntity "go-authentication-exercise/internal/role/entity"
+	"go-authentication-exercise/internal/user/entity"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+type contextKey string
+
+const (
+	claimsContextKey contextKey = "claims"
+	userContextKey   contextKey = "user"
+	accessContextKey contextKey = "access"
+	apiKeyContextKey contextKey = "apiKey"
+	clientContextKey contextKey = "client"
+)
+
+// WithClaims returns a copy of ctx carrying the given token claims
+func WithClaims(ctx context.Context, claims jwt.MapClaims) context.Context {
+	return context.WithValue(ctx, claimsContextKey, claims)
+}
+
+// ClaimsFromContext returns the claims of the token that authenticated the
+// request, or nil if the request didn't go through Authenticated
+func ClaimsFromContext(ctx context.Context) jwt.MapClaims {
+	claims, _ := ctx.Value(claimsContextKey).(jwt.MapClaims)
+	return claims
+}
+
+// SessionIdFromClaims returns the session id of the token, or uuid.Nil if
+// it doesn't carry one
+func SessionIdFromClaims(claims jwt.MapClaims) uuid.UUID {
+	sid, _ := claims["sid"].(string)
+	sessionId, err := uuid.Parse(sid)
+	if err != nil {
+		return uuid.Nil
+	}
+
+	return sessionId
+}
+
+// DelegatedClientId returns the OAuth client the access token of the
+// request was issued to on behalf of the user, or "" for first-party tokens
+func DelegatedClientId(ctx context.Context) string {
+	clientId, _ := ClaimsFromContext(ctx)["client_id"].(string)
+	return clientId
+}
+
+// WithUser returns a copy of ctx carrying the authenticated user
+func WithUser(ctx context.Context, user *entity.User) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// UserFromContext returns the authenticated user, or nil if the request
+// didn't go through Authenticated
+func UserFromContext(ctx context.Context) *entity.User {
+	user, _ := ctx.Value(userContextKey).(*entity.User)
+	return user
+}
+
+// WithAccess returns a copy of ctx carrying the roles and permissions of
+// the authenticated user
+func WithAccess(ctx context.Context, access *roleEntity.Access) context.Context {
+	return context.WithValue(ctx, accessContextKey, access)
+}
+
+// AccessFromContext returns the roles and permissions of the authenticated
+// user, or nil if the request didn't go through Authenticated
+func AccessFromContext(ctx context.Context) *roleEntity.Access {
+	access, _ := ctx.Value(accessContextKey).(*roleEntity.Access)
+	return access
+}
+
+// WithAPIKey returns a copy of ctx carrying the API key that authenticated
+// the request
+func WithAPIKey(ctx context.Context, key *apiKeyEntity.APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey, key)
+}
+
+// APIKeyFromContext returns the API key that authenticated the request, or
+// nil if it was authenticated with a JWT
+func APIKeyFromContext(ctx context.Context) *apiKeyEntity.APIKey {
+	key, _ := ctx.Value(apiKeyContextKey).(*apiKeyEntity.APIKey)
+	return key
+}
+
+// WithClient returns a copy of ctx carrying the OAuth client that
+// authenticated the request on its own behalf
+func WithClient(ctx context.Context, client *authEntity.OAuthClient) context.Context {
+	return context.WithValue(ctx, clientContextKey, client)
+}
+
+// ClientFromContext returns the OAuth client principal of the request, or
+// nil if a user is authenticated. Client principals have no user.
+func ClientFromContext(ctx context.Context) *authEntity.OAuthClient {
+	client, _ := ctx.Value(clientContextKey).(*authEntity.OAuthClient)
+	return client
+}
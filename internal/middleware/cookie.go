@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/auth/token"
+)
+
+const (
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+	CSRFTokenCookie    = "csrf_token"
+	CSRFTokenHeader    = "X-CSRF-Token"
+	CSRFTokenField     = "csrf_token"
+
+	// refreshTokenCookiePath limits the refresh token to the endpoints
+	// that take it
+	refreshTokenCookiePath = "/auth"
+)
+
+var ErrInvalidCSRFToken = errors.New("CSRF token is missing or invalid")
+
+// CookieConfig holds the settings of the cookie mode for browser clients.
+// When Enabled, issued tokens are set as HttpOnly cookies instead of being
+// returned, and requests authenticated by cookie need a CSRF token.
+type CookieConfig struct {
+	Enabled         bool
+	Domain          string
+	SameSite        http.SameSite
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// CookieConfigFromEnv reads AUTH_COOKIE_MODE, AUTH_COOKIE_DOMAIN and
+// AUTH_COOKIE_SAMESITE, the cookies live as long as the tokens
+func CookieConfigFromEnv(tokenConfig token.Config) (CookieConfig, error) {
+	enabled, _ := strconv.ParseBool(os.Getenv("AUTH_COOKIE_MODE"))
+	config := CookieConfig{
+		Enabled:         enabled,
+		Domain:          os.Getenv("AUTH_COOKIE_DOMAIN"),
+		AccessTokenTTL:  tokenConfig.AccessTokenTTL,
+		RefreshTokenTTL: tokenConfig.RefreshTokenTTL,
+	}
+
+	switch sameSite := strings.ToLower(os.Getenv("AUTH_COOKIE_SAMESITE")); sameSite {
+	case "", "strict":
+		config.SameSite = http.SameSiteStrictMode
+	case "lax":
+		config.SameSite = http.SameSiteLaxMode
+	case "none":
+		config.SameSite = http.SameSiteNoneMode
+	default:
+		return config, fmt.Errorf("unsupported AUTH_COOKIE_SAMESITE %q", sameSite)
+	}
+
+	return config, nil
+}
+
+// SetTokens sets the token pair as HttpOnly cookies along with a new CSRF
+// token, which is returned for the client to send back in the
+// X-CSRF-Token header
+func (c CookieConfig) SetTokens(w http.ResponseWriter, tokens *authEntity.TokenPair) (string, error) {
+	csrfToken, err := generateCSRFToken()
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, c.cookie(AccessTokenCookie, tokens.AccessToken, "/", c.AccessTokenTTL, true))
+	http.SetCookie(w, c.cookie(RefreshTokenCookie, tokens.RefreshToken, refreshTokenCookiePath, c.RefreshTokenTTL, true))
+	// the client script reads this one to echo it in the header
+	http.SetCookie(w, c.cookie(CSRFTokenCookie, csrfToken, "/", c.RefreshTokenTTL, false))
+
+	return csrfToken, nil
+}
+
+// Clear expires the token and CSRF cookies
+func (c CookieConfig) Clear(w http.ResponseWriter) {
+	http.SetCookie(w, c.cookie(AccessTokenCookie, "", "/", -1, true))
+	http.SetCookie(w, c.cookie(RefreshTokenCookie, "", refreshTokenCookiePath, -1, true))
+	http.SetCookie(w, c.cookie(CSRFTokenCookie, "", "/", -1, false))
+}
+
+func (c CookieConfig) cookie(name string, value string, path string, ttl time.Duration, httpOnly bool) *http.Cookie {
+	maxAge := int(ttl.Seconds())
+	if ttl < 0 {
+		maxAge = -1
+	}
+
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   c.Domain,
+		MaxAge:   maxAge,
+		Secure:   true,
+		HttpOnly: httpOnly,
+		SameSite: c.SameSite,
+	}
+}
+
+// CheckCSRF verifies the double-submit CSRF token, the X-CSRF-Token header
+// or the csrf_token field of an HTML form must match the CSRF cookie
+func CheckCSRF(r *http.Request) error {
+	cookie, err := r.Cookie(CSRFTokenCookie)
+	if err != nil || cookie.Value == "" {
+		return ErrInvalidCSRFToken
+	}
+
+	header := r.Header.Get(CSRFTokenHeader)
+	if header == "" {
+		header = r.PostFormValue(CSRFTokenField)
+	}
+	if subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+		return ErrInvalidCSRFToken
+	}
+
+	return nil
+}
+
+// isSafeMethod reports whether the method doesn't change state and so
+// needs no CSRF token
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	return false
+}
+
+func generateCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
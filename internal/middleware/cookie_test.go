@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authEntity "go-authentication-exercise/internal/auth/entity"
+	"go-authentication-exercise/internal/auth/token"
+	roleEntity "go-authentication-exercise/internal/role/entity"
+	"go-authentication-exercise/internal/user/entity"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCSRF(t *testing.T) {
+	tests := []struct {
+		name        string
+		cookie      string
+		header      string
+		form        string
+		expectError bool
+	}{
+		{name: "Matching token", cookie: "csrf", header: "csrf"},
+		{name: "Matching form field", cookie: "csrf", form: "csrf_token=csrf"},
+		{name: "Different form field", cookie: "csrf", form: "csrf_token=other", expectError: true},
+		{name: "Missing header", cookie: "csrf", expectError: true},
+		{name: "Missing cookie", header: "csrf", expectError: true},
+		{name: "Different token", cookie: "csrf", header: "other", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/auth/password", strings.NewReader(tt.form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: CSRFTokenCookie, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set(CSRFTokenHeader, tt.header)
+			}
+
+			err := CheckCSRF(req)
+
+			if tt.expectError {
+				assert.Equal(t, ErrInvalidCSRFToken, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSetTokens(t *testing.T) {
+	config := CookieConfig{
+		Enabled:         true,
+		SameSite:        http.SameSiteStrictMode,
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: time.Hour,
+	}
+
+	res := httptest.NewRecorder()
+	csrfToken, err := config.SetTokens(res, &authEntity.TokenPair{AccessToken: "access", RefreshToken: "refresh"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, csrfToken)
+
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range res.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+
+	// the tokens are out of reach of scripts, the CSRF token isn't
+	if assert.Len(t, cookies, 3) {
+		assert.Equal(t, "access", cookies[AccessTokenCookie].Value)
+		assert.True(t, cookies[AccessTokenCookie].HttpOnly)
+		assert.True(t, cookies[AccessTokenCookie].Secure)
+		assert.Equal(t, http.SameSiteStrictMode, cookies[AccessTokenCookie].SameSite)
+		assert.Equal(t, 900, cookies[AccessTokenCookie].MaxAge)
+
+		assert.Equal(t, "refresh", cookies[RefreshTokenCookie].Value)
+		assert.True(t, cookies[RefreshTokenCookie].HttpOnly)
+		assert.Equal(t, "/auth", cookies[RefreshTokenCookie].Path)
+
+		assert.Equal(t, csrfToken, cookies[CSRFTokenCookie].Value)
+		assert.False(t, cookies[CSRFTokenCookie].HttpOnly)
+	}
+}
+
+func TestAuthenticatedWithCookie(t *testing.T) {
+	key := token.NewHMACKey("test-key", []byte("test-secret-key"))
+	testUser := &entity.User{Id: uuid.New(), Username: "testuser"}
+
+	accessToken, err := key.Sign(jwt.MapClaims{
+		"jti": "cookie-jti",
+		"sub": testUser.Id.String(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("Error creating test token: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		enabled        bool
+		method         string
+		csrfHeader     string
+		expectedStatus int
+	}{
+		{name: "Safe method", enabled: true, method: "GET", expectedStatus: http.StatusOK},
+		{name: "State change with CSRF token", enabled: true, method: "POST", csrfHeader: "csrf", expectedStatus: http.StatusOK},
+		{name: "State change without CSRF token", enabled: true, method: "POST", expectedStatus: http.StatusForbidden},
+		{name: "State change with wrong CSRF token", enabled: true, method: "DELETE", csrfHeader: "other", expectedStatus: http.StatusForbidden},
+		{name: "Cookie mode disabled", enabled: false, method: "GET", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			middleware := NewAuthMiddleware(
+				token.NewKeyring(key),
+				token.Config{},
+				newFakeRevokedTokenRepository(),
+				&fakeSessionRepository{},
+				&fakeOAuthClientRepository{},
+				newFakeUserRepository(testUser),
+				&fakeRoleRepository{access: map[uuid.UUID]*roleEntity.Access{}},
+				&fakeAPIKeyService{},
+				CookieConfig{Enabled: tt.enabled}).Authenticated(nextHandler)
+
+			req := httptest.NewRequest(tt.method, "/user/me", nil)
+			req.AddCookie(&http.Cookie{Name: AccessTokenCookie, Value: accessToken})
+			req.AddCookie(&http.Cookie{Name: CSRFTokenCookie, Value: "csrf"})
+			if tt.csrfHeader != "" {
+				req.Header.Set(CSRFTokenHeader, tt.csrfHeader)
+			}
+
+			recorder := httptest.NewRecorder()
+			middleware.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+		})
+	}
+}
+
+func TestCookieSession(t *testing.T) {
+	key := token.NewHMACKey("test-key", []byte("test-secret-key"))
+	testUser := &entity.User{Id: uuid.New(), Username: "testuser"}
+
+	sign := func(claims jwt.MapClaims) string {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		signed, err := key.Sign(claims)
+		if err != nil {
+			t.Fatalf("Error creating test token: %v", err)
+		}
+		return signed
+	}
+	accessToken := sign(jwt.MapClaims{"jti": "cookie-jti", "sub": testUser.Id.String()})
+
+	tests := []struct {
+		name         string
+		enabled      bool
+		method       string
+		cookie       string
+		form         string
+		expectedUser bool
+	}{
+		{name: "Page with the cookie", enabled: true, method: "GET", cookie: accessToken, expectedUser: true},
+		{name: "Form with the CSRF token", enabled: true, method: "POST", cookie: accessToken, form: "csrf_token=csrf", expectedUser: true},
+		{name: "Form without the CSRF token", enabled: true, method: "POST", cookie: accessToken},
+		{name: "Without the cookie", enabled: true, method: "GET"},
+		{name: "Invalid cookie", enabled: true, method: "GET", cookie: accessToken + "x"},
+		{name: "Token of an OAuth client", enabled: true, method: "GET", cookie: sign(jwt.MapClaims{"jti": "client-jti", "sub": testUser.Id.String(), "client_id": "webapp"})},
+		{name: "Cookie mode disabled", method: "GET", cookie: accessToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var user *entity.User
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				user = UserFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})
+
+			middleware := NewAuthMiddleware(
+				token.NewKeyring(key),
+				token.Config{},
+				newFakeRevokedTokenRepository(),
+				&fakeSessionRepository{},
+				&fakeOAuthClientRepository{},
+				newFakeUserRepository(testUser),
+				&fakeRoleRepository{access: map[uuid.UUID]*roleEntity.Access{}},
+				&fakeAPIKeyService{},
+				CookieConfig{Enabled: tt.enabled}).CookieSession(nextHandler)
+
+			req := httptest.NewRequest(tt.method, "/device", strings.NewReader(tt.form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: AccessTokenCookie, Value: tt.cookie})
+			}
+			req.AddCookie(&http.Cookie{Name: CSRFTokenCookie, Value: "csrf"})
+
+			recorder := httptest.NewRecorder()
+			middleware.ServeHTTP(recorder, req)
+
+			// the page is always served, with or without a user
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			if tt.expectedUser {
+				assert.Equal(t, testUser, user)
+			} else {
+				assert.Nil(t, user)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+type AuthMiddleware interface {
+	Authenticated(next http.Handler) http.Handler
+	CookieSession(next http.Handler) http.Handler
+}
+
+type RateLimiter interface {
+	Limit(next http.Handler) http.Handler
+	Allow(key string) (bool, time.Duration)
+}
@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-authentication-exercise/internal/util"
+)
+
+// RateLimitKeyFunc returns the key requests are counted under
+type RateLimitKeyFunc func(r *http.Request) string
+
+// KeyByIP counts requests per client IP
+func KeyByIP(r *http.Request) string {
+	return "ip:" + util.ClientIP(r)
+}
+
+// KeyByUser counts requests per authenticated user or OAuth client,
+// falling back to the client IP. It must run after Authenticated.
+func KeyByUser(r *http.Request) string {
+	if user := UserFromContext(r.Context()); user != nil {
+		return "user:" + user.Id.String()
+	}
+
+	if client := ClientFromContext(r.Context()); client != nil {
+		return "client:" + client.Id
+	}
+
+	return KeyByIP(r)
+}
+
+// KeyByAPIKey counts requests per API key, falling back to KeyByUser. Keys
+// are identified by the one that authenticated the request, or otherwise a
+// hash of the X-API-Key header.
+func KeyByAPIKey(r *http.Request) string {
+	if key := APIKeyFromContext(r.Context()); key != nil {
+		return "key:" + key.Id.String()
+	}
+
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:8])
+	}
+
+	return KeyByUser(r)
+}
+
+type rateLimiter struct {
+	limit  int
+	period time.Duration
+	key    RateLimitKeyFunc
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// NewRateLimiter allows limit requests per period for every key, as a token
+// bucket that holds up to limit tokens and refills evenly over period. A
+// limit of zero disables limiting.
+func NewRateLimiter(limit int, period time.Duration, key RateLimitKeyFunc) RateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		period:  period,
+		key:     key,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Limit rejects requests over the limit with 429 and reports the quota in
+// the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers
+func (l *rateLimiter) Limit(next http.Handler) http.Handler {
+	if l.limit <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, remaining, reset, retryAfter := l.take(l.key(r))
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(l.limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(reset), 10))
+
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.FormatInt(ceilSeconds(retryAfter), 10))
+			util.Error(w, http.StatusTooManyRequests, nil, "Too many requests")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Allow counts a request of the key, for limits keyed by what only the
+// handler knows, like a client that has just been authenticated. It returns
+// whether the request is allowed and, when refused, the time until the next
+// token. The key func of the limiter isn't used.
+func (l *rateLimiter) Allow(key string) (bool, time.Duration) {
+	if l.limit <= 0 {
+		return true, 0
+	}
+
+	allowed, _, _, retryAfter := l.take(key)
+	return allowed, retryAfter
+}
+
+// take removes a token from the key's bucket. It returns whether the
+// request is allowed, the tokens left, the time until the bucket is full
+// again and, when refused, the time until the next token.
+func (l *rateLimiter) take(key string) (bool, int, time.Duration, time.Duration) {
+	now := l.now()
+	rate := float64(l.limit) / l.period.Seconds()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit), updatedAt: now}
+		l.buckets[key] = b
+	}
+
+	// refill for the time passed since the last request
+	b.tokens = math.Min(float64(l.limit), b.tokens+now.Sub(b.updatedAt).Seconds()*rate)
+	b.updatedAt = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	reset := secondsToDuration((float64(l.limit) - b.tokens) / rate)
+	if allowed {
+		return true, int(b.tokens), reset, 0
+	}
+
+	return false, 0, reset, secondsToDuration((1 - b.tokens) / rate)
+}
+
+// sweep drops buckets that have refilled completely, at most once per
+// period. Callers must hold mu.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.period {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.updatedAt) >= l.period {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// ParseRateLimit parses limits like `20/1m`, a number of requests per
+// duration
+func ParseRateLimit(value string) (int, time.Duration, error) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid rate limit %q, expected e.g. 20/1m", value)
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || limit < 1 {
+		return 0, 0, fmt.Errorf("invalid rate limit %q, expected e.g. 20/1m", value)
+	}
+
+	period, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || period <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate limit %q, expected e.g. 20/1m", value)
+	}
+
+	return limit, period, nil
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
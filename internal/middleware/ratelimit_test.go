@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-authentication-exercise/internal/user/entity"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := NewRateLimiter(3, time.Minute, KeyByIP).(*rateLimiter)
+	limiter.now = func() time.Time { return now }
+
+	handler := limiter.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	// the burst is allowed
+	for _, remaining := range []string{"2", "1", "0"} {
+		res := request("192.0.2.1:1234")
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "3", res.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, remaining, res.Header().Get("RateLimit-Remaining"))
+	}
+
+	// then requests are refused until a token refills
+	res := request("192.0.2.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, res.Code)
+	assert.Equal(t, "0", res.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", res.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "20", res.Header().Get("Retry-After"))
+
+	// other clients have their own bucket
+	res = request("192.0.2.2:1234")
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	// one token refills every 20 seconds
+	now = now.Add(20 * time.Second)
+	res = request("192.0.2.1:5678")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "0", res.Header().Get("RateLimit-Remaining"))
+
+	res = request("192.0.2.1:5678")
+	assert.Equal(t, http.StatusTooManyRequests, res.Code)
+
+	// an idle bucket refills completely
+	now = now.Add(time.Hour)
+	res = request("192.0.2.1:1234")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "2", res.Header().Get("RateLimit-Remaining"))
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	limiter := NewRateLimiter(0, 0, KeyByIP)
+
+	handler := limiter.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 10; i++ {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Empty(t, res.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Now()
+	limiter := NewRateLimiter(2, time.Minute, KeyByIP).(*rateLimiter)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		allowed, _ := limiter.Allow("client:gateway")
+		assert.True(t, allowed)
+	}
+
+	allowed, retryAfter := limiter.Allow("client:gateway")
+	assert.False(t, allowed)
+	assert.Equal(t, 30*time.Second, retryAfter)
+
+	// other keys have their own bucket
+	allowed, _ = limiter.Allow("client:worker")
+	assert.True(t, allowed)
+
+	disabled := NewRateLimiter(0, 0, KeyByIP)
+	for i := 0; i < 10; i++ {
+		allowed, _ := disabled.Allow("client:gateway")
+		assert.True(t, allowed)
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	user := &entity.User{Id: uuid.New()}
+
+	tests := []struct {
+		name     string
+		keyFunc  RateLimitKeyFunc
+		apiKey   string
+		user     *entity.User
+		expected string
+	}{
+		{name: "By IP", keyFunc: KeyByIP, expected: "ip:192.0.2.1"},
+		{name: "By user", keyFunc: KeyByUser, user: user, expected: "user:" + user.Id.String()},
+		{name: "By user without user", keyFunc: KeyByUser, expected: "ip:192.0.2.1"},
+		{name: "By API key", keyFunc: KeyByAPIKey, apiKey: "secret", user: user, expected: "key:2bb80d537b1da3e3"},
+		{name: "By API key without key", keyFunc: KeyByAPIKey, user: user, expected: "user:" + user.Id.String()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.user != nil {
+				req = req.WithContext(WithUser(req.Context(), tt.user))
+			}
+
+			assert.Equal(t, tt.expected, tt.keyFunc(req))
+		})
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value          string
+		expectedLimit  int
+		expectedPeriod time.Duration
+		expectError    bool
+	}{
+		{value: "20/1m", expectedLimit: 20, expectedPeriod: time.Minute},
+		{value: "5 / 1h", expectedLimit: 5, expectedPeriod: time.Hour},
+		{value: "20", expectError: true},
+		{value: "0/1m", expectError: true},
+		{value: "20/0s", expectError: true},
+		{value: "many/1m", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			limit, period, err := ParseRateLimit(tt.value)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedLimit, limit)
+			assert.Equal(t, tt.expectedPeriod, period)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package entity
+
+// Role is a named set of permissions that can be assigned to users
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// Access holds the roles of a user and the permissions they grant
+type Access struct {
+	Roles       []string
+	Permissions []string
+}
+
+// HasRole reports whether role is one of the roles
+func (a *Access) HasRole(role string) bool {
+	return contains(a.Roles, role)
+}
+
+// HasPermission reports whether any of the roles grants permission
+func (a *Access) HasPermission(permission string) bool {
+	return contains(a.Permissions, permission)
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
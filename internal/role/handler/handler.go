@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go-authentication-exercise/internal/role/request"
+	"go-authentication-exercise/internal/role/service"
+	"go-authentication-exercise/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type roleHandler struct {
+	service service.RoleService
+}
+
+func NewRoleHandler(sv service.RoleService) RoleHandler {
+	return &roleHandler{
+		service: sv,
+	}
+}
+
+// List returns every role with its permissions
+func (h *roleHandler) List(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.service.List(r.Context())
+	if err != nil {
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, roles, "")
+}
+
+// UserRoles returns the roles of the user in the path
+func (h *roleHandler) UserRoles(w http.ResponseWriter, r *http.Request) {
+	userId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid user id")
+		return
+	}
+
+	roles, err := h.service.UserRoles(r.Context(), userId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	util.Success(w, http.StatusOK, map[string]interface{}{"roles": roles}, "")
+}
+
+// Assign gives the user in the path a role
+func (h *roleHandler) Assign(w http.ResponseWriter, r *http.Request) {
+	userId, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid user id")
+		return
+	}
+
+	// get payload
+	payload := &request.AssignRoleRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	if err := h.service.Assign(r.Context(), userId, payload.Role); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	util.Success(w, http.StatusOK, nil, "Role assigned")
+}
+
+// Revoke takes the role in the path from the user
+func (h *roleHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	userId, err := uuid.Parse(vars["id"])
+	if err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid user id")
+		return
+	}
+
+	if err := h.service.Revoke(r.Context(), userId, vars["role"]); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	util.Success(w, http.StatusOK, nil, "Role revoked")
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound),
+		errors.Is(err, service.ErrRoleNotAssigned):
+		util.Error(w, http.StatusNotFound, nil, err.Error())
+	case errors.Is(err, service.ErrRoleNotFound):
+		util.Error(w, http.StatusBadRequest, nil, err.Error())
+	default:
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+	}
+}
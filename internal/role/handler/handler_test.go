@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-authentication-exercise/internal/role/entity"
+	"go-authentication-exercise/internal/role/service"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRoleService is a mock implementation of the RoleService interface
+type MockRoleService struct {
+	mock.Mock
+}
+
+func (m *MockRoleService) List(ctx context.Context) ([]*entity.Role, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Role), args.Error(1)
+}
+
+func (m *MockRoleService) UserRoles(ctx context.Context, userId uuid.UUID) ([]string, error) {
+	args := m.Called(ctx, userId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRoleService) Assign(ctx context.Context, userId uuid.UUID, role string) error {
+	args := m.Called(ctx, userId, role)
+	return args.Error(0)
+}
+
+func (m *MockRoleService) Revoke(ctx context.Context, userId uuid.UUID, role string) error {
+	args := m.Called(ctx, userId, role)
+	return args.Error(0)
+}
+
+// serve routes the request like setupRouter, so path variables are set
+func serve(h RoleHandler, req *http.Request) *httptest.ResponseRecorder {
+	r := mux.NewRouter()
+	r.HandleFunc("/admin/users/{id}/roles", h.UserRoles).Methods("GET")
+	r.HandleFunc("/admin/users/{id}/roles", h.Assign).Methods("POST")
+	r.HandleFunc("/admin/users/{id}/roles/{role}", h.Revoke).Methods("DELETE")
+
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	return res
+}
+
+func TestUserRoles(t *testing.T) {
+	userId := uuid.New()
+
+	tests := []struct {
+		name               string
+		path               string
+		setupMock          func(*MockRoleService)
+		expectedStatusCode int
+	}{
+		{
+			name: "Roles of user",
+			path: "/admin/users/" + userId.String() + "/roles",
+			setupMock: func(mockService *MockRoleService) {
+				mockService.On("UserRoles", mock.Anything, userId).Return([]string{"admin"}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "Unknown user",
+			path: "/admin/users/" + userId.String() + "/roles",
+			setupMock: func(mockService *MockRoleService) {
+				mockService.On("UserRoles", mock.Anything, userId).Return(nil, service.ErrUserNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Invalid user id",
+			path:               "/admin/users/not-a-uuid/roles",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockRoleService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			res := serve(NewRoleHandler(mockService), httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAssign(t *testing.T) {
+	userId := uuid.New()
+	path := "/admin/users/" + userId.String() + "/roles"
+
+	tests := []struct {
+		name               string
+		requestBody        map[string]interface{}
+		setupMock          func(*MockRoleService)
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name:        "Role assigned",
+			requestBody: map[string]interface{}{"role": "admin"},
+			setupMock: func(mockService *MockRoleService) {
+				mockService.On("Assign", mock.Anything, userId, "admin").Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    "Role assigned",
+		},
+		{
+			name:        "Unknown role",
+			requestBody: map[string]interface{}{"role": "superuser"},
+			setupMock: func(mockService *MockRoleService) {
+				mockService.On("Assign", mock.Anything, userId, "superuser").Return(service.ErrRoleNotFound)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    service.ErrRoleNotFound.Error(),
+		},
+		{
+			name:               "Missing role",
+			requestBody:        map[string]interface{}{},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    "Validation error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockRoleService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			jsonBody, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", path, bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+
+			res := serve(NewRoleHandler(mockService), req)
+
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			var responseBody map[string]interface{}
+			assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &responseBody))
+			assert.Equal(t, tt.expectedMessage, responseBody["message"])
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	userId := uuid.New()
+	path := "/admin/users/" + userId.String() + "/roles/admin"
+
+	tests := []struct {
+		name               string
+		err                error
+		expectedStatusCode int
+	}{
+		{
+			name:               "Role revoked",
+			err:                nil,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Role not assigned",
+			err:                service.ErrRoleNotAssigned,
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockRoleService)
+			mockService.On("Revoke", mock.Anything, userId, "admin").Return(tt.err)
+
+			res := serve(NewRoleHandler(mockService), httptest.NewRequest("DELETE", path, nil))
+
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"net/http"
+)
+
+type RoleHandler interface {
+	List(w http.ResponseWriter, r *http.Request)
+	UserRoles(w http.ResponseWriter, r *http.Request)
+	Assign(w http.ResponseWriter, r *http.Request)
+	Revoke(w http.ResponseWriter, r *http.Request)
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+
+	"go-authentication-exercise/internal/role/entity"
+
+	"github.com/google/uuid"
+)
+
+type RoleRepository interface {
+	List(ctx context.Context) ([]*entity.Role, error)
+	FindOneByName(ctx context.Context, name string) (*entity.Role, error)
+	FindAccessByUserId(ctx context.Context, userId uuid.UUID) (*entity.Access, error)
+	Assign(ctx context.Context, userId uuid.UUID, role string) error
+	Revoke(ctx context.Context, userId uuid.UUID, role string) (bool, error)
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"go-authentication-exercise/internal/role/entity"
+
+	"github.com/google/uuid"
+)
+
+type roleRepository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) RoleRepository {
+	return &roleRepository{
+		db: db,
+	}
+}
+
+func (r *roleRepository) List(ctx context.Context) ([]*entity.Role, error) {
+	query := `SELECT r.name, r.description, rp.permission
+			FROM roles r
+			LEFT JOIN role_permissions rp ON rp.role = r.name
+			ORDER BY r.name, rp.permission`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []*entity.Role
+	for rows.Next() {
+		var role entity.Role
+		var permission sql.NullString
+		if err := rows.Scan(&role.Name, &role.Description, &permission); err != nil {
+			return nil, err
+		}
+
+		// rows of the same role are adjacent
+		if len(res) == 0 || res[len(res)-1].Name != role.Name {
+			role.Permissions = []string{}
+			res = append(res, &role)
+		}
+
+		if permission.Valid {
+			last := res[len(res)-1]
+			last.Permissions = append(last.Permissions, permission.String)
+		}
+	}
+
+	return res, rows.Err()
+}
+
+func (r *roleRepository) FindOneByName(ctx context.Context, name string) (*entity.Role, error) {
+	roles, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, role := range roles {
+		if role.Name == name {
+			return role, nil
+		}
+	}
+
+	return nil, nil // no record
+}
+
+// FindAccessByUserId returns the roles of the user and the permissions
+// they grant, both empty for users without roles
+func (r *roleRepository) FindAccessByUserId(ctx context.Context, userId uuid.UUID) (*entity.Access, error) {
+	query := `SELECT ur.role, rp.permission
+			FROM user_roles ur
+			LEFT JOIN role_permissions rp ON rp.role = ur.role
+			WHERE ur.user_id = $1
+			ORDER BY ur.role, rp.permission`
+
+	rows, err := r.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	access := &entity.Access{Roles: []string{}, Permissions: []string{}}
+	seen := map[string]bool{}
+	for rows.Next() {
+		var role string
+		var permission sql.NullString
+		if err := rows.Scan(&role, &permission); err != nil {
+			return nil, err
+		}
+
+		if n := len(access.Roles); n == 0 || access.Roles[n-1] != role {
+			access.Roles = append(access.Roles, role)
+		}
+
+		if permission.Valid && !seen[permission.String] {
+			seen[permission.String] = true
+			access.Permissions = append(access.Permissions, permission.String)
+		}
+	}
+
+	return access, rows.Err()
+}
+
+// Assign gives the user the role, assigning it twice is a no-op
+func (r *roleRepository) Assign(ctx context.Context, userId uuid.UUID, role string) error {
+	query := `INSERT INTO user_roles (user_id, role) VALUES ($1, $2)
+			ON CONFLICT (user_id, role) DO NOTHING`
+
+	_, err := r.db.ExecContext(ctx, query, userId, role)
+	return err
+}
+
+// Revoke takes the role from the user, it reports false when the user
+// didn't have it
+func (r *roleRepository) Revoke(ctx context.Context, userId uuid.UUID, role string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM user_roles WHERE user_id = $1 AND role = $2`, userId, role)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
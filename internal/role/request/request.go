@@ -0,0 +1,5 @@
+package request
+
+type AssignRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
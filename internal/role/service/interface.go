@@ -0,0 +1,16 @@
+package service
+
+import (
+	"context"
+
+	"go-authentication-exercise/internal/role/entity"
+
+	"github.com/google/uuid"
+)
+
+type RoleService interface {
+	List(ctx context.Context) ([]*entity.Role, error)
+	UserRoles(ctx context.Context, userId uuid.UUID) ([]string, error)
+	Assign(ctx context.Context, userId uuid.UUID, role string) error
+	Revoke(ctx context.Context, userId uuid.UUID, role string) error
+}
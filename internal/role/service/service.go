@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"go-authentication-exercise/internal/role/entity"
+	"go-authentication-exercise/internal/role/repository"
+	userRepository "go-authentication-exercise/internal/user/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrRoleNotFound    = errors.New("role not found")
+	ErrRoleNotAssigned = errors.New("user doesn't have the role")
+)
+
+type roleService struct {
+	repository repository.RoleRepository
+	users      userRepository.UserRepository
+}
+
+func NewService(repo repository.RoleRepository, users userRepository.UserRepository) RoleService {
+	return &roleService{
+		repository: repo,
+		users:      users,
+	}
+}
+
+func (s *roleService) List(ctx context.Context) ([]*entity.Role, error) {
+	return s.repository.List(ctx)
+}
+
+// UserRoles returns the names of the roles assigned to the user
+func (s *roleService) UserRoles(ctx context.Context, userId uuid.UUID) ([]string, error) {
+	if err := s.checkUser(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	access, err := s.repository.FindAccessByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return access.Roles, nil
+}
+
+// Assign gives the user the role. Tokens issued before carry the old role
+// claims, permissions are checked against the current roles.
+func (s *roleService) Assign(ctx context.Context, userId uuid.UUID, role string) error {
+	if err := s.checkUser(ctx, userId); err != nil {
+		return err
+	}
+
+	existing, err := s.repository.FindOneByName(ctx, role)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		return ErrRoleNotFound
+	}
+
+	return s.repository.Assign(ctx, userId, role)
+}
+
+// Revoke takes the role from the user
+func (s *roleService) Revoke(ctx context.Context, userId uuid.UUID, role string) error {
+	if err := s.checkUser(ctx, userId); err != nil {
+		return err
+	}
+
+	revoked, err := s.repository.Revoke(ctx, userId, role)
+	if err != nil {
+		return err
+	}
+
+	if !revoked {
+		return ErrRoleNotAssigned
+	}
+
+	return nil
+}
+
+func (s *roleService) checkUser(ctx context.Context, userId uuid.UUID) error {
+	user, err := s.users.FindOneById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
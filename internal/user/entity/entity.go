@@ -10,8 +10,18 @@ type User struct {
 	Id        uuid.UUID  `json:"id"`
 	Username  string     `json:"username"`
 	Fullname  string     `json:"fullname"`
+	Email     string     `json:"email"`
 	Password  string     `json:"-"`
 	CreatedAt time.Time  `json:"createdAt"`
 	UpdatedAt time.Time  `json:"updatedAt"`
 	DeletedAt *time.Time `json:"deletedAt"`
+
+	// set once the user proved they own Email
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+
+	// disabled users can't log in until an admin enables them again
+	DisabledAt *time.Time `json:"disabledAt"`
+
+	// access tokens issued before this time are rejected
+	TokensValidAfter *time.Time `json:"-"`
 }
@@ -0,0 +1,17 @@
+package entity
+
+import "golang.org/x/crypto/bcrypt"
+
+// bcrypt only uses the first 72 bytes of a password
+const MaxPasswordLength = 72
+
+// CheckPassword reports whether password matches the bcrypt hash. Longer
+// passwords than bcrypt reads never match, so one can't get in with just
+// the first 72 bytes of another.
+func CheckPassword(password string, hash string) bool {
+	if len(password) > MaxPasswordLength {
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
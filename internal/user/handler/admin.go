@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go-authentication-exercise/internal/middleware"
+	"go-authentication-exercise/internal/user/request"
+	"go-authentication-exercise/internal/user/service"
+	"go-authentication-exercise/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Get returns the user in the path, soft-deleted users included
+func (h *userHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserId(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	util.Success(w, http.StatusOK, user, "")
+}
+
+// Update changes the username and/or fullname of the user in the path
+func (h *userHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathUserId(w, r)
+	if !ok {
+		return
+	}
+
+	// get payload
+	payload := &request.UpdateProfileRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	updated, err := h.service.UpdateProfile(r.Context(), id, payload.Username, payload.Fullname)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	util.Success(w, http.StatusOK, updated, "")
+}
+
+// Disable blocks the user in the path from logging in
+func (h *userHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	h.otherUserAction(w, r, h.service.Disable, "User disabled")
+}
+
+// Enable lets the user in the path log in again
+func (h *userHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.service.Enable, "User enabled")
+}
+
+// Delete soft-deletes the user in the path
+func (h *userHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	h.otherUserAction(w, r, h.service.Delete, "User deleted")
+}
+
+// Restore undoes the soft delete of the user in the path
+func (h *userHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.service.Restore, "User restored")
+}
+
+// ForcePasswordReset clears the password of the user in the path and
+// mails them a reset link
+func (h *userHandler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.service.ForcePasswordReset, "Password reset required")
+}
+
+// Unlock lifts a login lockout of the user in the path
+func (h *userHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	h.userAction(w, r, h.service.Unlock, "User unlocked")
+}
+
+// userAction runs action on the user in the path
+func (h *userHandler) userAction(w http.ResponseWriter, r *http.Request, action func(context.Context, uuid.UUID) error, message string) {
+	id, ok := pathUserId(w, r)
+	if !ok {
+		return
+	}
+
+	if err := action(r.Context(), id); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	util.Success(w, http.StatusOK, nil, message)
+}
+
+// otherUserAction is userAction for actions that would lock the admin out
+// if applied to their own account
+func (h *userHandler) otherUserAction(w http.ResponseWriter, r *http.Request, action func(context.Context, uuid.UUID) error, message string) {
+	if current := middleware.UserFromContext(r.Context()); current != nil && current.Id.String() == mux.Vars(r)["id"] {
+		util.Error(w, http.StatusBadRequest, nil, "You can't do this to your own account")
+		return
+	}
+
+	h.userAction(w, r, action, message)
+}
+
+// pathUserId parses the `id` path variable, answering 400 if it isn't a
+// valid id
+func pathUserId(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid user id")
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		util.Error(w, http.StatusNotFound, nil, err.Error())
+	case errors.Is(err, service.ErrUsernameTaken),
+		errors.Is(err, service.ErrEmailTaken),
+		errors.Is(err, service.ErrNoEmail),
+		errors.Is(err, service.ErrUserNotDeleted),
+		errors.Is(err, service.ErrRestoreExpired):
+		util.Error(w, http.StatusConflict, nil, err.Error())
+	default:
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+	}
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-authentication-exercise/internal/middleware"
+	"go-authentication-exercise/internal/user/entity"
+	"go-authentication-exercise/internal/user/service"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// serveAdmin routes the request like setupRouter, as the given admin
+func serveAdmin(h UserHandler, admin *entity.User, req *http.Request) *httptest.ResponseRecorder {
+	r := mux.NewRouter()
+	r.HandleFunc("/admin/users/{id}", h.Get).Methods("GET")
+	r.HandleFunc("/admin/users/{id}", h.Delete).Methods("DELETE")
+	r.HandleFunc("/admin/users/{id}/disable", h.Disable).Methods("POST")
+	r.HandleFunc("/admin/users/{id}/restore", h.Restore).Methods("POST")
+	r.HandleFunc("/admin/users/{id}/unlock", h.Unlock).Methods("POST")
+
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, req.WithContext(middleware.WithUser(req.Context(), admin)))
+	return res
+}
+
+func TestAdminGet(t *testing.T) {
+	user := testUser()
+
+	tests := []struct {
+		name               string
+		path               string
+		setupMock          func(*MockUserService)
+		expectedStatusCode int
+	}{
+		{
+			name: "Existing user",
+			path: "/admin/users/" + user.Id.String(),
+			setupMock: func(mockService *MockUserService) {
+				mockService.On("Get", mock.Anything, user.Id).Return(user, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "Unknown user",
+			path: "/admin/users/" + user.Id.String(),
+			setupMock: func(mockService *MockUserService) {
+				mockService.On("Get", mock.Anything, user.Id).Return(nil, service.ErrUserNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Invalid user id",
+			path:               "/admin/users/not-a-uuid",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockUserService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			res := serveAdmin(NewUserHandler(mockService), testUser(), httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdminActions(t *testing.T) {
+	admin := testUser()
+	userId := uuid.New()
+
+	tests := []struct {
+		name               string
+		method             string
+		path               string
+		setupMock          func(*MockUserService)
+		expectedStatusCode int
+	}{
+		{
+			name:   "Disable user",
+			method: "POST",
+			path:   "/admin/users/" + userId.String() + "/disable",
+			setupMock: func(mockService *MockUserService) {
+				mockService.On("Disable", mock.Anything, userId).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Disable own account",
+			method:             "POST",
+			path:               "/admin/users/" + admin.Id.String() + "/disable",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "Delete unknown user",
+			method: "DELETE",
+			path:   "/admin/users/" + userId.String(),
+			setupMock: func(mockService *MockUserService) {
+				mockService.On("Delete", mock.Anything, userId).Return(service.ErrUserNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Delete own account",
+			method:             "DELETE",
+			path:               "/admin/users/" + admin.Id.String(),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "Restore user",
+			method: "POST",
+			path:   "/admin/users/" + userId.String() + "/restore",
+			setupMock: func(mockService *MockUserService) {
+				mockService.On("Restore", mock.Anything, userId).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "Restore with taken username",
+			method: "POST",
+			path:   "/admin/users/" + userId.String() + "/restore",
+			setupMock: func(mockService *MockUserService) {
+				mockService.On("Restore", mock.Anything, userId).Return(service.ErrUsernameTaken)
+			},
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name:   "Unlock user",
+			method: "POST",
+			path:   "/admin/users/" + userId.String() + "/unlock",
+			setupMock: func(mockService *MockUserService) {
+				mockService.On("Unlock", mock.Anything, userId).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "Unlock unknown user",
+			method: "POST",
+			path:   "/admin/users/" + userId.String() + "/unlock",
+			setupMock: func(mockService *MockUserService) {
+				mockService.On("Unlock", mock.Anything, userId).Return(service.ErrUserNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockUserService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			res := serveAdmin(NewUserHandler(mockService), admin, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
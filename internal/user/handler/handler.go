@@ -2,13 +2,15 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 
+	"go-authentication-exercise/internal/middleware"
 	"go-authentication-exercise/internal/user/entity"
-	"go-authentication-exercise/internal/util"
-
-	// "go-authentication-exercise/internal/user/request"
+	"go-authentication-exercise/internal/user/request"
 	"go-authentication-exercise/internal/user/service"
+	"go-authentication-exercise/internal/util"
 )
 
 type userHandler struct {
@@ -52,6 +54,93 @@ func (h *userHandler) List(w http.ResponseWriter, r *http.Request) {
 	util.Success(w, http.StatusOK, paginatedData, "")
 }
 
+// Me returns the profile of the authenticated user
+func (h *userHandler) Me(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		util.Error(w, http.StatusUnauthorized, nil, "Unauthorized")
+		return
+	}
+
+	util.Success(w, http.StatusOK, user, "")
+}
+
+// UpdateMe changes the profile of the authenticated user
+func (h *userHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := middleware.UserFromContext(ctx)
+	if user == nil {
+		util.Error(w, http.StatusUnauthorized, nil, "Unauthorized")
+		return
+	}
+
+	// get payload
+	payload := &request.UpdateProfileRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	// update
+	updated, err := h.service.UpdateProfile(ctx, user.Id, payload.Username, payload.Fullname)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUsernameTaken):
+			util.Error(w, http.StatusConflict, nil, err.Error())
+		case errors.Is(err, service.ErrUserNotFound):
+			util.Error(w, http.StatusNotFound, nil, err.Error())
+		default:
+			util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		}
+		return
+	}
+
+	util.Success(w, http.StatusOK, updated, "")
+}
+
+// DeleteMe deletes the account of the authenticated user after checking
+// their password
+func (h *userHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := middleware.UserFromContext(ctx)
+	if user == nil {
+		util.Error(w, http.StatusUnauthorized, nil, "Unauthorized")
+		return
+	}
+
+	// get payload
+	payload := &request.DeleteAccountRequest{}
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		util.Error(w, http.StatusBadRequest, nil, "Invalid request")
+		return
+	}
+
+	// validate
+	if errors := util.ValidateRequest(payload); len(errors) > 0 {
+		util.Error(w, http.StatusBadRequest, errors, "Validation error")
+		return
+	}
+
+	if err := h.service.DeleteAccount(ctx, user.Id, payload.Password); err != nil {
+		if errors.Is(err, service.ErrIncorrectPassword) {
+			util.Error(w, http.StatusBadRequest, nil, err.Error())
+			return
+		}
+		util.Error(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	util.Success(w, http.StatusOK, nil, "Account deleted")
+}
+
 func listToResponse(list []*entity.User) []map[string]interface{} {
 	var res []map[string]interface{}
 
@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"go-authentication-exercise/internal/middleware"
+	"go-authentication-exercise/internal/user/entity"
+	"go-authentication-exercise/internal/user/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockUserService is a mock implementation of the UserService interface
+type MockUserService struct {
+	mock.Mock
+}
+
+func (m *MockUserService) List(ctx context.Context) ([]*entity.User, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.User), args.Error(1)
+}
+
+func (m *MockUserService) Count(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockUserService) UpdateProfile(ctx context.Context, id uuid.UUID, username *string, fullname *string) (*entity.User, error) {
+	args := m.Called(ctx, id, username, fullname)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *MockUserService) Get(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.User), args.Error(1)
+}
+
+func (m *MockUserService) Disable(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserService) Enable(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserService) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserService) Restore(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ForcePasswordReset(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserService) Unlock(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserService) DeleteAccount(ctx context.Context, id uuid.UUID, password string) error {
+	args := m.Called(ctx, id, password)
+	return args.Error(0)
+}
+
+func (m *MockUserService) Purge(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUserService) RunPurge(ctx context.Context, every time.Duration) {
+	m.Called(ctx, every)
+}
+
+// testUser returns the user the requests are authenticated as
+func testUser() *entity.User {
+	return &entity.User{
+		Id:        uuid.New(),
+		Username:  "testuser",
+		Fullname:  "Test User",
+		Password:  "hashed-password",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestMe(t *testing.T) {
+	user := testUser()
+
+	tests := []struct {
+		name               string
+		user               *entity.User
+		expectedStatusCode int
+	}{
+		{
+			name:               "Authenticated user",
+			user:               user,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "No user in context",
+			user:               nil,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(new(MockUserService))
+
+			req := httptest.NewRequest("GET", "/user/me", nil)
+			if tt.user != nil {
+				req = req.WithContext(middleware.WithUser(req.Context(), tt.user))
+			}
+			res := httptest.NewRecorder()
+
+			handler.Me(res, req)
+
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			if tt.expectedStatusCode == http.StatusOK {
+				var responseBody map[string]interface{}
+				err := json.Unmarshal(res.Body.Bytes(), &responseBody)
+				assert.NoError(t, err)
+
+				userData, ok := responseBody["data"].(map[string]interface{})
+				assert.True(t, ok, "Response data should be a user object")
+				assert.Equal(t, user.Id.String(), userData["id"])
+				assert.Equal(t, "testuser", userData["username"])
+				assert.Equal(t, "Test User", userData["fullname"])
+				_, passwordExists := userData["password"]
+				assert.False(t, passwordExists, "Password should not be included in response")
+			}
+		})
+	}
+}
+
+func TestUpdateMe(t *testing.T) {
+	user := testUser()
+
+	tests := []struct {
+		name               string
+		requestBody        map[string]interface{}
+		setupMock          func(*MockUserService)
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name: "Update fullname",
+			requestBody: map[string]interface{}{
+				"fullname": "New Name",
+			},
+			setupMock: func(mockService *MockUserService) {
+				updated := *user
+				updated.Fullname = "New Name"
+				mockService.On("UpdateProfile", mock.Anything, user.Id, (*string)(nil), stringPtr("New Name")).
+					Return(&updated, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    "",
+		},
+		{
+			name: "Username taken",
+			requestBody: map[string]interface{}{
+				"username": "otheruser",
+			},
+			setupMock: func(mockService *MockUserService) {
+				mockService.On("UpdateProfile", mock.Anything, user.Id, stringPtr("otheruser"), (*string)(nil)).
+					Return(nil, service.ErrUsernameTaken)
+			},
+			expectedStatusCode: http.StatusConflict,
+			expectedMessage:    service.ErrUsernameTaken.Error(),
+		},
+		{
+			name: "Service failure",
+			requestBody: map[string]interface{}{
+				"fullname": "New Name",
+			},
+			setupMock: func(mockService *MockUserService) {
+				mockService.On("UpdateProfile", mock.Anything, user.Id, (*string)(nil), stringPtr("New Name")).
+					Return(nil, errors.New("database unavailable"))
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedMessage:    "database unavailable",
+		},
+		{
+			name: "Invalid username",
+			requestBody: map[string]interface{}{
+				"username": "u", // Too short, validation should fail
+			},
+			setupMock: func(mockService *MockUserService) {
+				// Service mock should not be called since validation fails
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    "Validation error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mock service
+			mockService := new(MockUserService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			// Create the handler with mock service
+			handler := NewUserHandler(mockService)
+
+			// Create a request authenticated as the test user
+			jsonBody, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("PATCH", "/user/me", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(middleware.WithUser(req.Context(), user))
+
+			// Create a response recorder
+			res := httptest.NewRecorder()
+
+			// Call the handler
+			handler.UpdateMe(res, req)
+
+			// Check status code
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			// Parse response
+			var responseBody map[string]interface{}
+			err := json.Unmarshal(res.Body.Bytes(), &responseBody)
+			assert.NoError(t, err)
+
+			// Check response message
+			assert.Equal(t, tt.expectedMessage, responseBody["message"])
+
+			// Verify that all expected mock calls were made
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteMe(t *testing.T) {
+	user := testUser()
+
+	tests := []struct {
+		name               string
+		requestBody        map[string]interface{}
+		setupMock          func(*MockUserService)
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name: "Account deleted",
+			requestBody: map[string]interface{}{
+				"password": "password123",
+			},
+			setupMock: func(mockService *MockUserService) {
+				mockService.On("DeleteAccount", mock.Anything, user.Id, "password123").Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedMessage:    "Account deleted",
+		},
+		{
+			name: "Wrong password",
+			requestBody: map[string]interface{}{
+				"password": "wrong-password",
+			},
+			setupMock: func(mockService *MockUserService) {
+				mockService.On("DeleteAccount", mock.Anything, user.Id, "wrong-password").Return(service.ErrIncorrectPassword)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    service.ErrIncorrectPassword.Error(),
+		},
+		{
+			name:               "Missing password",
+			requestBody:        map[string]interface{}{},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    "Validation error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockUserService)
+			if tt.setupMock != nil {
+				tt.setupMock(mockService)
+			}
+
+			handler := NewUserHandler(mockService)
+
+			jsonBody, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("DELETE", "/user/me", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(middleware.WithUser(req.Context(), user))
+			res := httptest.NewRecorder()
+
+			handler.DeleteMe(res, req)
+
+			assert.Equal(t, tt.expectedStatusCode, res.Code)
+
+			var responseBody map[string]interface{}
+			assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &responseBody))
+			assert.Equal(t, tt.expectedMessage, responseBody["message"])
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-authentication-exercise/internal/user/entity"
+
+	"github.com/google/uuid"
+)
+
+// cachedRepository caches FindOneById lookups for ttl, so resolving the
+// authenticated user doesn't cost a query on every request. Methods that
+// aren't overridden go straight to the wrapped repository.
+type cachedRepository struct {
+	UserRepository
+	ttl time.Duration
+
+	mu        sync.Mutex
+	users     map[uuid.UUID]cachedUser
+	lastSweep time.Time
+}
+
+type cachedUser struct {
+	user      entity.User
+	expiresAt time.Time
+}
+
+func NewCachedRepository(next UserRepository, ttl time.Duration) UserRepository {
+	return &cachedRepository{
+		UserRepository: next,
+		ttl:            ttl,
+		users:          make(map[uuid.UUID]cachedUser),
+	}
+}
+
+func (r *cachedRepository) FindOneById(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	if cached, ok := r.users[id]; ok && now.Before(cached.expiresAt) {
+		r.mu.Unlock()
+		user := cached.user
+		return &user, nil
+	}
+	r.mu.Unlock()
+
+	user, err := r.UserRepository.FindOneById(ctx, id)
+	if err != nil || user == nil {
+		return user, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sweep(now)
+	r.users[id] = cachedUser{
+		user:      *user,
+		expiresAt: now.Add(r.ttl),
+	}
+
+	return user, nil
+}
+
+func (r *cachedRepository) Update(ctx context.Context, m *entity.User) (*entity.User, error) {
+	defer r.invalidate(m.Id)
+	return r.UserRepository.Update(ctx, m)
+}
+
+func (r *cachedRepository) UpdatePassword(ctx context.Context, id uuid.UUID, password string, tokensValidAfter time.Time) error {
+	defer r.invalidate(id)
+	return r.UserRepository.UpdatePassword(ctx, id, password, tokensValidAfter)
+}
+
+func (r *cachedRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, email string) (bool, error) {
+	defer r.invalidate(id)
+	return r.UserRepository.MarkEmailVerified(ctx, id, email)
+}
+
+func (r *cachedRepository) Disable(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	defer r.invalidate(id)
+	return r.UserRepository.Disable(ctx, id, at)
+}
+
+func (r *cachedRepository) Enable(ctx context.Context, id uuid.UUID) (bool, error) {
+	defer r.invalidate(id)
+	return r.UserRepository.Enable(ctx, id)
+}
+
+func (r *cachedRepository) Delete(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	defer r.invalidate(id)
+	return r.UserRepository.Delete(ctx, id, at)
+}
+
+func (r *cachedRepository) Restore(ctx context.Context, id uuid.UUID) (bool, error) {
+	defer r.invalidate(id)
+	return r.UserRepository.Restore(ctx, id)
+}
+
+// invalidate drops the cached user, for methods that change it
+func (r *cachedRepository) invalidate(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.users, id)
+}
+
+// sweep drops expired entries, at most once per ttl. Callers must hold mu.
+func (r *cachedRepository) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < r.ttl {
+		return
+	}
+	r.lastSweep = now
+
+	for id, cached := range r.users {
+		if now.After(cached.expiresAt) {
+			delete(r.users, id)
+		}
+	}
+}
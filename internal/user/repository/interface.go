@@ -2,13 +2,28 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"go-authentication-exercise/internal/user/entity"
+
+	"github.com/google/uuid"
 )
 
 type UserRepository interface {
 	List(ctx context.Context) ([]*entity.User, error)
 	Count(ctx context.Context) (int, error)
+	FindOneById(ctx context.Context, id uuid.UUID) (*entity.User, error)
+	FindOneByIdIncludingDeleted(ctx context.Context, id uuid.UUID) (*entity.User, error)
 	FindOneByUsername(ctx context.Context, username string) (*entity.User, error)
+	FindOneByEmail(ctx context.Context, email string) (*entity.User, error)
 	Create(ctx context.Context, u *entity.User) (*entity.User, error)
+	Update(ctx context.Context, u *entity.User) (*entity.User, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, password string, tokensValidAfter time.Time) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID, email string) (bool, error)
+	Disable(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+	Enable(ctx context.Context, id uuid.UUID) (bool, error)
+	Delete(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+	Restore(ctx context.Context, id uuid.UUID) (bool, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	Anonymize(ctx context.Context, deletedBefore time.Time) (int64, error)
 }
@@ -3,13 +3,28 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"go-authentication-exercise/internal/user/entity"
 	"go-authentication-exercise/internal/util"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateUsername = errors.New("username exists")
+	ErrDuplicateEmail    = errors.New("email exists")
 )
 
+// userColumns are selected and returned by every query, in scanUser order.
+// Users created before emails were introduced have none.
+const userColumns = "id, username, fullname, COALESCE(email, '') AS email, email_verified_at, password, " +
+	"created_at, updated_at, deleted_at, disabled_at, tokens_valid_after"
+
 type userRepository struct {
 	db *sql.DB
 }
@@ -25,7 +40,7 @@ func (r *userRepository) List(ctx context.Context) (res []*entity.User, err erro
 	paging := ctx.Value("paging").(*util.Paging)
 
 	// initial sql
-	start := "SELECT id, username, password, created_at, updated_at, deleted_at FROM users WHERE deleted_at IS NULL"
+	start := "SELECT " + userColumns + " FROM users WHERE deleted_at IS NULL"
 
 	var sql strings.Builder
 	sql.WriteString(start)
@@ -36,16 +51,11 @@ func (r *userRepository) List(ctx context.Context) (res []*entity.User, err erro
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var user entity.User
-		if err := rows.Scan(
-			&user.Id,
-			&user.Username,
-			&user.Password,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-			&user.DeletedAt); err != nil {
+		if err := scanUser(rows, &user); err != nil {
 			return nil, err
 		}
 
@@ -68,45 +78,234 @@ func (r *userRepository) Count(ctx context.Context) (int, error) {
 	return count, nil
 }
 
+func (r *userRepository) FindOneById(ctx context.Context, id uuid.UUID) (res *entity.User, err error) {
+	sql := "SELECT " + userColumns + " FROM users WHERE id = $1 AND deleted_at IS NULL"
+
+	return r.findOne(ctx, sql, id)
+}
+
+// FindOneByIdIncludingDeleted finds soft-deleted users as well
+func (r *userRepository) FindOneByIdIncludingDeleted(ctx context.Context, id uuid.UUID) (res *entity.User, err error) {
+	sql := "SELECT " + userColumns + " FROM users WHERE id = $1"
+
+	return r.findOne(ctx, sql, id)
+}
+
 func (r *userRepository) FindOneByUsername(ctx context.Context, username string) (res *entity.User, err error) {
-	sql := "SELECT id, username, fullname, password, created_at, updated_at, deleted_at FROM users WHERE username = $1 AND deleted_at IS NULL"
+	sql := "SELECT " + userColumns + " FROM users WHERE username = $1 AND deleted_at IS NULL"
 
-	row := r.db.QueryRow(sql, username)
+	return r.findOne(ctx, sql, username)
+}
 
-	user := entity.User{}
+// FindOneByEmail matches the email case-insensitively
+func (r *userRepository) FindOneByEmail(ctx context.Context, email string) (res *entity.User, err error) {
+	sql := "SELECT " + userColumns + " FROM users WHERE lower(email) = lower($1) AND deleted_at IS NULL"
+
+	return r.findOne(ctx, sql, email)
+}
+
+func (r *userRepository) Create(ctx context.Context, m *entity.User) (res *entity.User, err error) {
+	sql := `INSERT INTO users (id, username, fullname, email, password)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+			RETURNING ` + userColumns
 
-	if err := row.Scan(
-		&user.Id,
-		&user.Username,
-		&user.Fullname,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&user.DeletedAt); err != nil {
+	row := r.db.QueryRow(sql, m.Id, m.Username, m.Fullname, m.Email, m.Password)
 
-		return nil, nil // no record
+	if err := scanUser(row, m); err != nil {
+		return nil, duplicateError(err)
 	}
 
-	return &user, nil
+	return m, nil
 }
 
-func (r *userRepository) Create(ctx context.Context, m *entity.User) (res *entity.User, err error) {
-	sql := `INSERT INTO users (id, username, fullname, password)
-			VALUES ($1, $2, $3, $4)
-			RETURNING id, username, fullname, password, created_at, updated_at, deleted_at`
+func (r *userRepository) Update(ctx context.Context, m *entity.User) (res *entity.User, err error) {
+	sql := `UPDATE users SET username = $2, fullname = $3, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND deleted_at IS NULL
+			RETURNING ` + userColumns
+
+	row := r.db.QueryRow(sql, m.Id, m.Username, m.Fullname)
+
+	if err := scanUser(row, m); err != nil {
+		return nil, duplicateError(err)
+	}
+
+	return m, nil
+}
+
+// UpdatePassword stores a new password hash and rejects every access
+// token issued before tokensValidAfter
+func (r *userRepository) UpdatePassword(ctx context.Context, id uuid.UUID, password string, tokensValidAfter time.Time) error {
+	sql := `UPDATE users SET password = $2, tokens_valid_after = $3, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND deleted_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, sql, id, password, tokensValidAfter)
+	return err
+}
+
+// MarkEmailVerified flags the user's email as verified. It reports false
+// when the user's current email no longer matches the verified one.
+func (r *userRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, email string) (bool, error) {
+	sql := `UPDATE users SET email_verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND lower(email) = lower($2) AND deleted_at IS NULL`
+
+	return r.updateOne(ctx, sql, id, email)
+}
+
+// Disable blocks the user from logging in and rejects every token issued
+// before at. It reports false when there is no such user.
+func (r *userRepository) Disable(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	sql := `UPDATE users SET disabled_at = COALESCE(disabled_at, $2), tokens_valid_after = $2,
+			updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND deleted_at IS NULL`
+
+	return r.updateOne(ctx, sql, id, at)
+}
+
+// Enable lets a disabled user log in again
+func (r *userRepository) Enable(ctx context.Context, id uuid.UUID) (bool, error) {
+	sql := `UPDATE users SET disabled_at = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND deleted_at IS NULL`
+
+	return r.updateOne(ctx, sql, id)
+}
+
+// Delete soft-deletes the user and rejects every token issued before at
+func (r *userRepository) Delete(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	sql := `UPDATE users SET deleted_at = $2, tokens_valid_after = $2, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND deleted_at IS NULL`
+
+	return r.updateOne(ctx, sql, id, at)
+}
+
+// Restore undoes a soft delete. It reports false when the user isn't
+// deleted or has been anonymized.
+func (r *userRepository) Restore(ctx context.Context, id uuid.UUID) (bool, error) {
+	sql := `UPDATE users SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND deleted_at IS NOT NULL AND anonymized_at IS NULL`
 
-	row := r.db.QueryRow(sql, m.Id, m.Username, m.Fullname, m.Password)
+	restored, err := r.updateOne(ctx, sql, id)
+	return restored, duplicateError(err)
+}
+
+// Purge hard-deletes users deleted before deletedBefore, together with
+// everything that references them
+func (r *userRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE deleted_at < $1`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
 
-	if err := row.Scan(
+// anonymizeQuery erases the personal data of users deleted before $1 and
+// deletes their credentials. The CTEs are named apart from the tables
+// they delete from.
+const anonymizeQuery = `WITH anonymized AS (
+		UPDATE users SET username = 'deleted-' || id, fullname = '', email = NULL,
+			email_verified_at = NULL, password = '', anonymized_at = CURRENT_TIMESTAMP
+		WHERE deleted_at < $1 AND anonymized_at IS NULL
+		RETURNING id
+	), verifications AS (
+		DELETE FROM email_verification_tokens WHERE user_id IN (SELECT id FROM anonymized)
+	), totp AS (
+		DELETE FROM totp_credentials WHERE user_id IN (SELECT id FROM anonymized)
+	), recovery AS (
+		DELETE FROM recovery_codes WHERE user_id IN (SELECT id FROM anonymized)
+	), challenges AS (
+		DELETE FROM mfa_challenges WHERE user_id IN (SELECT id FROM anonymized)
+	), password_resets AS (
+		DELETE FROM password_reset_tokens WHERE user_id IN (SELECT id FROM anonymized)
+	), user_sessions AS (
+		DELETE FROM sessions WHERE user_id IN (SELECT id FROM anonymized)
+	), user_refresh_tokens AS (
+		DELETE FROM refresh_tokens WHERE user_id IN (SELECT id FROM anonymized)
+	), user_api_keys AS (
+		DELETE FROM api_keys WHERE user_id IN (SELECT id FROM anonymized)
+	), authorization_codes AS (
+		DELETE FROM oauth_authorization_codes WHERE user_id IN (SELECT id FROM anonymized)
+	), device_codes AS (
+		DELETE FROM oauth_device_codes WHERE user_id IN (SELECT id FROM anonymized)
+	)
+	SELECT COUNT(*) FROM anonymized`
+
+// Anonymize keeps the rows of users deleted before deletedBefore but
+// erases their personal data and credentials, including their sessions,
+// tokens and API keys
+func (r *userRepository) Anonymize(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var count int64
+	if err := r.db.QueryRowContext(ctx, anonymizeQuery, deletedBefore).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// updateOne runs an update and reports whether it changed exactly one row
+func (r *userRepository) updateOne(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// duplicateError turns a violation of the unique username or email index
+// into ErrDuplicateUsername or ErrDuplicateEmail, other errors are returned
+// as is
+func duplicateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return err
+	}
+
+	switch pqErr.Constraint {
+	case "users_username_idx":
+		return ErrDuplicateUsername
+	case "users_email_idx":
+		return ErrDuplicateEmail
+	default:
+		return err
+	}
+}
+
+// findOne returns the single user matched by query, or nil if there is none
+func (r *userRepository) findOne(ctx context.Context, query string, args ...interface{}) (*entity.User, error) {
+	row := r.db.QueryRowContext(ctx, query, args...)
+
+	user := entity.User{}
+
+	if err := scanUser(row, &user); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // no record
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner, m *entity.User) error {
+	return row.Scan(
 		&m.Id,
 		&m.Username,
 		&m.Fullname,
+		&m.Email,
+		&m.EmailVerifiedAt,
 		&m.Password,
 		&m.CreatedAt,
 		&m.UpdatedAt,
-		&m.DeletedAt); err != nil {
-		return nil, err
-	}
-
-	return m, nil
+		&m.DeletedAt,
+		&m.DisabledAt,
+		&m.TokensValidAfter)
 }
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnonymizeQuery(t *testing.T) {
+	// only users past the retention are anonymized, and only once
+	assert.Contains(t, anonymizeQuery, "WHERE deleted_at < $1 AND anonymized_at IS NULL\n\t\tRETURNING id")
+	assert.Contains(t, anonymizeQuery, "SELECT COUNT(*) FROM anonymized")
+
+	deletes := regexp.MustCompile(`(\w+) AS \(\s*DELETE FROM (\w+) WHERE ([^\n]+)`).FindAllStringSubmatch(anonymizeQuery, -1)
+
+	var tables []string
+	for _, d := range deletes {
+		name, table, where := d[1], d[2], d[3]
+		tables = append(tables, table)
+
+		// every delete is limited to the users anonymized by this query
+		assert.Equal(t, "user_id IN (SELECT id FROM anonymized)", where, table)
+		assert.NotEqual(t, table, name)
+	}
+
+	assert.ElementsMatch(t, []string{
+		"email_verification_tokens",
+		"totp_credentials",
+		"recovery_codes",
+		"mfa_challenges",
+		"password_reset_tokens",
+		"sessions",
+		"refresh_tokens",
+		"api_keys",
+		"oauth_authorization_codes",
+		"oauth_device_codes",
+	}, tables)
+}
+
+func TestDuplicateError(t *testing.T) {
+	other := errors.New("other")
+
+	assert.Equal(t, ErrDuplicateUsername, duplicateError(&pq.Error{Code: "23505", Constraint: "users_username_idx"}))
+	assert.Equal(t, ErrDuplicateEmail, duplicateError(&pq.Error{Code: "23505", Constraint: "users_email_idx"}))
+	assert.IsType(t, &pq.Error{}, duplicateError(&pq.Error{Code: "23505", Constraint: "users_pkey"}))
+	assert.Equal(t, other, duplicateError(other))
+	assert.Nil(t, duplicateError(nil))
+}
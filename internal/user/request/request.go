@@ -0,0 +1,12 @@
+package request
+
+// UpdateProfileRequest only changes the fields that are present
+type UpdateProfileRequest struct {
+	Username *string `json:"username" validate:"omitempty,min=2"`
+	Fullname *string `json:"fullname" validate:"omitempty,min=1"`
+}
+
+// DeleteAccountRequest confirms deleting the own account with the password
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
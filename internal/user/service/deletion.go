@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	authService "go-authentication-exercise/internal/auth/service"
+	"go-authentication-exercise/internal/user/entity"
+	"go-authentication-exercise/internal/util"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// the same error as for a password change, so clients see one message
+	ErrIncorrectPassword = authService.ErrIncorrectPassword
+	ErrRestoreExpired    = errors.New("the user was deleted too long ago to be restored")
+)
+
+// PurgeMode decides what happens to users once their retention is over
+type PurgeMode string
+
+const (
+	// PurgeModeDelete removes the user and everything referencing it
+	PurgeModeDelete PurgeMode = "delete"
+	// PurgeModeAnonymize keeps the row but erases personal data
+	PurgeModeAnonymize PurgeMode = "anonymize"
+)
+
+// DeletionConfig holds the settings for deleted users. They can be
+// restored during Retention and are purged after it.
+type DeletionConfig struct {
+	Retention time.Duration
+	PurgeMode PurgeMode
+}
+
+// DeletionConfigFromEnv reads USER_DELETE_RETENTION and USER_PURGE_MODE
+func DeletionConfigFromEnv() (DeletionConfig, error) {
+	config := DeletionConfig{
+		Retention: util.GetEnvDuration("USER_DELETE_RETENTION", 30*24*time.Hour),
+		PurgeMode: PurgeMode(os.Getenv("USER_PURGE_MODE")),
+	}
+
+	switch config.PurgeMode {
+	case "":
+		config.PurgeMode = PurgeModeDelete
+	case PurgeModeDelete, PurgeModeAnonymize:
+	default:
+		return config, fmt.Errorf("unsupported USER_PURGE_MODE %q", config.PurgeMode)
+	}
+
+	return config, nil
+}
+
+// DeleteAccount soft-deletes the user after checking their password and
+// signs them out everywhere
+func (s *userService) DeleteAccount(ctx context.Context, id uuid.UUID, password string) error {
+	user, err := s.repository.FindOneById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	if !entity.CheckPassword(password, user.Password) {
+		return ErrIncorrectPassword
+	}
+
+	return found(s.repository.Delete(ctx, id, tokensValidAfter()))
+}
+
+// Purge removes or anonymizes the users deleted longer than the retention
+// ago, depending on the purge mode, and returns how many were affected
+func (s *userService) Purge(ctx context.Context) (int64, error) {
+	deletedBefore := time.Now().Add(-s.deletion.Retention)
+
+	if s.deletion.PurgeMode == PurgeModeAnonymize {
+		return s.repository.Anonymize(ctx, deletedBefore)
+	}
+
+	return s.repository.Purge(ctx, deletedBefore)
+}
+
+// RunPurge purges deleted users every interval until ctx is done. A zero
+// interval disables it.
+func (s *userService) RunPurge(ctx context.Context, every time.Duration) {
+	if every <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		count, err := s.Purge(ctx)
+		if err != nil {
+			log.Printf("Failed to purge deleted users: %v", err)
+			continue
+		}
+
+		if count > 0 {
+			log.Printf("Purged %d deleted users", count)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go-authentication-exercise/internal/user/entity"
+	"go-authentication-exercise/internal/user/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeUserRepository serves a single user and records purges
+type fakeUserRepository struct {
+	repository.UserRepository
+	user       *entity.User
+	purged     bool
+	anonymized bool
+	updateErr  error
+	password   *string
+}
+
+func (r *fakeUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, password string, tokensValidAfter time.Time) error {
+	r.password = &password
+	return nil
+}
+
+func (r *fakeUserRepository) FindOneById(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	return r.user, nil
+}
+
+func (r *fakeUserRepository) Update(ctx context.Context, u *entity.User) (*entity.User, error) {
+	if r.updateErr != nil {
+		return nil, r.updateErr
+	}
+	return u, nil
+}
+
+func (r *fakeUserRepository) FindOneByIdIncludingDeleted(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	return r.user, nil
+}
+
+func (r *fakeUserRepository) FindOneByUsername(ctx context.Context, username string) (*entity.User, error) {
+	return nil, nil
+}
+
+func (r *fakeUserRepository) FindOneByEmail(ctx context.Context, email string) (*entity.User, error) {
+	return nil, nil
+}
+
+func (r *fakeUserRepository) Restore(ctx context.Context, id uuid.UUID) (bool, error) {
+	return true, nil
+}
+
+func (r *fakeUserRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	r.purged = true
+	return 1, nil
+}
+
+func (r *fakeUserRepository) Anonymize(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	r.anonymized = true
+	return 1, nil
+}
+
+func TestRestore(t *testing.T) {
+	retention := 30 * 24 * time.Hour
+
+	tests := []struct {
+		name          string
+		deletedAt     *time.Time
+		expectedError error
+	}{
+		{
+			name:          "Within the retention",
+			deletedAt:     timePtr(time.Now().Add(-time.Hour)),
+			expectedError: nil,
+		},
+		{
+			name:          "After the retention",
+			deletedAt:     timePtr(time.Now().Add(-retention - time.Hour)),
+			expectedError: ErrRestoreExpired,
+		},
+		{
+			name:          "Not deleted",
+			deletedAt:     nil,
+			expectedError: ErrUserNotDeleted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeUserRepository{user: &entity.User{Id: uuid.New(), Username: "testuser", DeletedAt: tt.deletedAt}}
+			s := NewService(repo, nil, nil, DeletionConfig{Retention: retention, PurgeMode: PurgeModeDelete})
+
+			assert.Equal(t, tt.expectedError, s.Restore(context.Background(), repo.user.Id))
+		})
+	}
+}
+
+func TestPurgeMode(t *testing.T) {
+	for _, mode := range []PurgeMode{PurgeModeDelete, PurgeModeAnonymize} {
+		t.Run(string(mode), func(t *testing.T) {
+			repo := &fakeUserRepository{}
+			s := NewService(repo, nil, nil, DeletionConfig{Retention: time.Hour, PurgeMode: mode})
+
+			count, err := s.Purge(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), count)
+			assert.Equal(t, mode == PurgeModeDelete, repo.purged)
+			assert.Equal(t, mode == PurgeModeAnonymize, repo.anonymized)
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestDeleteAccountPassword(t *testing.T) {
+	password := strings.Repeat("a", entity.MaxPasswordLength)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		password      string
+		expectedError error
+	}{
+		{name: "Wrong password", password: "wrong", expectedError: ErrIncorrectPassword},
+		// bcrypt would only compare the first 72 bytes
+		{name: "Longer than bcrypt reads", password: password + "b", expectedError: ErrIncorrectPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeUserRepository{user: &entity.User{Id: uuid.New(), Username: "testuser", Password: string(hash)}}
+			s := NewService(repo, nil, nil, DeletionConfig{})
+
+			assert.Equal(t, tt.expectedError, s.DeleteAccount(context.Background(), repo.user.Id, tt.password))
+		})
+	}
+}
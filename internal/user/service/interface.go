@@ -2,10 +2,35 @@ package service
 
 import (
 	"context"
+	"time"
+
 	"go-authentication-exercise/internal/user/entity"
+
+	"github.com/google/uuid"
 )
 
 type UserService interface {
 	List(ctx context.Context) ([]*entity.User, error)
 	Count(ctx context.Context) (int, error)
+	UpdateProfile(ctx context.Context, id uuid.UUID, username *string, fullname *string) (*entity.User, error)
+	Get(ctx context.Context, id uuid.UUID) (*entity.User, error)
+	Disable(ctx context.Context, id uuid.UUID) error
+	Enable(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	Restore(ctx context.Context, id uuid.UUID) error
+	ForcePasswordReset(ctx context.Context, id uuid.UUID) error
+	Unlock(ctx context.Context, id uuid.UUID) error
+	DeleteAccount(ctx context.Context, id uuid.UUID, password string) error
+	Purge(ctx context.Context) (int64, error)
+	RunPurge(ctx context.Context, every time.Duration)
+}
+
+// PasswordResetSender mails a password reset link to the user
+type PasswordResetSender interface {
+	SendPasswordReset(ctx context.Context, user *entity.User) error
+}
+
+// LoginUnlocker lifts a login lockout of the username
+type LoginUnlocker interface {
+	Unlock(ctx context.Context, username string) error
 }
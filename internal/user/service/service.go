@@ -2,18 +2,36 @@ package service
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"go-authentication-exercise/internal/user/entity"
 	"go-authentication-exercise/internal/user/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUserNotFound   = errors.New("user not found")
+	ErrUsernameTaken  = errors.New("username exists")
+	ErrEmailTaken     = errors.New("email is already registered")
+	ErrUserNotDeleted = errors.New("user is not deleted")
+	ErrNoEmail        = errors.New("user has no email address")
 )
 
 type userService struct {
 	repository repository.UserRepository
+	resets     PasswordResetSender
+	unlocker   LoginUnlocker
+	deletion   DeletionConfig
 }
 
-func NewService(repo repository.UserRepository) UserService {
+func NewService(repo repository.UserRepository, resets PasswordResetSender, unlocker LoginUnlocker, deletion DeletionConfig) UserService {
 	return &userService{
 		repository: repo,
+		resets:     resets,
+		unlocker:   unlocker,
+		deletion:   deletion,
 	}
 }
 
@@ -34,3 +52,183 @@ func (s *userService) Count(ctx context.Context) (int, error) {
 
 	return count, nil
 }
+
+// UpdateProfile changes the given fields of the user, nil fields are kept
+func (s *userService) UpdateProfile(ctx context.Context, id uuid.UUID, username *string, fullname *string) (*entity.User, error) {
+	user, err := s.repository.FindOneById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	if username != nil && *username != user.Username {
+		existing, err := s.repository.FindOneByUsername(ctx, *username)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing != nil {
+			return nil, ErrUsernameTaken
+		}
+
+		user.Username = *username
+	}
+
+	if fullname != nil {
+		user.Fullname = *fullname
+	}
+
+	// another user may have taken the username since the check above
+	updated, err := s.repository.Update(ctx, user)
+	if errors.Is(err, repository.ErrDuplicateUsername) {
+		return nil, ErrUsernameTaken
+	}
+
+	return updated, err
+}
+
+// Get returns the user, soft-deleted users included
+func (s *userService) Get(ctx context.Context, id uuid.UUID) (*entity.User, error) {
+	user, err := s.repository.FindOneByIdIncludingDeleted(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// Disable blocks the user from logging in and signs them out everywhere
+func (s *userService) Disable(ctx context.Context, id uuid.UUID) error {
+	return found(s.repository.Disable(ctx, id, tokensValidAfter()))
+}
+
+// Enable lets a disabled user log in again
+func (s *userService) Enable(ctx context.Context, id uuid.UUID) error {
+	return found(s.repository.Enable(ctx, id))
+}
+
+// Delete soft-deletes the user and signs them out everywhere
+func (s *userService) Delete(ctx context.Context, id uuid.UUID) error {
+	return found(s.repository.Delete(ctx, id, tokensValidAfter()))
+}
+
+// Restore undoes a soft delete within the retention, unless the username
+// or email has been taken by another user in the meantime
+func (s *userService) Restore(ctx context.Context, id uuid.UUID) error {
+	user, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if user.DeletedAt == nil {
+		return ErrUserNotDeleted
+	}
+
+	if time.Since(*user.DeletedAt) > s.deletion.Retention {
+		return ErrRestoreExpired
+	}
+
+	existing, err := s.repository.FindOneByUsername(ctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		return ErrUsernameTaken
+	}
+
+	if user.Email != "" {
+		existing, err := s.repository.FindOneByEmail(ctx, user.Email)
+		if err != nil {
+			return err
+		}
+
+		if existing != nil {
+			return ErrEmailTaken
+		}
+	}
+
+	restored, err := s.repository.Restore(ctx, id)
+	switch {
+	case errors.Is(err, repository.ErrDuplicateUsername):
+		return ErrUsernameTaken
+	case errors.Is(err, repository.ErrDuplicateEmail):
+		return ErrEmailTaken
+	case err != nil:
+		return err
+	}
+
+	// purged by now
+	if !restored {
+		return ErrRestoreExpired
+	}
+
+	return nil
+}
+
+// ForcePasswordReset mails the user a reset link, then clears the password
+// and signs them out everywhere. Users without an email, or whose mail
+// can't be delivered, are left unchanged, as they would be locked out.
+func (s *userService) ForcePasswordReset(ctx context.Context, id uuid.UUID) error {
+	user, err := s.repository.FindOneById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	if user.Email == "" {
+		return ErrNoEmail
+	}
+
+	if err := s.resets.SendPasswordReset(ctx, user); err != nil {
+		return err
+	}
+
+	// an empty hash matches no password
+	return s.repository.UpdatePassword(ctx, id, "", tokensValidAfter())
+}
+
+// Unlock lifts a login lockout of the user, along with the backoff of its
+// failed logins
+func (s *userService) Unlock(ctx context.Context, id uuid.UUID) error {
+	user, err := s.repository.FindOneById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	return s.unlocker.Unlock(ctx, user.Username)
+}
+
+// tokensValidAfter returns the time from which tokens stay valid after a
+// sign out. Tokens only carry second precision, so anything issued from
+// this second onwards remains valid.
+func tokensValidAfter() time.Time {
+	return time.Now().Truncate(time.Second)
+}
+
+// found turns a missed update into ErrUserNotFound
+func found(updated bool, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if !updated {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
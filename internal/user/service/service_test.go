@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-authentication-exercise/internal/user/entity"
+	"go-authentication-exercise/internal/user/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateProfileUsernameTaken(t *testing.T) {
+	// the username is free when checked but taken by the time it is stored
+	repo := &fakeUserRepository{
+		user:      &entity.User{Id: uuid.New(), Username: "testuser"},
+		updateErr: repository.ErrDuplicateUsername,
+	}
+	s := NewService(repo, nil, nil, DeletionConfig{})
+
+	username := "Other"
+	_, err := s.UpdateProfile(context.Background(), repo.user.Id, &username, nil)
+	assert.ErrorIs(t, err, ErrUsernameTaken)
+}
+
+// fakeResetSender records the users mailed a reset link
+type fakeResetSender struct {
+	sent []*entity.User
+	err  error
+}
+
+func (r *fakeResetSender) SendPasswordReset(ctx context.Context, user *entity.User) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.sent = append(r.sent, user)
+	return nil
+}
+
+func TestForcePasswordReset(t *testing.T) {
+	deliveryErr := errors.New("mail server unavailable")
+
+	tests := []struct {
+		name          string
+		email         string
+		sendErr       error
+		expectedError error
+	}{
+		{name: "User with email", email: "testuser@example.com"},
+		{name: "User without email", expectedError: ErrNoEmail},
+		{name: "Mail not delivered", email: "testuser@example.com", sendErr: deliveryErr, expectedError: deliveryErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeUserRepository{user: &entity.User{Id: uuid.New(), Username: "testuser", Email: tt.email}}
+			resets := &fakeResetSender{err: tt.sendErr}
+			s := NewService(repo, resets, nil, DeletionConfig{})
+
+			err := s.ForcePasswordReset(context.Background(), repo.user.Id)
+			assert.Equal(t, tt.expectedError, err)
+
+			// the password is only cleared once the link is on its way
+			if tt.expectedError == nil {
+				assert.Len(t, resets.sent, 1)
+				assert.Equal(t, "", *repo.password)
+			} else {
+				assert.Empty(t, resets.sent)
+				assert.Nil(t, repo.password)
+			}
+		})
+	}
+}
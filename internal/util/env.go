@@ -0,0 +1,29 @@
+package util
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetEnvDuration parses the environment variable as a time.Duration,
+// returning fallback when it is unset or invalid
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
+// GetEnvInt parses the environment variable as an int, returning fallback
+// when it is unset or invalid
+func GetEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
@@ -0,0 +1,30 @@
+package util
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ClientIP returns the IP address of the client. Behind a reverse proxy set
+// TRUST_PROXY_HEADERS, then the last address in X-Forwarded-For is used,
+// which is the one the proxy itself appended.
+func ClientIP(r *http.Request) string {
+	if trust, _ := strconv.ParseBool(os.Getenv("TRUST_PROXY_HEADERS")); trust {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			parts := strings.Split(forwarded, ",")
+			if ip := strings.TrimSpace(parts[len(parts)-1]); net.ParseIP(ip) != nil {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -34,3 +34,17 @@ func Error(w http.ResponseWriter, code int, data interface{}, message string) {
 	w.WriteHeader(code)
 	w.Write(response)
 }
+
+// JSON writes data as the response body without the data/message envelope,
+// for endpoints whose format is defined by a specification
+func JSON(w http.ResponseWriter, code int, data interface{}) {
+	response, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	w.Write(response)
+}
@@ -1,18 +1,30 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
+	APIKeyHandler "go-authentication-exercise/internal/apikey/handler"
+	APIKeyRepository "go-authentication-exercise/internal/apikey/repository"
+	APIKeyService "go-authentication-exercise/internal/apikey/service"
 	AuthHandler "go-authentication-exercise/internal/auth/handler"
+	AuthRepository "go-authentication-exercise/internal/auth/repository"
 	AuthService "go-authentication-exercise/internal/auth/service"
+	"go-authentication-exercise/internal/auth/token"
+	"go-authentication-exercise/internal/mailer"
 	"go-authentication-exercise/internal/middleware"
+	RoleHandler "go-authentication-exercise/internal/role/handler"
+	RoleRepository "go-authentication-exercise/internal/role/repository"
+	RoleService "go-authentication-exercise/internal/role/service"
 	UserHandler "go-authentication-exercise/internal/user/handler"
 	UserRepository "go-authentication-exercise/internal/user/repository"
 	UserService "go-authentication-exercise/internal/user/service"
+	"go-authentication-exercise/internal/util"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
@@ -33,15 +45,165 @@ func main() {
 	defer db.Close()
 	log.Printf("Database initialized.")
 
+	// Load the JWT signing keys, the configured key seeds an empty keyring
+	// and is rotated to when it changes
+	signingKey, err := token.LoadKeyFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load signing key: %v", err)
+	}
+
+	keyCipher, err := token.KeyCipherFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize signing key encryption: %v", err)
+	}
+
+	tokenConfig := token.ConfigFromEnv()
+	keyring := token.NewKeyring(signingKey)
+	keyService := AuthService.NewKeyService(
+		AuthRepository.NewSigningKeyRepository(db),
+		keyring,
+		keyCipher,
+		signingKey.Method.Alg(),
+		util.GetEnvDuration("JWT_KEY_OVERLAP", tokenConfig.AccessTokenTTL+tokenConfig.Leeway),
+		util.GetEnvDuration("JWT_KEY_RELOAD_INTERVAL", time.Minute))
+	if err := keyService.Load(context.Background()); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+	adopted, err := keyService.Adopt(context.Background(), signingKey)
+	if err != nil {
+		log.Fatalf("Failed to adopt the configured signing key: %v", err)
+	}
+	if adopted {
+		log.Printf("Rotated to the configured signing key %s, it starts signing once every instance has reloaded it.", signingKey.Id)
+	}
+	if current := keyring.Current().Id; current == signingKey.Id {
+		log.Printf("Signing with the configured key %s.", current)
+	} else {
+		log.Printf("Signing with key %s from the database.", current)
+	}
+
+	// `rotate-keys` replaces the signing key and exits
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		key, err := keyService.Rotate(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to rotate signing key: %v", err)
+		}
+		log.Printf("Rotated signing key, new key id %s", key.Id)
+		return
+	}
+
+	go keyService.Run(context.Background(), util.GetEnvDuration("JWT_KEY_ROTATION_INTERVAL", 0))
+
+	mail, err := mailer.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
 	//  repo
-	userRepository := UserRepository.NewRepository(db)
-	userService := UserService.NewService(userRepository)
+	userRepository := UserRepository.NewCachedRepository(UserRepository.NewRepository(db), 30*time.Second)
+	roleRepository := RoleRepository.NewRepository(db)
+	roleService := RoleService.NewService(roleRepository, userRepository)
+	roleHandler := RoleHandler.NewRoleHandler(roleService)
+	apiKeyService := APIKeyService.NewService(APIKeyRepository.NewRepository(db))
+	apiKeyHandler := APIKeyHandler.NewAPIKeyHandler(apiKeyService)
+	refreshTokenRepository := AuthRepository.NewRefreshTokenRepository(db)
+	sessionRepository := AuthRepository.NewSessionRepository(db)
+	oauthClientRepository := AuthRepository.NewOAuthClientRepository(db)
+	revokedTokenRepository := AuthRepository.NewCachedRevokedTokenRepository(
+		AuthRepository.NewRevokedTokenRepository(db), 30*time.Second)
+	passwordResetTokenRepository := AuthRepository.NewPasswordResetTokenRepository(db)
+	emailVerificationTokenRepository := AuthRepository.NewEmailVerificationTokenRepository(db)
+	totpCredentialRepository := AuthRepository.NewTOTPCredentialRepository(db)
+	recoveryCodeRepository := AuthRepository.NewRecoveryCodeRepository(db)
+	mfaChallengeRepository := AuthRepository.NewMFAChallengeRepository(db)
+	loginThrottle := AuthService.NewLoginThrottle(newLoginAttemptRepository(db), AuthService.ThrottleConfigFromEnv())
+
+	// `unlock-user <username>` lifts a login lockout and exits
+	if len(os.Args) > 2 && os.Args[1] == "unlock-user" {
+		if err := loginThrottle.Unlock(context.Background(), os.Args[2]); err != nil {
+			log.Fatalf("Failed to unlock user: %v", err)
+		}
+		log.Printf("Unlocked user %s", os.Args[2])
+		return
+	}
+
+	// `grant-role <username> <role>` assigns a role, e.g. the first admin,
+	// and exits
+	if len(os.Args) > 3 && os.Args[1] == "grant-role" {
+		if err := grantRole(context.Background(), userRepository, roleService, os.Args[2], os.Args[3]); err != nil {
+			log.Fatalf("Failed to grant role: %v", err)
+		}
+		log.Printf("Granted role %s to user %s", os.Args[3], os.Args[2])
+		return
+	}
+
+	authService := AuthService.NewService(
+		userRepository,
+		roleRepository,
+		refreshTokenRepository,
+		sessionRepository,
+		revokedTokenRepository,
+		passwordResetTokenRepository,
+		emailVerificationTokenRepository,
+		totpCredentialRepository,
+		recoveryCodeRepository,
+		mfaChallengeRepository,
+		loginThrottle,
+		AuthService.SignupModeFromEnv(),
+		keyring,
+		tokenConfig,
+		mail,
+		AuthService.PasswordResetConfigFromEnv(),
+		AuthService.EmailVerificationConfigFromEnv())
+	cookieConfig, err := middleware.CookieConfigFromEnv(tokenConfig)
+	if err != nil {
+		log.Fatalf("Invalid cookie settings: %v", err)
+	}
+	authHandler := AuthHandler.NewAuthHandler(authService, cookieConfig)
+	oauthService := AuthService.NewOAuthService(
+		authService,
+		userRepository,
+		oauthClientRepository,
+		AuthRepository.NewAuthorizationCodeRepository(db),
+		AuthRepository.NewDeviceCodeRepository(db),
+		refreshTokenRepository,
+		sessionRepository,
+		revokedTokenRepository,
+		apiKeyService,
+		newRateLimiter("RATE_LIMIT_INTROSPECT", "6000/1m", middleware.KeyByIP),
+		keyring,
+		tokenConfig,
+		AuthService.OAuthConfigFromEnv(tokenConfig))
+	oauthHandler := AuthHandler.NewOAuthHandler(oauthService, authService)
+	deletionConfig, err := UserService.DeletionConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid user deletion settings: %v", err)
+	}
+	userService := UserService.NewService(userRepository, authService, loginThrottle, deletionConfig)
 	userHandler := UserHandler.NewUserHandler(userService)
-	authService := AuthService.NewService(userRepository)
-	authHandler := AuthHandler.NewAuthHandler(authService)
+	// `purge-users` purges the users deleted longer than the retention ago
+	// and exits
+	if len(os.Args) > 1 && os.Args[1] == "purge-users" {
+		count, err := userService.Purge(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to purge deleted users: %v", err)
+		}
+		log.Printf("Purged %d deleted users", count)
+		return
+	}
+
+	go userService.RunPurge(context.Background(), util.GetEnvDuration("USER_PURGE_INTERVAL", time.Hour))
+
+	authMiddleware := middleware.NewAuthMiddleware(keyring, tokenConfig, revokedTokenRepository, sessionRepository, oauthClientRepository, userRepository, roleRepository, apiKeyService, cookieConfig)
+	rateLimits := rateLimits{
+		auth:   newRateLimiter("RATE_LIMIT_AUTH", "20/1m", middleware.KeyByIP),
+		signup: newRateLimiter("RATE_LIMIT_SIGNUP", "5/1h", middleware.KeyByIP),
+		user:   newRateLimiter("RATE_LIMIT_USER", "120/1m", middleware.KeyByAPIKey),
+		ip:     newRateLimiter("RATE_LIMIT_IP", "600/1m", middleware.KeyByIP),
+	}
 
 	// Setup router and routes
-	r := setupRouter(userHandler, authHandler)
+	r := setupRouter(userHandler, authHandler, oauthHandler, roleHandler, apiKeyHandler, authMiddleware, rateLimits)
 
 	// Start the server
 	port := os.Getenv("APP_PORT")
@@ -61,6 +223,62 @@ func rootEndpoint(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "go-authentication-exercise v%s", version)
 }
 
+// grantRole assigns the role to the user with the given username
+func grantRole(ctx context.Context, users UserRepository.UserRepository, roles RoleService.RoleService, username string, role string) error {
+	user, err := users.FindOneByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return RoleService.ErrUserNotFound
+	}
+
+	return roles.Assign(ctx, user.Id, role)
+}
+
+// newLoginAttemptRepository returns the failed login store selected by
+// LOGIN_ATTEMPT_STORE, `postgres` (default) or `memory`
+func newLoginAttemptRepository(db *sql.DB) AuthRepository.LoginAttemptRepository {
+	switch store := os.Getenv("LOGIN_ATTEMPT_STORE"); store {
+	case "", "postgres":
+		return AuthRepository.NewLoginAttemptRepository(db)
+	case "memory":
+		return AuthRepository.NewMemoryLoginAttemptRepository()
+	default:
+		log.Fatalf("Unsupported LOGIN_ATTEMPT_STORE %q", store)
+		return nil
+	}
+}
+
+// rateLimits are the rate limiters of the route groups
+type rateLimits struct {
+	auth   middleware.RateLimiter
+	signup middleware.RateLimiter
+	user   middleware.RateLimiter
+	ip     middleware.RateLimiter
+}
+
+// newRateLimiter reads a limit like `20/1m` from the environment variable,
+// `off` disables it
+func newRateLimiter(key string, fallback string, keyFunc middleware.RateLimitKeyFunc) middleware.RateLimiter {
+	value := os.Getenv(key)
+	if value == "" {
+		value = fallback
+	}
+
+	if value == "off" {
+		return middleware.NewRateLimiter(0, 0, keyFunc)
+	}
+
+	limit, period, err := middleware.ParseRateLimit(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+
+	return middleware.NewRateLimiter(limit, period, keyFunc)
+}
+
 // initDB initializes the database connection
 func initDB() (*sql.DB, error) {
 	connStr := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable",
@@ -83,19 +301,119 @@ func initDB() (*sql.DB, error) {
 }
 
 // setupRouter configures all the routes for the application
-func setupRouter(userHandler UserHandler.UserHandler, authHandler AuthHandler.AuthHandler) *mux.Router {
+func setupRouter(
+	userHandler UserHandler.UserHandler,
+	authHandler AuthHandler.AuthHandler,
+	oauthHandler AuthHandler.OAuthHandler,
+	roleHandler RoleHandler.RoleHandler,
+	apiKeyHandler APIKeyHandler.APIKeyHandler,
+	authMiddleware middleware.AuthMiddleware,
+	rateLimits rateLimits,
+) *mux.Router {
 	r := mux.NewRouter()
 	r.HandleFunc("/", rootEndpoint)
+	r.HandleFunc("/.well-known/jwks.json", authHandler.JWKS).Methods("GET")
+	r.HandleFunc("/.well-known/openid-configuration", oauthHandler.OpenIDConfiguration).Methods("GET")
+
+	// endpoints about the signed in user, OAuth client principals have none
+	authenticatedUser := func(next http.Handler) http.Handler {
+		return authMiddleware.Authenticated(middleware.RequireUser(next))
+	}
+
+	// endpoints managing the account, closed to tokens of OAuth clients
+	accountUser := func(next http.Handler) http.Handler {
+		return authenticatedUser(middleware.RequireFirstParty(next))
+	}
 
 	// auth endpoints
 	authRoutes := r.PathPrefix("/auth").Subrouter()
+	authRoutes.Use(rateLimits.auth.Limit)
 	authRoutes.HandleFunc("/login", authHandler.Login).Methods("POST")
-	authRoutes.HandleFunc("/signup", authHandler.Signup).Methods("POST")
+	authRoutes.HandleFunc("/login/mfa", authHandler.LoginMFA).Methods("POST")
+	authRoutes.Handle("/signup", rateLimits.signup.Limit(http.HandlerFunc(authHandler.Signup))).Methods("POST")
+	authRoutes.HandleFunc("/refresh", authHandler.Refresh).Methods("POST")
+	authRoutes.Handle("/logout", authenticatedUser(http.HandlerFunc(authHandler.Logout))).Methods("POST")
+	authRoutes.Handle("/password", accountUser(http.HandlerFunc(authHandler.ChangePassword))).Methods("POST")
+	authRoutes.HandleFunc("/password/forgot", authHandler.ForgotPassword).Methods("POST")
+	authRoutes.HandleFunc("/password/reset", authHandler.ResetPassword).Methods("POST")
+	authRoutes.HandleFunc("/verify-email", authHandler.VerifyEmail).Methods("POST")
+	authRoutes.HandleFunc("/verify-email/resend", authHandler.ResendVerification).Methods("POST")
+	authRoutes.Handle("/mfa/totp/setup", accountUser(http.HandlerFunc(authHandler.SetupTOTP))).Methods("POST")
+	authRoutes.Handle("/mfa/totp/verify", accountUser(http.HandlerFunc(authHandler.ConfirmTOTP))).Methods("POST")
+	authRoutes.Handle("/mfa/totp/disable", accountUser(http.HandlerFunc(authHandler.DisableTOTP))).Methods("POST")
+
+	// token introspection is limited per IP here, and per client by the
+	// OAuth service once the client is authenticated, gateways call it for
+	// every request they pass on
+	r.Handle("/oauth/introspect", rateLimits.ip.Limit(http.HandlerFunc(oauthHandler.Introspect))).Methods("POST")
+
+	// OAuth authorization server
+	oauthRoutes := r.PathPrefix("/oauth").Subrouter()
+	oauthRoutes.Use(rateLimits.auth.Limit)
+	oauthRoutes.HandleFunc("/authorize", oauthHandler.Authorize).Methods("GET")
+	oauthRoutes.HandleFunc("/authorize", oauthHandler.Approve).Methods("POST")
+	oauthRoutes.HandleFunc("/token", oauthHandler.Token).Methods("POST")
+	oauthRoutes.HandleFunc("/device/code", oauthHandler.DeviceAuthorization).Methods("POST")
+	oauthRoutes.HandleFunc("/revoke", oauthHandler.Revoke).Methods("POST")
+
+	// device verification page, users signed in with cookies aren't asked
+	// for their credentials again
+	r.Handle("/device", rateLimits.auth.Limit(authMiddleware.CookieSession(http.HandlerFunc(oauthHandler.Device)))).Methods("GET")
+	r.Handle("/device", rateLimits.auth.Limit(authMiddleware.CookieSession(http.HandlerFunc(oauthHandler.DecideDevice)))).Methods("POST")
+
+	// OpenID Connect userinfo, limited like the user endpoints
+	userInfo := rateLimits.ip.Limit(authenticatedUser(rateLimits.user.Limit(http.HandlerFunc(oauthHandler.UserInfo))))
+	r.Handle("/userinfo", userInfo).Methods("GET", "POST")
 
 	// user endpoints
 	userRoutes := r.PathPrefix("/user").Subrouter()
-	userRoutes.Use(middleware.Authenticated)
-	userRoutes.HandleFunc("/list", userHandler.List).Methods("GET")
+	// limited per IP before authentication too, so requests with invalid
+	// credentials count
+	userRoutes.Use(rateLimits.ip.Limit)
+	userRoutes.Use(authMiddleware.Authenticated)
+	userRoutes.Use(rateLimits.user.Limit)
+	userRoutes.Handle("/list", middleware.RequirePermission("users:read")(http.HandlerFunc(userHandler.List))).Methods("GET")
+
+	// the rest is about the user itself, and closed to tokens of OAuth
+	// clients
+	ownRoutes := userRoutes.NewRoute().Subrouter()
+	ownRoutes.Use(middleware.RequireUser)
+	ownRoutes.Use(middleware.RequireFirstParty)
+	ownRoutes.HandleFunc("/me", userHandler.Me).Methods("GET")
+	ownRoutes.HandleFunc("/me", userHandler.UpdateMe).Methods("PATCH")
+	ownRoutes.HandleFunc("/me", userHandler.DeleteMe).Methods("DELETE")
+	ownRoutes.HandleFunc("/me/sessions", authHandler.ListSessions).Methods("GET")
+	ownRoutes.HandleFunc("/me/sessions/{id}", authHandler.RevokeSession).Methods("DELETE")
+	ownRoutes.HandleFunc("/api-keys", apiKeyHandler.List).Methods("GET")
+	ownRoutes.HandleFunc("/api-keys", apiKeyHandler.Create).Methods("POST")
+	ownRoutes.HandleFunc("/api-keys/{id}", apiKeyHandler.Revoke).Methods("DELETE")
+
+	// admin endpoints, each gated by a permission
+	readUsers := middleware.RequirePermission("users:read")
+	writeUsers := middleware.RequirePermission("users:write")
+	readRoles := middleware.RequirePermission("roles:read")
+	writeRoles := middleware.RequirePermission("roles:write")
+	readClients := middleware.RequirePermission("clients:read")
+	writeClients := middleware.RequirePermission("clients:write")
+	adminRoutes := r.PathPrefix("/admin").Subrouter()
+	adminRoutes.Use(rateLimits.ip.Limit)
+	adminRoutes.Use(authMiddleware.Authenticated)
+	adminRoutes.Use(rateLimits.user.Limit)
+	adminRoutes.Handle("/users/{id}", readUsers(http.HandlerFunc(userHandler.Get))).Methods("GET")
+	adminRoutes.Handle("/users/{id}", writeUsers(http.HandlerFunc(userHandler.Update))).Methods("PATCH")
+	adminRoutes.Handle("/users/{id}", writeUsers(http.HandlerFunc(userHandler.Delete))).Methods("DELETE")
+	adminRoutes.Handle("/users/{id}/disable", writeUsers(http.HandlerFunc(userHandler.Disable))).Methods("POST")
+	adminRoutes.Handle("/users/{id}/enable", writeUsers(http.HandlerFunc(userHandler.Enable))).Methods("POST")
+	adminRoutes.Handle("/users/{id}/restore", writeUsers(http.HandlerFunc(userHandler.Restore))).Methods("POST")
+	adminRoutes.Handle("/users/{id}/password-reset", writeUsers(http.HandlerFunc(userHandler.ForcePasswordReset))).Methods("POST")
+	adminRoutes.Handle("/users/{id}/unlock", writeUsers(http.HandlerFunc(userHandler.Unlock))).Methods("POST")
+	adminRoutes.Handle("/roles", readRoles(http.HandlerFunc(roleHandler.List))).Methods("GET")
+	adminRoutes.Handle("/users/{id}/roles", readRoles(http.HandlerFunc(roleHandler.UserRoles))).Methods("GET")
+	adminRoutes.Handle("/users/{id}/roles", writeRoles(http.HandlerFunc(roleHandler.Assign))).Methods("POST")
+	adminRoutes.Handle("/users/{id}/roles/{role}", writeRoles(http.HandlerFunc(roleHandler.Revoke))).Methods("DELETE")
+	adminRoutes.Handle("/oauth/clients", readClients(http.HandlerFunc(oauthHandler.ListClients))).Methods("GET")
+	adminRoutes.Handle("/oauth/clients", writeClients(http.HandlerFunc(oauthHandler.CreateClient))).Methods("POST")
+	adminRoutes.Handle("/oauth/clients/{id}", writeClients(http.HandlerFunc(oauthHandler.DeleteClient))).Methods("DELETE")
 
 	return r
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	APIKeyHandler "go-authentication-exercise/internal/apikey/handler"
+	AuthHandler "go-authentication-exercise/internal/auth/handler"
+	"go-authentication-exercise/internal/middleware"
+	RoleHandler "go-authentication-exercise/internal/role/handler"
+	"go-authentication-exercise/internal/user/entity"
+	UserHandler "go-authentication-exercise/internal/user/handler"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// delegatedAuth authenticates every request with a token issued to an OAuth
+// client on behalf of the user
+type delegatedAuth struct{}
+
+func (delegatedAuth) Authenticated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := &entity.User{Id: uuid.New(), Username: "johndoe"}
+		ctx := middleware.WithClaims(r.Context(), jwt.MapClaims{"sub": user.Id.String(), "client_id": "webapp", "scope": "openid"})
+		next.ServeHTTP(w, r.WithContext(middleware.WithUser(ctx, user)))
+	})
+}
+
+func (delegatedAuth) CookieSession(next http.Handler) http.Handler {
+	return next
+}
+
+func TestAccountRoutesRefuseOAuthClientTokens(t *testing.T) {
+	off := middleware.NewRateLimiter(0, 0, middleware.KeyByIP)
+	r := setupRouter(
+		UserHandler.NewUserHandler(nil),
+		AuthHandler.NewAuthHandler(nil, middleware.CookieConfig{}),
+		AuthHandler.NewOAuthHandler(nil, nil),
+		RoleHandler.NewRoleHandler(nil),
+		APIKeyHandler.NewAPIKeyHandler(nil),
+		delegatedAuth{},
+		rateLimits{auth: off, signup: off, user: off, ip: off},
+	)
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{"POST", "/user/api-keys"},
+		{"POST", "/auth/password"},
+		{"PATCH", "/user/me"},
+		{"DELETE", "/user/me"},
+		{"GET", "/user/me/sessions"},
+		{"POST", "/auth/mfa/totp/setup"},
+	}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			r.ServeHTTP(res, httptest.NewRequest(route.method, route.path, nil))
+
+			assert.Equal(t, http.StatusForbidden, res.Code)
+		})
+	}
+}
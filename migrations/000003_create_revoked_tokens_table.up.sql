@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS "revoked_tokens" (
+  "jti" text NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY("jti")
+);
+
+CREATE INDEX IF NOT EXISTS "revoked_tokens_expires_at_idx" ON "revoked_tokens" ("expires_at");
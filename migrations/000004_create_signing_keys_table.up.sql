@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS "signing_keys" (
+  "id" text NOT NULL,
+  "algorithm" text NOT NULL,
+  "key_material" text NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "retired_at" timestamptz NULL,
+  "expires_at" timestamptz NULL,
+    PRIMARY KEY("id")
+);
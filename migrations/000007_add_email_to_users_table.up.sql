@@ -0,0 +1,18 @@
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "email" text NULL;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "email_verified_at" timestamptz NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS "users_email_idx" ON "users" (lower("email")) WHERE "deleted_at" IS NULL;
+
+CREATE TABLE IF NOT EXISTS "email_verification_tokens" (
+  "id" uuid NOT NULL,
+  "user_id" uuid NOT NULL REFERENCES "users" ("id"),
+  "email" text NOT NULL,
+  "token_hash" text NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "used_at" timestamptz NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "email_verification_tokens_token_hash_idx" ON "email_verification_tokens" ("token_hash");
+CREATE INDEX IF NOT EXISTS "email_verification_tokens_user_id_idx" ON "email_verification_tokens" ("user_id");
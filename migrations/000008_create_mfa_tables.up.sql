@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS "totp_credentials" (
+  "user_id" uuid NOT NULL REFERENCES "users" ("id"),
+  "secret" text NOT NULL,
+  "confirmed_at" timestamptz NULL,
+  "last_used_step" bigint NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY("user_id")
+);
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+  "id" uuid NOT NULL,
+  "user_id" uuid NOT NULL REFERENCES "users" ("id"),
+  "code_hash" text NOT NULL,
+  "used_at" timestamptz NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "recovery_codes_user_id_code_hash_idx" ON "recovery_codes" ("user_id", "code_hash");
+
+CREATE TABLE IF NOT EXISTS "mfa_challenges" (
+  "id" uuid NOT NULL,
+  "user_id" uuid NOT NULL REFERENCES "users" ("id"),
+  "token_hash" text NOT NULL,
+  "attempts" integer NOT NULL DEFAULT 0,
+  "expires_at" timestamptz NOT NULL,
+  "used_at" timestamptz NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "mfa_challenges_token_hash_idx" ON "mfa_challenges" ("token_hash");
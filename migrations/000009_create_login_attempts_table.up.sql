@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS "login_attempts" (
+  "key" text NOT NULL,
+  "failures" integer NOT NULL DEFAULT 0,
+  "last_failure_at" timestamptz NOT NULL,
+  "blocked_until" timestamptz NULL,
+    PRIMARY KEY("key")
+);
+
+CREATE INDEX IF NOT EXISTS "login_attempts_last_failure_at_idx" ON "login_attempts" ("last_failure_at");
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS "roles" (
+  "name" text NOT NULL,
+  "description" text NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY("name")
+);
+
+CREATE TABLE IF NOT EXISTS "permissions" (
+  "name" text NOT NULL,
+  "description" text NOT NULL DEFAULT '',
+    PRIMARY KEY("name")
+);
+
+CREATE TABLE IF NOT EXISTS "role_permissions" (
+  "role" text NOT NULL REFERENCES "roles" ("name") ON DELETE CASCADE,
+  "permission" text NOT NULL REFERENCES "permissions" ("name") ON DELETE CASCADE,
+    PRIMARY KEY("role", "permission")
+);
+
+CREATE TABLE IF NOT EXISTS "user_roles" (
+  "user_id" uuid NOT NULL REFERENCES "users" ("id"),
+  "role" text NOT NULL REFERENCES "roles" ("name") ON DELETE CASCADE,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY("user_id", "role")
+);
+
+INSERT INTO "permissions" ("name", "description") VALUES
+  ('users:read', 'List and view users'),
+  ('users:write', 'Change and delete users'),
+  ('roles:read', 'View roles and role assignments'),
+  ('roles:write', 'Assign and revoke roles')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "roles" ("name", "description") VALUES
+  ('admin', 'Full access to user and role management')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "role_permissions" ("role", "permission")
+SELECT 'admin', "name" FROM "permissions"
+ON CONFLICT DO NOTHING;
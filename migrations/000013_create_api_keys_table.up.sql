@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS "api_keys" (
+  "id" uuid NOT NULL,
+  "user_id" uuid NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+  "name" text NOT NULL,
+  "prefix" text NOT NULL,
+  "token_hash" text NOT NULL,
+  "scopes" text[] NOT NULL DEFAULT '{}',
+  "expires_at" timestamptz NULL,
+  "last_used_at" timestamptz NULL,
+  "revoked_at" timestamptz NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "api_keys_token_hash_idx" ON "api_keys" ("token_hash");
+CREATE INDEX IF NOT EXISTS "api_keys_user_id_idx" ON "api_keys" ("user_id");
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "sessions" (
+  "id" uuid NOT NULL,
+  "user_id" uuid NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+  "user_agent" text NOT NULL DEFAULT '',
+  "ip" text NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "last_seen_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "revoked_at" timestamptz NULL,
+    PRIMARY KEY("id")
+);
+
+CREATE INDEX IF NOT EXISTS "sessions_user_id_idx" ON "sessions" ("user_id");
@@ -0,0 +1,8 @@
+DELETE FROM "permissions" WHERE "name" IN ('clients:read', 'clients:write');
+
+ALTER TABLE "sessions"
+  DROP COLUMN IF EXISTS "scopes",
+  DROP COLUMN IF EXISTS "client_id";
+
+DROP TABLE IF EXISTS "oauth_authorization_codes";
+DROP TABLE IF EXISTS "oauth_clients";
@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS "oauth_clients" (
+  "id" text NOT NULL,
+  "name" text NOT NULL,
+  "redirect_uris" text[] NOT NULL DEFAULT '{}',
+  "scopes" text[] NOT NULL DEFAULT '{}',
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY("id")
+);
+
+CREATE TABLE IF NOT EXISTS "oauth_authorization_codes" (
+  "id" uuid NOT NULL,
+  "client_id" text NOT NULL REFERENCES "oauth_clients" ("id") ON DELETE CASCADE,
+  "user_id" uuid NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+  "code_hash" text NOT NULL,
+  "redirect_uri" text NOT NULL,
+  "scopes" text[] NOT NULL DEFAULT '{}',
+  "code_challenge" text NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "used_at" timestamptz NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "oauth_authorization_codes_code_hash_idx" ON "oauth_authorization_codes" ("code_hash");
+
+-- sessions started by an OAuth client are limited to the granted scopes
+ALTER TABLE "sessions"
+  ADD COLUMN IF NOT EXISTS "client_id" text NULL REFERENCES "oauth_clients" ("id") ON DELETE CASCADE,
+  ADD COLUMN IF NOT EXISTS "scopes" text[] NOT NULL DEFAULT '{}';
+
+INSERT INTO "permissions" ("name", "description") VALUES
+  ('clients:read', 'View OAuth clients'),
+  ('clients:write', 'Register and delete OAuth clients')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "role_permissions" ("role", "permission") VALUES
+  ('admin', 'clients:read'),
+  ('admin', 'clients:write')
+ON CONFLICT DO NOTHING;
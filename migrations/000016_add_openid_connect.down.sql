@@ -0,0 +1,5 @@
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS "auth_time";
+
+ALTER TABLE "oauth_authorization_codes"
+  DROP COLUMN IF EXISTS "auth_time",
+  DROP COLUMN IF EXISTS "nonce";
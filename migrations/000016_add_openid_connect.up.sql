@@ -0,0 +1,14 @@
+-- the nonce and the time the user signed in go into the ID token
+ALTER TABLE "oauth_authorization_codes"
+  ADD COLUMN IF NOT EXISTS "nonce" text NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS "auth_time" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- ID tokens issued on refresh keep the time of the original sign in
+ALTER TABLE "sessions"
+  ADD COLUMN IF NOT EXISTS "auth_time" timestamptz NULL;
+
+UPDATE "sessions" SET "auth_time" = "created_at" WHERE "auth_time" IS NULL;
+
+ALTER TABLE "sessions"
+  ALTER COLUMN "auth_time" SET DEFAULT CURRENT_TIMESTAMP,
+  ALTER COLUMN "auth_time" SET NOT NULL;
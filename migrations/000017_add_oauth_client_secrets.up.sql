@@ -0,0 +1,4 @@
+-- confidential clients authenticate with a secret, only its SHA-256 hash
+-- is stored
+ALTER TABLE "oauth_clients"
+  ADD COLUMN IF NOT EXISTS "secret_hash" text NULL;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS "oauth_device_codes" (
+  "id" uuid NOT NULL,
+  "client_id" text NOT NULL REFERENCES "oauth_clients" ("id") ON DELETE CASCADE,
+  "device_code_hash" text NOT NULL,
+  "user_code_hash" text NOT NULL,
+  "scopes" text[] NOT NULL DEFAULT '{}',
+  "interval" integer NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "last_polled_at" timestamptz NULL,
+  "user_id" uuid NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+  "approved_at" timestamptz NULL,
+  "denied_at" timestamptz NULL,
+  "consumed_at" timestamptz NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "oauth_device_codes_device_code_hash_idx" ON "oauth_device_codes" ("device_code_hash");
+CREATE INDEX IF NOT EXISTS "oauth_device_codes_user_code_hash_idx" ON "oauth_device_codes" ("user_code_hash");
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "users_username_idx";
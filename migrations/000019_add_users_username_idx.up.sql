@@ -0,0 +1 @@
+CREATE UNIQUE INDEX IF NOT EXISTS "users_username_idx" ON "users" (lower("username")) WHERE "deleted_at" IS NULL;